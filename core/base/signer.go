@@ -0,0 +1,7 @@
+package base
+
+// Signer 对 32 字节摘要进行 secp256k1 签名, 私钥不在本地时 (硬件钱包、远程签名服务) 实现该接口
+type Signer interface {
+	// SignHash 返回 65 字节的 [R || S || V] 可恢复签名, V 为 0 或 1
+	SignHash(hash []byte) ([]byte, error)
+}
@@ -33,6 +33,27 @@ type TransactionDetail struct {
 	BlockHash         string
 	BlockNumber       *big.Int
 	TransactionIndex  uint
+	Confirmations     uint64               //确认数
+	FeeRate           float64              //费率 UTXO链为 sat/vB
+	Inputs            []*TransactionInput  //交易输入 UTXO链
+	Outputs           []*TransactionOutput //交易输出 UTXO链
+	NetValue          *big.Int             //查询地址的余额变化 负数为转出 地址历史
+}
+
+// TransactionInput UTXO 交易的输入
+type TransactionInput struct {
+	TxId    string   //引用的交易
+	Vout    uint32   //引用的输出序号
+	Address string   //引用输出的地址
+	Value   *big.Int //引用输出的金额
+}
+
+// TransactionOutput UTXO 交易的输出
+type TransactionOutput struct {
+	Index      uint32
+	Address    string
+	ScriptType string //脚本类型 如 witness_v0_keyhash
+	Value      *big.Int
 }
 
 type TransactionStatus = int
@@ -14,6 +14,16 @@ import (
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
 )
 
+// AddressType 地址类型
+type AddressType int
+
+const (
+	AddressTypeLegacy       AddressType = iota // P2PKH
+	AddressTypeNestedSegwit                    // P2SH-P2WPKH
+	AddressTypeNativeSegwit                    // P2WPKH
+	AddressTypeTaproot                         // P2TR
+)
+
 type Account struct {
 	Coin
 	privateKey *btcec.PrivateKey
@@ -39,6 +49,7 @@ func NewAccount(mnemonic string, chainId int) (*Account, error) {
 	}
 
 	return &Account{
+		Coin:       NewCoin(chain),
 		privateKey: pri,
 		address:    address,
 		chain:      chain,
@@ -76,13 +87,14 @@ func NewAccountWithPrivateKey(privateKey string, chainId int) (*Account, error)
 		return nil, err
 	}
 	return &Account{
+		Coin:       NewCoin(chain),
 		privateKey: pri,
 		address:    address,
 		chain:      chain,
 	}, nil
 }
 func (a *Account) GetKey(addr btcutil.Address) (*btcec.PrivateKey, bool, error) {
-	return a.privateKey, true, nil
+	return a.privateKey, a.address.Format() == btcutil.PKFCompressed, nil
 }
 
 func (a *Account) Address() (string, error) {
@@ -113,6 +125,9 @@ func (a *Account) PublicKeyHex() string {
 
 // NativeSegwitAddress P2WPKH just for m/84'/
 func (a *Account) NativeSegwitAddress() (string, error) {
+	if a.chain.Bech32HRPSegwit == "" {
+		return "", log.WithError(utils.ErrAddressTypeNotSupported, "NativeSegwitAddress failed")
+	}
 	address, err := btcutil.NewAddressWitnessPubKeyHash(a.address.AddressPubKeyHash().ScriptAddress(), a.chain)
 	if err != nil {
 		return "", log.WithError(err, "NewAddressWitnessPubKeyHash failed")
@@ -122,6 +137,9 @@ func (a *Account) NativeSegwitAddress() (string, error) {
 
 // NestedSegwitAddress P2SH-P2WPKH just for m/49'/
 func (a *Account) NestedSegwitAddress() (string, error) {
+	if a.chain.Bech32HRPSegwit == "" {
+		return "", log.WithError(utils.ErrAddressTypeNotSupported, "NestedSegwitAddress failed")
+	}
 	witAddr, err := btcutil.NewAddressWitnessPubKeyHash(a.address.AddressPubKeyHash().ScriptAddress(), a.chain)
 	if err != nil {
 		return "", log.WithError(err, "NewAddressWitnessPubKeyHash failed")
@@ -139,6 +157,9 @@ func (a *Account) NestedSegwitAddress() (string, error) {
 
 // TaprootAddress P2TR just for m/86'/
 func (a *Account) TaprootAddress() (string, error) {
+	if a.chain.Bech32HRPSegwit == "" {
+		return "", log.WithError(utils.ErrAddressTypeNotSupported, "TaprootAddress failed")
+	}
 	tapKey := txscript.ComputeTaprootKeyNoScript(a.address.PubKey())
 	address, err := btcutil.NewAddressTaproot(
 		schnorr.SerializePubKey(tapKey), a.chain,
@@ -161,3 +182,18 @@ func (a *Account) ComingTaprootAddress() (string, error) {
 func (a *Account) LegacyAddress() string {
 	return a.address.AddressPubKeyHash().EncodeAddress()
 }
+
+// AddressOfType 获取指定类型的地址
+func (a *Account) AddressOfType(addrType AddressType) (string, error) {
+	switch addrType {
+	case AddressTypeLegacy:
+		return a.LegacyAddress(), nil
+	case AddressTypeNestedSegwit:
+		return a.NestedSegwitAddress()
+	case AddressTypeNativeSegwit:
+		return a.NativeSegwitAddress()
+	case AddressTypeTaproot:
+		return a.TaprootAddress()
+	}
+	return "", log.WithError(utils.ErrAddressTypeNotSupported, "AddressOfType failed")
+}
@@ -146,7 +146,7 @@ func (t *StreamToken) PushTx(signedTx string, transaction *btc.Transaction) (str
 		EnableBCInfoHacks: true,
 	}, nil)
 
-	send, err := transaction.SendRawTransaction(client)
+	send, err := transaction.SendRawTransaction(client, t.ChainParams())
 	if err != nil {
 		return "", err
 	}
@@ -166,3 +166,17 @@ func (t *StreamToken) GetGasFee() (uint64, error) {
 	}
 	return data.Data.ByteSat * 1000, nil
 }
+
+// ChainParams 实现 btc.NetworkReporter, 接口地址均为 testnet
+func (t *StreamToken) ChainParams() *chaincfg.Params {
+	return &chaincfg.TestNet3Params
+}
+
+// GetHistory 获取地址的交易历史, 通过 blockstream.info 的 Esplora 接口查询
+func (t *StreamToken) GetHistory(address btcutil.Address, cursor string) (*btc.HistoryPage, error) {
+	source, err := NewEsploraSource(utils.BtcChainTestNet3, "")
+	if err != nil {
+		return nil, err
+	}
+	return source.GetHistory(address, cursor)
+}
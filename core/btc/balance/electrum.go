@@ -0,0 +1,609 @@
+package balance
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/pkg/errors"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/btc"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// ElectrumProtocolVersion 客户端协商的协议版本
+	ElectrumProtocolVersion = "1.4"
+	// ElectrumFeeTargetBlocks GetGasFee 默认的确认目标区块数
+	ElectrumFeeTargetBlocks = 6
+
+	electrumClientName = "hdwallet-go-sdk"
+	methodHeadersSub   = "blockchain.headers.subscribe"
+)
+
+var (
+	// ErrElectrumClosed 连接已关闭
+	ErrElectrumClosed = errors.New("electrum connection closed")
+)
+
+// ElectrumBalance scripthash 余额, 单位聪
+type ElectrumBalance struct {
+	Confirmed   int64 `json:"confirmed"`
+	Unconfirmed int64 `json:"unconfirmed"`
+}
+
+// ElectrumUnspent scripthash 未花费输出
+type ElectrumUnspent struct {
+	TxHash string `json:"tx_hash"`
+	TxPos  uint32 `json:"tx_pos"`
+	Height int64  `json:"height"` // 0 或负数表示在内存池中
+	Value  uint64 `json:"value"`
+}
+
+// ElectrumHistory scripthash 交易历史
+type ElectrumHistory struct {
+	TxHash string `json:"tx_hash"`
+	Height int64  `json:"height"` // 0 或负数表示在内存池中
+	Fee    uint64 `json:"fee,omitempty"`
+}
+
+// ElectrumHeader 区块头通知
+type ElectrumHeader struct {
+	Height int64  `json:"height"`
+	Hex    string `json:"hex"`
+}
+
+// BlockHeader 解析区块头
+func (h *ElectrumHeader) BlockHeader() (*wire.BlockHeader, error) {
+	data, err := hex.DecodeString(h.Hex)
+	if err != nil {
+		return nil, err
+	}
+	header := &wire.BlockHeader{}
+	if err = header.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+type electrumRequest struct {
+	JsonRpc string        `json:"jsonrpc"`
+	Id      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type electrumResponse struct {
+	Id     *uint64         `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// electrumError 服务端返回的错误, 兼容对象和字符串两种格式
+func electrumError(raw json.RawMessage) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var e struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &e); err == nil && e.Message != "" {
+		return fmt.Errorf("electrum error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Errorf("electrum error: %s", string(raw))
+}
+
+// ElectrumClient Electrum/Fulcrum 服务器的 JSON-RPC 客户端, 支持 TCP 和 TLS
+type ElectrumClient struct {
+	conn    net.Conn
+	timeout time.Duration
+
+	mu         sync.Mutex
+	nextId     uint64
+	pending    map[uint64]chan *electrumResponse
+	headerSubs []chan *ElectrumHeader
+	closed     bool
+	done       chan struct{}
+}
+
+// NewElectrumClient 连接 Electrum 服务器, tlsConfig 为空时使用明文 TCP
+func NewElectrumClient(address string, tlsConfig *tls.Config, timeout time.Duration) (*ElectrumClient, error) {
+	if address == "" {
+		return nil, log.WithError(utils.ErrInvalidURL, "NewElectrumClient failed")
+	}
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 10 * time.Minute}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, log.WithError(err, "dial electrum failed")
+	}
+
+	c := &ElectrumClient{
+		conn:    conn,
+		timeout: timeout,
+		pending: make(map[uint64]chan *electrumResponse),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+
+	// 协议要求第一条消息为 server.version
+	var version []string
+	if err = c.Call("server.version", []interface{}{electrumClientName, ElectrumProtocolVersion}, &version); err != nil {
+		c.Close()
+		return nil, log.WithError(err, "server.version failed")
+	}
+	return c, nil
+}
+
+// Close 关闭连接, 所有等待中的请求返回 ErrElectrumClosed
+func (c *ElectrumClient) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+// Call 发送请求并等待响应, result 为空时丢弃结果
+func (c *ElectrumClient) Call(method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	ch := make(chan *electrumResponse, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrElectrumClosed
+	}
+	c.nextId++
+	id := c.nextId
+	c.pending[id] = ch
+	data, err := json.Marshal(&electrumRequest{JsonRpc: "2.0", Id: id, Method: method, Params: params})
+	if err == nil {
+		c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+		_, err = c.conn.Write(append(data, '\n'))
+	}
+	if err != nil {
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return err
+	}
+	c.mu.Unlock()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return ErrElectrumClosed
+		}
+		if err = electrumError(resp.Error); err != nil {
+			return err
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-timer.C:
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return fmt.Errorf("electrum request %s timeout", method)
+	}
+}
+
+// readLoop 读取响应并分发给请求方或订阅者
+func (c *ElectrumClient) readLoop() {
+	defer c.shutdown()
+	reader := bufio.NewReader(c.conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			c.dispatch(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *ElectrumClient) dispatch(line []byte) {
+	var resp electrumResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		log.Warnf("electrum: invalid message %s", string(line))
+		return
+	}
+	if resp.Id != nil {
+		c.mu.Lock()
+		ch, ok := c.pending[*resp.Id]
+		delete(c.pending, *resp.Id)
+		c.mu.Unlock()
+		if ok {
+			ch <- &resp
+		}
+		return
+	}
+	if resp.Method == methodHeadersSub {
+		var headers []*ElectrumHeader
+		if err := json.Unmarshal(resp.Params, &headers); err != nil {
+			log.Warnf("electrum: invalid header notification %s", string(resp.Params))
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, header := range headers {
+			for _, sub := range c.headerSubs {
+				select {
+				case sub <- header:
+				default:
+					log.Warnf("electrum: header subscriber is full, drop height %d", header.Height)
+				}
+			}
+		}
+	}
+}
+
+func (c *ElectrumClient) shutdown() {
+	c.mu.Lock()
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	for _, sub := range c.headerSubs {
+		close(sub)
+	}
+	c.headerSubs = nil
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// ElectrumScriptHash 计算地址对应的 scripthash: sha256(scriptPubKey) 按字节倒序
+func ElectrumScriptHash(address btcutil.Address) (string, error) {
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(script)
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// GetScriptHashBalance 查询地址余额
+func (c *ElectrumClient) GetScriptHashBalance(address btcutil.Address) (*ElectrumBalance, error) {
+	scriptHash, err := ElectrumScriptHash(address)
+	if err != nil {
+		return nil, err
+	}
+	var balance ElectrumBalance
+	if err = c.Call("blockchain.scripthash.get_balance", []interface{}{scriptHash}, &balance); err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// ListUnspent 查询地址未花费输出, 包含内存池中的输出
+func (c *ElectrumClient) ListUnspent(address btcutil.Address) ([]ElectrumUnspent, error) {
+	scriptHash, err := ElectrumScriptHash(address)
+	if err != nil {
+		return nil, err
+	}
+	var unspent []ElectrumUnspent
+	if err = c.Call("blockchain.scripthash.listunspent", []interface{}{scriptHash}, &unspent); err != nil {
+		return nil, err
+	}
+	return unspent, nil
+}
+
+// GetHistory 查询地址交易历史, 包含内存池中的交易
+func (c *ElectrumClient) GetHistory(address btcutil.Address) ([]ElectrumHistory, error) {
+	scriptHash, err := ElectrumScriptHash(address)
+	if err != nil {
+		return nil, err
+	}
+	var history []ElectrumHistory
+	if err = c.Call("blockchain.scripthash.get_history", []interface{}{scriptHash}, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetTransaction 获取原始交易的十六进制
+func (c *ElectrumClient) GetTransaction(txId string) (string, error) {
+	var txHex string
+	if err := c.Call("blockchain.transaction.get", []interface{}{txId, false}, &txHex); err != nil {
+		return "", err
+	}
+	return txHex, nil
+}
+
+// Broadcast 广播原始交易, 返回交易ID
+func (c *ElectrumClient) Broadcast(txHex string) (string, error) {
+	var txId string
+	if err := c.Call("blockchain.transaction.broadcast", []interface{}{txHex}, &txId); err != nil {
+		return "", err
+	}
+	return txId, nil
+}
+
+// electrumBlockHeaders blockchain.block.headers 的返回, hex 为连续的区块头
+type electrumBlockHeaders struct {
+	Count int    `json:"count"`
+	Hex   string `json:"hex"`
+	Max   int    `json:"max"`
+}
+
+// GetBlockHeaders 获取从 startHeight 开始的最多 count 个区块头, 实现 btc.HeaderSource
+func (c *ElectrumClient) GetBlockHeaders(startHeight int64, count int) ([]*wire.BlockHeader, error) {
+	var result electrumBlockHeaders
+	if err := c.Call("blockchain.block.headers", []interface{}{startHeight, count}, &result); err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(result.Hex)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != result.Count*wire.MaxBlockHeaderPayload {
+		return nil, fmt.Errorf("electrum: invalid headers length %d", len(data))
+	}
+	reader := bytes.NewReader(data)
+	headers := make([]*wire.BlockHeader, result.Count)
+	for i := range headers {
+		headers[i] = &wire.BlockHeader{}
+		if err = headers[i].Deserialize(reader); err != nil {
+			return nil, err
+		}
+	}
+	return headers, nil
+}
+
+// EstimateFee 估算在 blocks 个区块内确认所需的费率, 单位 BTC/kB, 无法估算时返回 -1
+func (c *ElectrumClient) EstimateFee(blocks int) (float64, error) {
+	var fee float64
+	if err := c.Call("blockchain.estimatefee", []interface{}{blocks}, &fee); err != nil {
+		return 0, err
+	}
+	return fee, nil
+}
+
+// RelayFee 服务器接受的最低费率, 单位 BTC/kB
+func (c *ElectrumClient) RelayFee() (float64, error) {
+	var fee float64
+	if err := c.Call("blockchain.relayfee", nil, &fee); err != nil {
+		return 0, err
+	}
+	return fee, nil
+}
+
+// SubscribeHeaders 订阅新区块, 返回当前链顶和后续通知的通道, 连接关闭时通道关闭
+func (c *ElectrumClient) SubscribeHeaders() (*ElectrumHeader, <-chan *ElectrumHeader, error) {
+	sub := make(chan *ElectrumHeader, 16)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, nil, ErrElectrumClosed
+	}
+	c.headerSubs = append(c.headerSubs, sub)
+	c.mu.Unlock()
+
+	var tip ElectrumHeader
+	if err := c.Call(methodHeadersSub, nil, &tip); err != nil {
+		c.unsubscribeHeaders(sub)
+		return nil, nil, err
+	}
+	return &tip, sub, nil
+}
+
+func (c *ElectrumClient) unsubscribeHeaders(sub chan *ElectrumHeader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.headerSubs {
+		if c.headerSubs[i] == sub {
+			c.headerSubs = append(c.headerSubs[:i], c.headerSubs[i+1:]...)
+			close(sub)
+			return
+		}
+	}
+}
+
+// ElectrumToken 基于 Electrum 服务器实现的 btc.NetParams
+type ElectrumToken struct {
+	btc.Coin
+	Info   *base.TokenInfo
+	chain  *btc.Chain
+	client *ElectrumClient
+	params *chaincfg.Params
+}
+
+func NewElectrumToken(chain *btc.Chain, client *ElectrumClient) *ElectrumToken {
+	return &ElectrumToken{chain: chain, client: client, Info: &base.TokenInfo{}}
+}
+
+func (t *ElectrumToken) Client() *ElectrumClient {
+	return t.client
+}
+
+// SetChainParams 设置 Electrum 服务器所在的网络, 未设置时使用 chain 的客户端网络
+func (t *ElectrumToken) SetChainParams(params *chaincfg.Params) *ElectrumToken {
+	t.params = params
+	return t
+}
+
+// ChainParams 实现 btc.NetworkReporter, 网络未知时为空
+func (t *ElectrumToken) ChainParams() *chaincfg.Params {
+	if t.params != nil || t.chain == nil {
+		return t.params
+	}
+	client, err := t.chain.Client()
+	if err != nil {
+		return nil
+	}
+	return client.ChainParams()
+}
+
+func (t *ElectrumToken) GetDecimal() int16 {
+	if t.Info.Decimal == 0 {
+		t.Info, _ = t.TokenInfo()
+	}
+
+	return t.Info.Decimal
+}
+
+func (t *ElectrumToken) TokenInfo() (*base.TokenInfo, error) {
+	token := base.GetToken(t.CoinType(), "")
+	if token != nil {
+		return token, nil
+	}
+
+	t.Info = &base.TokenInfo{
+		Name:    "BTC",
+		Symbol:  "BTC",
+		Decimal: 8,
+	}
+
+	base.AddToken(t.CoinType(), "", t.Info)
+
+	return t.Info, nil
+}
+
+// GetBtcUnspent 获取已确认的未花费输出
+func (t *ElectrumToken) GetBtcUnspent(current btcutil.Address, amount uint64) ([]btc.BtcUnspent, error) {
+	script, err := txscript.PayToAddrScript(current)
+	if err != nil {
+		return nil, err
+	}
+	scriptStr := hex.EncodeToString(script)
+	unSpent, err := t.client.ListUnspent(current)
+	if err != nil {
+		return nil, log.WithError(err, "ListUnspent failed")
+	}
+	data := make([]btc.BtcUnspent, 0, len(unSpent))
+	for _, item := range unSpent {
+		if item.Height <= 0 {
+			continue
+		}
+		data = append(data, btc.BtcUnspent{
+			TxID:         item.TxHash,
+			Vout:         item.TxPos,
+			ScriptPubKey: scriptStr,
+			Amount:       btcutil.Amount(item.Value).ToBTC(),
+			Value:        item.Value,
+		})
+	}
+	return data, nil
+}
+
+// GetBalance 获取余额, Usable 只统计已确认部分
+func (t *ElectrumToken) GetBalance(address btcutil.Address) (*base.Balance, error) {
+	balance, err := t.client.GetScriptHashBalance(address)
+	if err != nil {
+		return base.EmptyBalance(), log.WithError(err, "GetScriptHashBalance failed")
+	}
+	total := balance.Confirmed + balance.Unconfirmed
+	if total < 0 {
+		total = 0
+	}
+	usable := balance.Confirmed
+	if usable > total {
+		usable = total
+	}
+	return &base.Balance{
+		Total:  utils.NewOptAmount(strconv.FormatInt(total, 10), t.GetDecimal()),
+		Usable: utils.NewOptAmount(strconv.FormatInt(usable, 10), t.GetDecimal()),
+	}, nil
+}
+
+// PushTx 广播交易, signedTx 为空时序列化 transaction
+func (t *ElectrumToken) PushTx(signedTx string, transaction *btc.Transaction) (string, error) {
+	if signedTx == "" {
+		if transaction == nil {
+			return "", log.WithError(utils.ErrInvalidValue, "PushTx failed")
+		}
+		var err error
+		if signedTx, err = transaction.TxHex(); err != nil {
+			return "", err
+		}
+	}
+	txId, err := t.client.Broadcast(signedTx)
+	if err != nil {
+		return "", log.WithError(err, "Broadcast failed")
+	}
+	return txId, nil
+}
+
+// GetGasFee 获取推荐的矿工费, 单位 sat/kB
+func (t *ElectrumToken) GetGasFee() (uint64, error) {
+	fee, err := t.client.EstimateFee(ElectrumFeeTargetBlocks)
+	if err != nil {
+		return 0, log.WithError(err, "EstimateFee failed")
+	}
+	if fee <= 0 {
+		// 服务器没有足够数据估算时退回到最低转发费率
+		if fee, err = t.client.RelayFee(); err != nil {
+			return 0, log.WithError(err, "RelayFee failed")
+		}
+	}
+	amount, err := btcutil.NewAmount(fee)
+	if err != nil {
+		return 0, log.WithError(err, "NewAmount failed")
+	}
+	return uint64(amount), nil
+}
+
+// GetHistory 暂不支持, Electrum 需要逐笔获取交易及其引用的输出
+func (t *ElectrumToken) GetHistory(address btcutil.Address, cursor string) (*btc.HistoryPage, error) {
+	return nil, log.WithError(utils.ErrNotSupported, "ElectrumToken GetHistory")
+}
+
+// BlockNotifications 实现 btc.BlockNotifier, 基于区块头订阅, 连接关闭时通道关闭
+func (t *ElectrumToken) BlockNotifications() (<-chan int64, error) {
+	_, headers, err := t.client.SubscribeHeaders()
+	if err != nil {
+		return nil, log.WithError(err, "SubscribeHeaders failed")
+	}
+	blocks := make(chan int64, 16)
+	go func() {
+		defer close(blocks)
+		for header := range headers {
+			// 通知只用于触发查询, 来不及处理时丢弃
+			select {
+			case blocks <- header.Height:
+			default:
+			}
+		}
+	}()
+	return blocks, nil
+}
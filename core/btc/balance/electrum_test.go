@@ -0,0 +1,357 @@
+package balance
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/config"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/btc"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func init() {
+	config.InitConfig(&config.BaseConfig{
+		BaseDir:    "..",
+		LogSwitch:  "CONSOLE_FILE",
+		Platform:   "ALL",
+		DeviceType: "UNKNOWN",
+		BtcParam:   "TestNet3",
+	})
+}
+
+// fakeElectrumServer 进程内的 Electrum 服务器, 按方法名返回预设结果
+type fakeElectrumServer struct {
+	listener net.Listener
+	handlers map[string]func(params []json.RawMessage) (interface{}, interface{})
+
+	mu    sync.Mutex
+	conns []net.Conn
+	calls []string
+}
+
+func newFakeElectrumServer(t *testing.T, tlsConfig *tls.Config) *fakeElectrumServer {
+	var (
+		listener net.Listener
+		err      error
+	)
+	if tlsConfig != nil {
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeElectrumServer{
+		listener: listener,
+		handlers: map[string]func(params []json.RawMessage) (interface{}, interface{}){
+			"server.version": func(params []json.RawMessage) (interface{}, interface{}) {
+				return []string{"FakeElectrum 1.0", ElectrumProtocolVersion}, nil
+			},
+		},
+	}
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *fakeElectrumServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeElectrumServer) handle(method string, fn func(params []json.RawMessage) (interface{}, interface{})) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = fn
+}
+
+func (s *fakeElectrumServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *fakeElectrumServer) serveConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req struct {
+			Id     uint64            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err = json.Unmarshal(line, &req); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.calls = append(s.calls, req.Method)
+		fn, ok := s.handlers[req.Method]
+		s.mu.Unlock()
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+		if !ok {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "unknown method " + req.Method}
+		} else if result, rpcErr := fn(req.Params); rpcErr != nil {
+			resp["error"] = rpcErr
+		} else {
+			resp["result"] = result
+		}
+		s.write(conn, resp)
+	}
+}
+
+func (s *fakeElectrumServer) write(conn net.Conn, msg interface{}) {
+	data, _ := json.Marshal(msg)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn.Write(append(data, '\n'))
+}
+
+// notify 向所有连接推送通知
+func (s *fakeElectrumServer) notify(method string, params ...interface{}) {
+	s.mu.Lock()
+	conns := append([]net.Conn(nil), s.conns...)
+	s.mu.Unlock()
+	for _, conn := range conns {
+		s.write(conn, map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+	}
+}
+
+func (s *fakeElectrumServer) close() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func newTestElectrumToken(t *testing.T, server *fakeElectrumServer) *ElectrumToken {
+	client, err := NewElectrumClient(server.addr(), nil, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return NewElectrumToken(nil, client)
+}
+
+func TestElectrumScriptHash(t *testing.T) {
+	// 取自 Electrum 协议文档的示例
+	address, _ := btcutil.DecodeAddress("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", &chaincfg.MainNetParams)
+	scriptHash, err := ElectrumScriptHash(address)
+	assert.NoError(t, err)
+	assert.Equal(t, "8b01df4e368ea28f8dc0423bcf7a4923e3a12d307c875e47a0cfbf90b5c39161", scriptHash)
+}
+
+func TestElectrumToken_GetBalance(t *testing.T) {
+	server := newFakeElectrumServer(t, nil)
+	server.handle("blockchain.scripthash.get_balance", func(params []json.RawMessage) (interface{}, interface{}) {
+		return map[string]int64{"confirmed": 150000000, "unconfirmed": -50000000}, nil
+	})
+	token := newTestElectrumToken(t, server)
+	address, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", &chaincfg.TestNet3Params)
+
+	balance, err := token.GetBalance(address)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", balance.Total.AmountString())
+	assert.Equal(t, "1", balance.Usable.AmountString())
+}
+
+func TestElectrumToken_GetBtcUnspent(t *testing.T) {
+	server := newFakeElectrumServer(t, nil)
+	address, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", &chaincfg.TestNet3Params)
+	scriptHash, _ := ElectrumScriptHash(address)
+	server.handle("blockchain.scripthash.listunspent", func(params []json.RawMessage) (interface{}, interface{}) {
+		var got string
+		json.Unmarshal(params[0], &got)
+		if got != scriptHash {
+			return nil, map[string]interface{}{"code": 1, "message": "bad scripthash"}
+		}
+		return []map[string]interface{}{
+			{"tx_hash": "604520d6133dbacc55d15ea76d42797e88a0cc384153d3eb6524da90dbcc33f6", "tx_pos": 1, "height": 2504192, "value": 5000},
+			{"tx_hash": "b1db4a20e9c35e8ef64232d99c5ee941461ef1748a62fd1360364d5e2df3c416", "tx_pos": 0, "height": 0, "value": 7000},
+		}, nil
+	})
+	token := newTestElectrumToken(t, server)
+
+	unspent, err := token.GetBtcUnspent(address, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, unspent, 1) {
+		assert.Equal(t, uint32(1), unspent[0].Vout)
+		assert.Equal(t, uint64(5000), unspent[0].Value)
+		assert.True(t, strings.HasPrefix(unspent[0].ScriptPubKey, "a914"))
+	}
+}
+
+func TestElectrumClient_GetHistory(t *testing.T) {
+	server := newFakeElectrumServer(t, nil)
+	server.handle("blockchain.scripthash.get_history", func(params []json.RawMessage) (interface{}, interface{}) {
+		return []map[string]interface{}{
+			{"tx_hash": "604520d6133dbacc55d15ea76d42797e88a0cc384153d3eb6524da90dbcc33f6", "height": 2504192},
+			{"tx_hash": "b1db4a20e9c35e8ef64232d99c5ee941461ef1748a62fd1360364d5e2df3c416", "height": 0, "fee": 250},
+		}, nil
+	})
+	token := newTestElectrumToken(t, server)
+	address, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", &chaincfg.TestNet3Params)
+
+	history, err := token.Client().GetHistory(address)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, int64(2504192), history[0].Height)
+		assert.Equal(t, uint64(250), history[1].Fee)
+	}
+}
+
+func TestElectrumToken_PushTx(t *testing.T) {
+	server := newFakeElectrumServer(t, nil)
+	server.handle("blockchain.transaction.broadcast", func(params []json.RawMessage) (interface{}, interface{}) {
+		var txHex string
+		json.Unmarshal(params[0], &txHex)
+		if txHex != "0100" {
+			return nil, map[string]interface{}{"code": 1, "message": "the transaction was rejected by network rules."}
+		}
+		return "604520d6133dbacc55d15ea76d42797e88a0cc384153d3eb6524da90dbcc33f6", nil
+	})
+	token := newTestElectrumToken(t, server)
+
+	txId, err := token.PushTx("0100", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "604520d6133dbacc55d15ea76d42797e88a0cc384153d3eb6524da90dbcc33f6", txId)
+
+	_, err = token.PushTx("0200", nil)
+	assert.ErrorContains(t, err, "rejected by network rules")
+}
+
+func TestElectrumToken_GetGasFee(t *testing.T) {
+	server := newFakeElectrumServer(t, nil)
+	estimate := 0.00012
+	server.handle("blockchain.estimatefee", func(params []json.RawMessage) (interface{}, interface{}) {
+		return estimate, nil
+	})
+	server.handle("blockchain.relayfee", func(params []json.RawMessage) (interface{}, interface{}) {
+		return 0.00001, nil
+	})
+	token := newTestElectrumToken(t, server)
+
+	fee, err := token.GetGasFee()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12000), fee)
+
+	// 无法估算时使用 relayfee
+	estimate = -1
+	fee, err = token.GetGasFee()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000), fee)
+}
+
+func TestElectrumClient_SubscribeHeaders(t *testing.T) {
+	server := newFakeElectrumServer(t, nil)
+	server.handle(methodHeadersSub, func(params []json.RawMessage) (interface{}, interface{}) {
+		return map[string]interface{}{"height": 100, "hex": "00"}, nil
+	})
+	token := newTestElectrumToken(t, server)
+
+	tip, headers, err := token.Client().SubscribeHeaders()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), tip.Height)
+
+	server.notify(methodHeadersSub, map[string]interface{}{"height": 101, "hex": "01"})
+	select {
+	case header := <-headers:
+		assert.Equal(t, int64(101), header.Height)
+	case <-time.After(5 * time.Second):
+		t.Fatal("header notification not received")
+	}
+
+	// 连接断开后通知通道关闭
+	server.close()
+	select {
+	case _, ok := <-headers:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("header channel not closed")
+	}
+}
+
+func TestElectrumToken_BlockNotifications(t *testing.T) {
+	server := newFakeElectrumServer(t, nil)
+	server.handle(methodHeadersSub, func(params []json.RawMessage) (interface{}, interface{}) {
+		return map[string]interface{}{"height": 100, "hex": "00"}, nil
+	})
+	token := newTestElectrumToken(t, server)
+
+	var _ btc.BlockNotifier = token
+	blocks, err := token.BlockNotifications()
+	assert.NoError(t, err)
+	server.notify(methodHeadersSub, map[string]interface{}{"height": 101, "hex": "01"})
+	select {
+	case height := <-blocks:
+		assert.Equal(t, int64(101), height)
+	case <-time.After(5 * time.Second):
+		t.Fatal("block notification not received")
+	}
+}
+
+func TestElectrumClient_TLS(t *testing.T) {
+	certPem, keyPem, err := btcutil.NewTLSCertPair("fake electrum", time.Now().Add(time.Hour), []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeElectrumServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	server.handle("blockchain.relayfee", func(params []json.RawMessage) (interface{}, interface{}) {
+		return 0.00001, nil
+	})
+
+	// 自建服务器通常使用自签名证书
+	client, err := NewElectrumClient(server.addr(), &tls.Config{InsecureSkipVerify: true}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	fee, err := client.RelayFee()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.00001, fee)
+}
+
+func TestElectrumClient_GetBlockHeaders(t *testing.T) {
+	// 测试网创世区块头
+	genesis := "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4adae5494dffff001d1aa4ae18"
+	server := newFakeElectrumServer(t, nil)
+	server.handle("blockchain.block.headers", func(params []json.RawMessage) (interface{}, interface{}) {
+		return map[string]interface{}{"count": 2, "hex": genesis + genesis, "max": 2016}, nil
+	})
+	token := newTestElectrumToken(t, server)
+
+	var _ btc.HeaderSource = token.Client()
+	headers, err := token.Client().GetBlockHeaders(0, 2)
+	assert.NoError(t, err)
+	if assert.Len(t, headers, 2) {
+		assert.Equal(t, chaincfg.TestNet3Params.GenesisHash.String(), headers[1].BlockHash().String())
+	}
+
+	server.handle("blockchain.block.headers", func(params []json.RawMessage) (interface{}, interface{}) {
+		return map[string]interface{}{"count": 2, "hex": genesis, "max": 2016}, nil
+	})
+	_, err = token.Client().GetBlockHeaders(0, 2)
+	assert.Error(t, err)
+}
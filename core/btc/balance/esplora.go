@@ -0,0 +1,328 @@
+package balance
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/btc"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"strconv"
+	"strings"
+)
+
+// EsploraUrls 各网络默认的 Esplora 接口地址
+var EsploraUrls = map[int]string{
+	utils.BtcChainMainNet:  "https://blockstream.info/api",
+	utils.BtcChainTestNet3: "https://blockstream.info/testnet/api",
+	utils.BtcChainTestNet4: "https://mempool.space/testnet4/api",
+	utils.BtcChainSigNet:   "https://mempool.space/signet/api",
+}
+
+const (
+	// esploraChainPageSize Esplora 每页返回的已确认交易数量
+	esploraChainPageSize = 25
+	// esploraTxNotFound 交易不存在时接口返回的内容
+	esploraTxNotFound = "Transaction not found"
+)
+
+// EsploraTx Esplora 返回的交易
+type EsploraTx struct {
+	TxId     string         `json:"txid"`
+	Version  int32          `json:"version"`
+	LockTime uint32         `json:"locktime"`
+	Size     int64          `json:"size"`
+	Weight   int64          `json:"weight"`
+	Fee      int64          `json:"fee"`
+	Vin      []EsploraTxIn  `json:"vin"`
+	Vout     []EsploraTxOut `json:"vout"`
+	Status   EsploraStatus  `json:"status"`
+}
+
+// EsploraTxIn Esplora 返回的交易输入, 包含引用的输出
+type EsploraTxIn struct {
+	TxId       string        `json:"txid"`
+	Vout       uint32        `json:"vout"`
+	IsCoinbase bool          `json:"is_coinbase"`
+	Sequence   uint32        `json:"sequence"`
+	Prevout    *EsploraTxOut `json:"prevout"`
+}
+
+// EsploraTxOut Esplora 返回的交易输出
+type EsploraTxOut struct {
+	ScriptPubKey        string `json:"scriptpubkey"`
+	ScriptPubKeyType    string `json:"scriptpubkey_type"`
+	ScriptPubKeyAddress string `json:"scriptpubkey_address"`
+	Value               int64  `json:"value"`
+}
+
+// EsploraStatus 交易的确认状态
+type EsploraStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int64  `json:"block_height"`
+	BlockHash   string `json:"block_hash"`
+	BlockTime   int64  `json:"block_time"`
+}
+
+// EsploraSource 通过 Esplora 接口(blockstream.info、mempool.space 等)获取交易
+type EsploraSource struct {
+	baseUrl string
+	params  *chaincfg.Params
+}
+
+// NewEsploraSource baseUrl 为空时使用 EsploraUrls 中的默认地址
+func NewEsploraSource(chainId int, baseUrl string) (*EsploraSource, error) {
+	params, err := utils.GetBtcChainParams(chainId)
+	if err != nil {
+		return nil, log.WithError(err, "ChainID failed")
+	}
+	if baseUrl == "" {
+		baseUrl = EsploraUrls[chainId]
+	}
+	if baseUrl == "" {
+		return nil, log.WithError(utils.ErrInvalidURL, "NewEsploraSource failed")
+	}
+	return &EsploraSource{baseUrl: strings.TrimRight(baseUrl, "/"), params: params}, nil
+}
+
+func (s *EsploraSource) BaseUrl() string {
+	return s.baseUrl
+}
+
+func (s *EsploraSource) ChainParams() *chaincfg.Params {
+	return s.params
+}
+
+// GetRawTransaction 获取交易, Esplora 的交易数据中已包含引用的输出
+func (s *EsploraSource) GetRawTransaction(hash *chainhash.Hash) (*btc.RawTransaction, error) {
+	var tx EsploraTx
+	if err := esploraGet(fmt.Sprintf("%s/tx/%s", s.baseUrl, hash.String()), &tx); err != nil {
+		return nil, log.WithError(err, "get esplora tx failed")
+	}
+	tipHeight := int64(0)
+	if tx.Status.Confirmed {
+		var err error
+		if tipHeight, err = s.TipHeight(); err != nil {
+			return nil, log.WithError(err, "TipHeight failed")
+		}
+	}
+	return tx.RawTransaction(tipHeight)
+}
+
+// GetHistory 获取地址的交易历史, 第一页包含内存池中的交易, 之后每页 25 笔已确认交易
+// cursor 为上一页最后一笔已确认交易的 txid
+func (s *EsploraSource) GetHistory(address btcutil.Address, cursor string) (*btc.HistoryPage, error) {
+	url := fmt.Sprintf("%s/address/%s/txs", s.baseUrl, address.EncodeAddress())
+	if cursor != "" {
+		if _, err := chainhash.NewHashFromStr(cursor); err != nil {
+			return nil, log.WithError(utils.ErrInvalidValue, "invalid cursor")
+		}
+		url += "/chain/" + cursor
+	}
+	var txs []EsploraTx
+	if err := esploraGet(url, &txs); err != nil {
+		return nil, log.WithError(err, "get esplora history failed")
+	}
+
+	page := &btc.HistoryPage{}
+	tipHeight := int64(0)
+	confirmed := 0
+	for i := range txs {
+		if !txs[i].Status.Confirmed {
+			continue
+		}
+		confirmed++
+		if tipHeight == 0 {
+			var err error
+			if tipHeight, err = s.TipHeight(); err != nil {
+				return nil, log.WithError(err, "TipHeight failed")
+			}
+		}
+		if confirmed == esploraChainPageSize {
+			page.NextCursor = txs[i].TxId
+		}
+	}
+	for i := range txs {
+		raw, err := txs[i].RawTransaction(tipHeight)
+		if err != nil {
+			return nil, log.WithError(err, "RawTransaction failed")
+		}
+		detail := btc.NewTransactionDetail(raw, s.params)
+		btc.SetNetValue(detail, address.EncodeAddress())
+		page.Transactions = append(page.Transactions, detail)
+	}
+	return page, nil
+}
+
+// TipHeight 获取最新区块高度
+func (s *EsploraSource) TipHeight() (int64, error) {
+	data, err := utils.DoGet(s.baseUrl+"/blocks/tip/height", 3)
+	if err != nil {
+		return 0, err
+	}
+	height, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("esplora: %s", strings.TrimSpace(string(data)))
+	}
+	return height, nil
+}
+
+// RawTransaction 转换为 btc.RawTransaction, tipHeight 用于计算确认数
+func (tx *EsploraTx) RawTransaction(tipHeight int64) (*btc.RawTransaction, error) {
+	raw := &btc.RawTransaction{
+		Hash:  tx.TxId,
+		VSize: (tx.Weight + 3) / 4,
+	}
+	for _, vin := range tx.Vin {
+		input := &btc.RawTxInput{Coinbase: vin.IsCoinbase}
+		if vin.IsCoinbase {
+			input.PrevOut = wire.OutPoint{Index: wire.MaxPrevOutIndex}
+		} else {
+			prevHash, err := chainhash.NewHashFromStr(vin.TxId)
+			if err != nil {
+				return nil, err
+			}
+			input.PrevOut = wire.OutPoint{Hash: *prevHash, Index: vin.Vout}
+			if vin.Prevout != nil {
+				if input.TxOut, err = vin.Prevout.TxOut(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		raw.Inputs = append(raw.Inputs, input)
+	}
+	for i := range tx.Vout {
+		out, err := tx.Vout[i].TxOut()
+		if err != nil {
+			return nil, err
+		}
+		raw.Outputs = append(raw.Outputs, out)
+	}
+	if tx.Status.Confirmed {
+		raw.Time = tx.Status.BlockTime
+		raw.Block = &btc.RawBlock{
+			Hash:   tx.Status.BlockHash,
+			Height: tx.Status.BlockHeight,
+			Time:   tx.Status.BlockTime,
+		}
+		if tipHeight >= tx.Status.BlockHeight {
+			raw.Block.Confirmations = uint64(tipHeight - tx.Status.BlockHeight + 1)
+		}
+	}
+	return raw, nil
+}
+
+func (o *EsploraTxOut) TxOut() (*wire.TxOut, error) {
+	script, err := hex.DecodeString(o.ScriptPubKey)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewTxOut(o.Value, script), nil
+}
+
+// esploraGet 请求 Esplora 接口, 出错时接口返回的是纯文本
+func esploraGet(url string, v interface{}) error {
+	data, err := utils.DoGet(url, 3)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("esplora: %s", strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// esploraOutspend 输出的花费情况
+type esploraOutspend struct {
+	Spent bool   `json:"spent"`
+	TxId  string `json:"txid"`
+}
+
+// GetTxStatus 实现 btc.WatchSource, 交易不存在时接口返回 404 和纯文本
+func (s *EsploraSource) GetTxStatus(txId string) (*btc.TxStatus, error) {
+	data, err := utils.DoGet(fmt.Sprintf("%s/tx/%s", s.baseUrl, txId), 3)
+	if err != nil {
+		return nil, log.WithError(err, "get esplora tx failed")
+	}
+	if strings.TrimSpace(string(data)) == esploraTxNotFound {
+		return &btc.TxStatus{}, nil
+	}
+	var tx EsploraTx
+	if err = json.Unmarshal(data, &tx); err != nil {
+		return nil, log.WithError(fmt.Errorf("esplora: %s", strings.TrimSpace(string(data))), "get esplora tx failed")
+	}
+	status := &btc.TxStatus{
+		Found:       true,
+		Confirmed:   tx.Status.Confirmed,
+		BlockHeight: tx.Status.BlockHeight,
+		BlockHash:   tx.Status.BlockHash,
+	}
+	for _, vin := range tx.Vin {
+		if vin.IsCoinbase {
+			continue
+		}
+		prevHash, err := chainhash.NewHashFromStr(vin.TxId)
+		if err != nil {
+			return nil, log.WithError(err, "NewHashFromStr failed")
+		}
+		status.Inputs = append(status.Inputs, wire.OutPoint{Hash: *prevHash, Index: vin.Vout})
+	}
+	return status, nil
+}
+
+// GetOutspend 实现 btc.WatchSource
+func (s *EsploraSource) GetOutspend(outpoint wire.OutPoint) (string, error) {
+	var outspend esploraOutspend
+	if err := esploraGet(fmt.Sprintf("%s/tx/%s/outspend/%d", s.baseUrl, outpoint.Hash.String(), outpoint.Index), &outspend); err != nil {
+		return "", log.WithError(err, "get esplora outspend failed")
+	}
+	if !outspend.Spent {
+		return "", nil
+	}
+	return outspend.TxId, nil
+}
+
+// AddressTxIds 实现 btc.WatchSource, 包含内存池中的交易和最近 25 笔已确认交易
+func (s *EsploraSource) AddressTxIds(address btcutil.Address) ([]string, error) {
+	var txs []EsploraTx
+	if err := esploraGet(fmt.Sprintf("%s/address/%s/txs", s.baseUrl, address.EncodeAddress()), &txs); err != nil {
+		return nil, log.WithError(err, "get esplora history failed")
+	}
+	txIds := make([]string, 0, len(txs))
+	for i := range txs {
+		txIds = append(txIds, txs[i].TxId)
+	}
+	return txIds, nil
+}
+
+// esploraMerkleProof /tx/:txid/merkle-proof 返回的 Electrum 格式证明
+type esploraMerkleProof struct {
+	BlockHeight int64    `json:"block_height"`
+	Merkle      []string `json:"merkle"`
+	Pos         int      `json:"pos"`
+}
+
+// GetMerkleProof 实现 btc.ProofSource, 证明按高度与本地区块头比对
+func (s *EsploraSource) GetMerkleProof(txId string) (*btc.MerkleProof, error) {
+	hash, err := chainhash.NewHashFromStr(txId)
+	if err != nil {
+		return nil, log.WithError(err, "NewHashFromStr failed")
+	}
+	var proof esploraMerkleProof
+	if err = esploraGet(fmt.Sprintf("%s/tx/%s/merkle-proof", s.baseUrl, txId), &proof); err != nil {
+		return nil, log.WithError(err, "get esplora merkle proof failed")
+	}
+	branch := make([]chainhash.Hash, 0, len(proof.Merkle))
+	for _, node := range proof.Merkle {
+		h, err := chainhash.NewHashFromStr(node)
+		if err != nil {
+			return nil, log.WithError(utils.ErrInvalidProof, err.Error())
+		}
+		branch = append(branch, *h)
+	}
+	return btc.NewMerkleBranchProof(*hash, branch, proof.Pos, proof.BlockHeight)
+}
@@ -0,0 +1,257 @@
+package balance
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/btc"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newEsploraTestTx 构造一笔交易及其 Esplora 格式的数据
+func newEsploraTestTx(status EsploraStatus) (*wire.MsgTx, []*wire.TxOut, *EsploraTx) {
+	params := &chaincfg.TestNet3Params
+	from, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", params)
+	fromScript, _ := txscript.PayToAddrScript(from)
+	toScript, _ := txscript.PayToAddrScript(to)
+
+	prevHash, _ := chainhash.NewHashFromStr("348674e4e971f2a5222c6e8bab966ae1d735bf22aa47f7b706f7bcd6f2317b84")
+	tx := wire.NewMsgTx(wire.TxVersion)
+	in := wire.NewTxIn(wire.NewOutPoint(prevHash, 0), nil, nil)
+	in.Witness = wire.TxWitness{make([]byte, 72), make([]byte, 33)}
+	tx.AddTxIn(in)
+	tx.AddTxOut(wire.NewTxOut(60000, toScript))
+	tx.AddTxOut(wire.NewTxOut(38000, fromScript))
+	prevOuts := []*wire.TxOut{wire.NewTxOut(100000, fromScript)}
+
+	esploraTx := &EsploraTx{
+		TxId:   tx.TxHash().String(),
+		Weight: blockchain.GetTransactionWeight(btcutil.NewTx(tx)),
+		Fee:    2000,
+		Status: status,
+	}
+	esploraTx.Vin = append(esploraTx.Vin, EsploraTxIn{
+		TxId:    prevHash.String(),
+		Vout:    0,
+		Prevout: &EsploraTxOut{ScriptPubKey: hex.EncodeToString(fromScript), Value: 100000},
+	})
+	for _, out := range tx.TxOut {
+		esploraTx.Vout = append(esploraTx.Vout, EsploraTxOut{ScriptPubKey: hex.EncodeToString(out.PkScript), Value: out.Value})
+	}
+	return tx, prevOuts, esploraTx
+}
+
+func newEsploraServer(t *testing.T, tx *EsploraTx, tipHeight string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tx/"+tx.TxId, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(tx)
+	})
+	mux.HandleFunc("/blocks/tip/height", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tipHeight))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Transaction not found"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestEsploraSource_GetRawTransaction(t *testing.T) {
+	status := EsploraStatus{
+		Confirmed:   true,
+		BlockHeight: 2504192,
+		BlockHash:   "00000000000000122988c78057b5739633c1e71e31299ab1dbe0857a93bc3319",
+		BlockTime:   1695177573,
+	}
+	tx, prevOuts, esploraTx := newEsploraTestTx(status)
+	server := newEsploraServer(t, esploraTx, "2504198\n")
+
+	source, err := NewEsploraSource(utils.BtcChainTestNet3, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := btc.NewChain().SetTxSource(source)
+	detail, err := chain.FetchTransactionDetail(esploraTx.TxId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 与节点 RPC 的结果一致
+	block := &btc.RawBlock{Hash: status.BlockHash, Height: status.BlockHeight, Time: status.BlockTime, Confirmations: 7}
+	want := btc.NewTransactionDetail(btc.NewRawTransaction(tx, prevOuts, block), &chaincfg.TestNet3Params)
+	assert.Equal(t, want, detail)
+
+	assert.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	assert.Equal(t, uint64(7), detail.Confirmations)
+	assert.Equal(t, int64(esploraTx.Fee), detail.GasFee.Int64())
+	assert.Equal(t, "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", detail.Inputs[0].Address)
+}
+
+func TestEsploraSource_Unconfirmed(t *testing.T) {
+	_, _, esploraTx := newEsploraTestTx(EsploraStatus{})
+	server := newEsploraServer(t, esploraTx, "invalid")
+
+	source, _ := NewEsploraSource(utils.BtcChainTestNet3, server.URL)
+	detail, err := btc.NewChain().SetTxSource(source).FetchTransactionDetail(esploraTx.TxId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, base.TransactionStatusPending, detail.Status)
+	assert.Nil(t, detail.BlockNumber)
+	assert.Equal(t, uint64(0), detail.Confirmations)
+}
+
+func TestEsploraSource_NotFound(t *testing.T) {
+	_, _, esploraTx := newEsploraTestTx(EsploraStatus{})
+	server := newEsploraServer(t, esploraTx, "1")
+
+	source, _ := NewEsploraSource(utils.BtcChainTestNet3, server.URL)
+	_, err := btc.NewChain().SetTxSource(source).FetchTransactionDetail("604520d6133dbacc55d15ea76d42797e88a0cc384153d3eb6524da90dbcc33f6")
+	assert.ErrorContains(t, err, "Transaction not found")
+}
+
+func TestEsploraSource_GetHistory(t *testing.T) {
+	const address = "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732"
+	_, _, pending := newEsploraTestTx(EsploraStatus{})
+	_, _, confirmed := newEsploraTestTx(EsploraStatus{Confirmed: true, BlockHeight: 100, BlockHash: "00000000000000122988c78057b5739633c1e71e31299ab1dbe0857a93bc3319", BlockTime: 1695177573})
+	// 第一页: 一笔未确认交易和满页的已确认交易
+	firstPage := []*EsploraTx{pending}
+	for i := 0; i < esploraChainPageSize; i++ {
+		firstPage = append(firstPage, confirmed)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/address/"+address+"/txs", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(firstPage)
+	})
+	mux.HandleFunc("/address/"+address+"/txs/chain/"+confirmed.TxId, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]*EsploraTx{confirmed})
+	})
+	mux.HandleFunc("/blocks/tip/height", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("109"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	source, _ := NewEsploraSource(utils.BtcChainTestNet3, server.URL)
+	addr, _ := btcutil.DecodeAddress(address, &chaincfg.TestNet3Params)
+	page, err := source.GetHistory(addr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, page.Transactions, esploraChainPageSize+1)
+	assert.Equal(t, confirmed.TxId, page.NextCursor)
+	assert.Equal(t, base.TransactionStatusPending, page.Transactions[0].Status)
+	assert.Equal(t, uint64(10), page.Transactions[1].Confirmations)
+	// 输入 100000, 找零 38000
+	assert.Equal(t, int64(-62000), page.Transactions[0].NetValue.Int64())
+
+	page, err = source.GetHistory(addr, page.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, page.Transactions, 1)
+	assert.Empty(t, page.NextCursor)
+
+	// 对接收方为转入
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", &chaincfg.TestNet3Params)
+	assert.Equal(t, int64(60000), btc.SetNetValue(page.Transactions[0], to.EncodeAddress()).Int64())
+
+	_, err = source.GetHistory(addr, "invalid")
+	assert.Error(t, err)
+}
+
+func TestEsploraSource_WatchSource(t *testing.T) {
+	status := EsploraStatus{Confirmed: true, BlockHeight: 2504192, BlockHash: "00000000000000122988c78057b5739633c1e71e31299ab1dbe0857a93bc3319"}
+	tx, _, esploraTx := newEsploraTestTx(status)
+	server := newEsploraServer(t, esploraTx, "2504198")
+	source, _ := NewEsploraSource(utils.BtcChainTestNet3, server.URL)
+
+	var _ btc.WatchSource = source
+	txStatus, err := source.GetTxStatus(esploraTx.TxId)
+	assert.NoError(t, err)
+	assert.Equal(t, &btc.TxStatus{
+		Found:       true,
+		Confirmed:   true,
+		BlockHeight: status.BlockHeight,
+		BlockHash:   status.BlockHash,
+		Inputs:      []wire.OutPoint{tx.TxIn[0].PreviousOutPoint},
+	}, txStatus)
+
+	txStatus, err = source.GetTxStatus(chainhash.HashH([]byte("missing")).String())
+	assert.NoError(t, err)
+	assert.False(t, txStatus.Found)
+}
+
+func TestEsploraSource_GetOutspend(t *testing.T) {
+	prevHash := chainhash.HashH([]byte("prev"))
+	spender := chainhash.HashH([]byte("spender")).String()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tx/"+prevHash.String()+"/outspend/0", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"spent":true,"txid":"` + spender + `","vin":0,"status":{"confirmed":false}}`))
+	})
+	mux.HandleFunc("/tx/"+prevHash.String()+"/outspend/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"spent":false}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	source, _ := NewEsploraSource(utils.BtcChainTestNet3, server.URL)
+
+	txId, err := source.GetOutspend(wire.OutPoint{Hash: prevHash, Index: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, spender, txId)
+	txId, err = source.GetOutspend(wire.OutPoint{Hash: prevHash, Index: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, txId)
+}
+
+func TestEsploraSource_GetMerkleProof(t *testing.T) {
+	leaves := []chainhash.Hash{chainhash.HashH([]byte("a")), chainhash.HashH([]byte("b")), chainhash.HashH([]byte("c"))}
+	left := blockchain.HashMerkleBranches(&leaves[0], &leaves[1])
+	right := blockchain.HashMerkleBranches(&leaves[2], &leaves[2])
+	root := blockchain.HashMerkleBranches(left, right)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tx/"+leaves[2].String()+"/merkle-proof", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"block_height":2504192,"merkle":["` + leaves[2].String() + `","` + left.String() + `"],"pos":2}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	source, _ := NewEsploraSource(utils.BtcChainTestNet3, server.URL)
+
+	var _ btc.ProofSource = source
+	proof, err := source.GetMerkleProof(leaves[2].String())
+	assert.NoError(t, err)
+	assert.Equal(t, *root, proof.MerkleRoot)
+	assert.Equal(t, int64(2504192), proof.BlockHeight)
+
+	_, err = source.GetMerkleProof(leaves[0].String())
+	assert.Error(t, err)
+}
+
+func TestNewEsploraSource_DefaultUrls(t *testing.T) {
+	for chainId, url := range map[int]string{
+		utils.BtcChainTestNet4: "https://mempool.space/testnet4/api",
+		utils.BtcChainSigNet:   "https://mempool.space/signet/api",
+	} {
+		source, err := NewEsploraSource(chainId, "")
+		assert.NoError(t, err)
+		assert.Equal(t, url, source.BaseUrl())
+		assert.Equal(t, chainId, int(source.ChainParams().Net))
+	}
+	_, err := NewEsploraSource(utils.BtcChainRegtest, "")
+	assert.Equal(t, utils.ErrInvalidURL.ErrCode, err.(*utils.Error).ErrCode)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/rpcclient"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/pkg/errors"
@@ -14,10 +15,18 @@ import (
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
 	"math"
+	"math/big"
 	"sort"
 	"strconv"
+	"strings"
 )
 
+// OkLinkUrl OKLink 接口地址
+var OkLinkUrl = "https://www.oklink.com"
+
+// okLinkHistoryPageSize 交易历史每页的数量
+const okLinkHistoryPageSize = 20
+
 type OkLinkToken struct {
 	btc.Coin
 	Info  *base.TokenInfo
@@ -83,7 +92,7 @@ func (t *OkLinkToken) TokenInfo() (*base.TokenInfo, error) {
 	return t.Info, nil
 }
 
-// GetBtcUnspent 获取未花费的比特币交易输出
+// GetBtcUnspent 获取未花费的比特币交易输出, 按页查询直到金额足够, amount 为 0 时查询全部
 func (t *OkLinkToken) GetBtcUnspent(current btcutil.Address, amount uint64) ([]btc.BtcUnspent, error) {
 	data := make([]btc.BtcUnspent, 0)
 	script, err := txscript.PayToAddrScript(current)
@@ -92,41 +101,40 @@ func (t *OkLinkToken) GetBtcUnspent(current btcutil.Address, amount uint64) ([]b
 	}
 	scriptStr := fmt.Sprintf("%x", script)
 	total := uint64(0)
-	index := 1
-	for total < amount {
+	for index := 1; amount == 0 || total < amount; index++ {
 		log.Infof("start begin %d", index)
 		unSpent, er := getUnSpentTxByMain(current, index)
 		if er != nil {
 			return nil, er
 		}
-		if len(unSpent.Data) > 0 {
-			list := unSpent.Data[0].UTXOList
-			if len(list) == 0 {
-				break
+		if len(unSpent.Data) == 0 || len(unSpent.Data[0].UTXOList) == 0 {
+			break
+		}
+		list := unSpent.Data[0].UTXOList
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].UnspentAmount < list[j].UnspentAmount
+		})
+		for i := range list {
+			item := list[i]
+			vout, err := strconv.ParseUint(item.Index, 10, 32)
+			if err != nil {
+
+				return nil, err
 			}
-			sort.Slice(list, func(i, j int) bool {
-				return list[i].UnspentAmount < list[j].UnspentAmount
-			})
-			for i := range list {
-				item := list[i]
-				vout, err := strconv.ParseUint(item.Index, 10, 32)
-				if err != nil {
-
-					return nil, err
-				}
-				amountValue, err := strconv.ParseFloat(item.UnspentAmount, 64)
-				if err != nil {
-					return nil, err
-				}
-				newAmount, err := btcutil.NewAmount(amountValue)
-				if err != nil {
-					return nil, err
-				}
-				unspent := btc.BtcUnspent{TxID: item.TxId, Vout: uint32(vout), ScriptPubKey: scriptStr, Amount: amountValue, Value: uint64(newAmount.ToUnit(btcutil.AmountSatoshi))}
-				data = append(data, unspent)
-				total += unspent.Value
+			amountValue, err := strconv.ParseFloat(item.UnspentAmount, 64)
+			if err != nil {
+				return nil, err
 			}
-			index++
+			newAmount, err := btcutil.NewAmount(amountValue)
+			if err != nil {
+				return nil, err
+			}
+			unspent := btc.BtcUnspent{TxID: item.TxId, Vout: uint32(vout), ScriptPubKey: scriptStr, Amount: amountValue, Value: uint64(newAmount.ToUnit(btcutil.AmountSatoshi))}
+			data = append(data, unspent)
+			total += unspent.Value
+		}
+		if totalPage, _ := strconv.Atoi(unSpent.Data[0].TotalPage); index >= totalPage {
+			break
 		}
 	}
 	return data, err
@@ -134,7 +142,7 @@ func (t *OkLinkToken) GetBtcUnspent(current btcutil.Address, amount uint64) ([]b
 
 // getUnSpentTxByMain 获取未花费的比特币交易输出
 func getUnSpentTxByMain(current btcutil.Address, page int) (MainOKLinkUTXO, error) {
-	url := fmt.Sprintf("https://www.oklink.com/api/v5/explorer/address/utxo?chainShortName=BTC&address=%s&page=%s&limit=20", current.String(), strconv.Itoa(page))
+	url := fmt.Sprintf("%s/api/v5/explorer/address/utxo?chainShortName=BTC&address=%s&page=%s&limit=20", OkLinkUrl, current.String(), strconv.Itoa(page))
 	var data MainOKLinkUTXO
 	request, err := utils.DoGetAndHeader(url, 3, map[string]string{"Ok-Access-Key": sdk_struct.GetOkLinkApiKey()})
 	if err != nil {
@@ -150,7 +158,7 @@ func getUnSpentTxByMain(current btcutil.Address, page int) (MainOKLinkUTXO, erro
 
 // GetBalance 获取余额
 func (t *OkLinkToken) GetBalance(address btcutil.Address) (*base.Balance, error) {
-	url := fmt.Sprintf("https://www.oklink.com/api/v5/explorer/address/address-summary?chainShortName=BTC&address=%s", address.String())
+	url := fmt.Sprintf("%s/api/v5/explorer/address/address-summary?chainShortName=BTC&address=%s", OkLinkUrl, address.String())
 	request, err := utils.DoGetAndHeader(url, 3, map[string]string{"Ok-Access-Key": sdk_struct.GetOkLinkApiKey()})
 	if err != nil {
 		return base.EmptyBalance(), err
@@ -181,17 +189,22 @@ func (t *OkLinkToken) PushTx(signedTx string, transaction *btc.Transaction) (str
 		EnableBCInfoHacks: true,
 	}, nil)
 
-	send, err := transaction.SendRawTransaction(client)
+	send, err := transaction.SendRawTransaction(client, t.ChainParams())
 	if err != nil {
 		return "", err
 	}
 	return send.String(), nil
 }
 
+// ChainParams 实现 btc.NetworkReporter, 接口查询的均为 BTC 主网
+func (t *OkLinkToken) ChainParams() *chaincfg.Params {
+	return &chaincfg.MainNetParams
+}
+
 // GetGasFee 获取推荐的矿工费
 func (t *OkLinkToken) GetGasFee() (uint64, error) {
 	var data MainOKLinkFee
-	request, err := utils.DoGetAndHeader("https://www.oklink.com/api/v5/explorer/blockchain/fee?chainShortName=BTC",
+	request, err := utils.DoGetAndHeader(OkLinkUrl+"/api/v5/explorer/blockchain/fee?chainShortName=BTC",
 		3, map[string]string{"Ok-Access-Key": sdk_struct.GetOkLinkApiKey()})
 
 	if err = json.Unmarshal(request, &data); err != nil {
@@ -213,3 +226,211 @@ func (t *OkLinkToken) GetGasFee() (uint64, error) {
 		return 0, errors.New(data.Msg)
 	}
 }
+
+// MainOKLinkTxList 地址交易列表
+type MainOKLinkTxList struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Page             string `json:"page"`
+		Limit            string `json:"limit"`
+		TotalPage        string `json:"totalPage"`
+		TransactionLists []struct {
+			TxId      string `json:"txId"`
+			BlockHash string `json:"blockHash"`
+			Height    string `json:"height"`
+		} `json:"transactionLists"`
+	} `json:"data"`
+}
+
+// MainOKLinkTxFills 交易详情
+type MainOKLinkTxFills struct {
+	Code string         `json:"code"`
+	Msg  string         `json:"msg"`
+	Data []OKLinkTxFill `json:"data"`
+}
+
+// OKLinkTxFill 交易的输入输出明细, 金额单位为 BTC
+type OKLinkTxFill struct {
+	TxId            string `json:"txid"`
+	Height          string `json:"height"`
+	TransactionTime string `json:"transactionTime"` // 毫秒
+	TxFee           string `json:"txfee"`
+	Confirm         string `json:"confirm"`
+	VirtualSize     string `json:"virtualSize"`
+	InputDetails    []struct {
+		InputHash string `json:"inputHash"`
+		Amount    string `json:"amount"`
+	} `json:"inputDetails"`
+	OutputDetails []struct {
+		OutputHash string `json:"outputHash"`
+		Amount     string `json:"amount"`
+	} `json:"outputDetails"`
+}
+
+// GetHistory 获取地址的交易历史, 第一页包含未确认的交易, 之后按区块倒序翻页
+// cursor 为上一页最后一笔已确认交易的 "高度:txid", 新交易到来时不会使翻页错位
+// 列表接口不含输入输出明细, 一页的交易用一次 transaction-fills 批量查询
+func (t *OkLinkToken) GetHistory(address btcutil.Address, cursor string) (*btc.HistoryPage, error) {
+	url := fmt.Sprintf("%s/api/v5/explorer/address/transaction-list?chainShortName=BTC&address=%s&limit=%d",
+		OkLinkUrl, address.EncodeAddress(), okLinkHistoryPageSize)
+	var lastHeight, lastTxId string
+	if cursor != "" {
+		var ok bool
+		lastHeight, lastTxId, ok = strings.Cut(cursor, ":")
+		if height, err := strconv.ParseUint(lastHeight, 10, 64); !ok || err != nil || height == 0 {
+			return nil, log.WithError(utils.ErrInvalidValue, "invalid cursor")
+		}
+		if _, err := chainhash.NewHashFromStr(lastTxId); err != nil {
+			return nil, log.WithError(utils.ErrInvalidValue, "invalid cursor")
+		}
+		url += "&endBlockHeight=" + lastHeight
+	}
+	var list MainOKLinkTxList
+	if err := okLinkGet(url, &list); err != nil {
+		return nil, log.WithError(err, "get oklink history failed")
+	}
+
+	result := &btc.HistoryPage{}
+	if len(list.Data) == 0 {
+		return result, nil
+	}
+	items := list.Data[0].TransactionLists
+	if cursor != "" {
+		// 同一区块的交易可能跨页, 跳过上一页已经返回的部分, 未确认的交易只在第一页返回
+		for i, item := range items {
+			if item.TxId == lastTxId {
+				items = items[i+1:]
+				break
+			}
+		}
+		confirmed := items[:0]
+		for _, item := range items {
+			if item.Height != "" {
+				confirmed = append(confirmed, item)
+			}
+		}
+		items = confirmed
+	}
+	txIds := make([]string, 0, len(items))
+	for _, item := range items {
+		txIds = append(txIds, item.TxId)
+	}
+	if len(txIds) == 0 {
+		return result, nil
+	}
+
+	var fills MainOKLinkTxFills
+	url = fmt.Sprintf("%s/api/v5/explorer/transaction/transaction-fills?chainShortName=BTC&txid=%s", OkLinkUrl, strings.Join(txIds, ","))
+	if err := okLinkGet(url, &fills); err != nil {
+		return nil, log.WithError(err, "get oklink transaction failed")
+	}
+	fillByTxId := make(map[string]*OKLinkTxFill, len(fills.Data))
+	for i := range fills.Data {
+		fillByTxId[fills.Data[i].TxId] = &fills.Data[i]
+	}
+	for _, item := range items {
+		fill, ok := fillByTxId[item.TxId]
+		if !ok {
+			return nil, log.WithError(utils.TransactionHashError, "transaction not found "+item.TxId)
+		}
+		detail, err := fill.TransactionDetail()
+		if err != nil {
+			return nil, log.WithError(err, "TransactionDetail failed")
+		}
+		if detail.BlockNumber != nil {
+			detail.BlockHash = item.BlockHash
+			if totalPage, _ := strconv.Atoi(list.Data[0].TotalPage); totalPage > 1 {
+				result.NextCursor = item.Height + ":" + item.TxId
+			}
+		}
+		btc.SetNetValue(detail, address.EncodeAddress())
+		result.Transactions = append(result.Transactions, detail)
+	}
+	return result, nil
+}
+
+// TransactionDetail 转换为统一的交易明细, 金额转换为聪
+func (f *OKLinkTxFill) TransactionDetail() (*base.TransactionDetail, error) {
+	detail := &base.TransactionDetail{
+		Hash:   f.TxId,
+		Status: base.TransactionStatusPending,
+	}
+	if ms, err := strconv.ParseInt(f.TransactionTime, 10, 64); err == nil {
+		detail.Time = ms / 1000
+	}
+
+	totalOut := new(big.Int)
+	for _, in := range f.InputDetails {
+		value, err := okLinkSatoshi(in.Amount)
+		if err != nil {
+			return nil, err
+		}
+		detail.Inputs = append(detail.Inputs, &base.TransactionInput{Address: in.InputHash, Value: value})
+	}
+	for i, out := range f.OutputDetails {
+		value, err := okLinkSatoshi(out.Amount)
+		if err != nil {
+			return nil, err
+		}
+		detail.Outputs = append(detail.Outputs, &base.TransactionOutput{Index: uint32(i), Address: out.OutputHash, Value: value})
+		totalOut.Add(totalOut, value)
+	}
+	if len(detail.Inputs) > 0 {
+		detail.Form = detail.Inputs[0].Address
+	}
+	if len(detail.Outputs) > 0 {
+		detail.To = detail.Outputs[0].Address
+	}
+	detail.Value = totalOut
+	detail.Amount = utils.NewOptAmount(totalOut.String(), 8).AmountString()
+
+	fee, err := okLinkSatoshi(f.TxFee)
+	if err != nil {
+		return nil, err
+	}
+	detail.GasFee = fee
+	if vsize, err := strconv.ParseUint(f.VirtualSize, 10, 64); err == nil && vsize > 0 {
+		detail.GasUsed = vsize
+		// 保留两位小数
+		detail.FeeRate = math.Round(float64(fee.Int64())/float64(vsize)*100) / 100
+	}
+
+	if height, err := strconv.ParseInt(f.Height, 10, 64); err == nil && height > 0 {
+		detail.Status = base.TransactionStatusSuccess
+		detail.BlockNumber = big.NewInt(height)
+		detail.Confirmations, _ = strconv.ParseUint(f.Confirm, 10, 64)
+	}
+	return detail, nil
+}
+
+// okLinkSatoshi 将 BTC 金额转换为聪
+func okLinkSatoshi(amount string) (*big.Int, error) {
+	if amount == "" {
+		return new(big.Int), nil
+	}
+	value, err := utils.ParseAmount(amount, 8)
+	if err != nil {
+		return nil, fmt.Errorf("oklink: invalid amount %s", amount)
+	}
+	return value.BigInt(), nil
+}
+
+// okLinkGet 请求 OKLink 接口, code 不为 0 时返回 msg
+func okLinkGet(url string, v interface{}) error {
+	data, err := utils.DoGetAndHeader(url, 3, map[string]string{"Ok-Access-Key": sdk_struct.GetOkLinkApiKey()})
+	if err != nil {
+		return err
+	}
+	var status struct {
+		Code string `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err = json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("oklink: %s", strings.TrimSpace(string(data)))
+	}
+	if status.Code != "0" {
+		return fmt.Errorf("oklink: %s", status.Msg)
+	}
+	return json.Unmarshal(data, v)
+}
@@ -3,7 +3,13 @@ package balance
 import (
 	"fmt"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -36,3 +42,125 @@ func TestOkLinkToken_GetBalance(t1 *testing.T) {
 		})
 	}
 }
+
+func TestOkLinkToken_GetHistory(t *testing.T) {
+	const address = "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732"
+	const firstPage = `{"code":"0","msg":"","data":[{"page":"1","limit":"20","totalPage":"2","transactionLists":[
+		{"txId":"aa00000000000000000000000000000000000000000000000000000000000001","blockHash":"","height":""},
+		{"txId":"aa00000000000000000000000000000000000000000000000000000000000002","blockHash":"00000000000000122988c78057b5739633c1e71e31299ab1dbe0857a93bc3319","height":"2504192"}]}]}`
+	// 按高度翻页时同一区块中已经返回过的交易会再次出现
+	const nextPage = `{"code":"0","msg":"","data":[{"page":"1","limit":"20","totalPage":"1","transactionLists":[
+		{"txId":"aa00000000000000000000000000000000000000000000000000000000000002","blockHash":"00000000000000122988c78057b5739633c1e71e31299ab1dbe0857a93bc3319","height":"2504192"},
+		{"txId":"aa00000000000000000000000000000000000000000000000000000000000003","blockHash":"00000000000000122988c78057b5739633c1e71e31299ab1dbe0857a93bc3319","height":"2504192"}]}]}`
+	fills := map[string]string{
+		"aa00000000000000000000000000000000000000000000000000000000000001": `{"txid":"aa00000000000000000000000000000000000000000000000000000000000001","height":"","transactionTime":"1695177573000","txfee":"0.00002","confirm":"0","virtualSize":"141",
+			"inputDetails":[{"inputHash":"tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732","amount":"0.001"}],
+			"outputDetails":[{"outputHash":"2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi","amount":"0.0006"},{"outputHash":"tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732","amount":"0.00038"}]}`,
+		"aa00000000000000000000000000000000000000000000000000000000000002": `{"txid":"aa00000000000000000000000000000000000000000000000000000000000002","height":"2504192","transactionTime":"1695177000000","txfee":"0.00001","confirm":"7","virtualSize":"110",
+			"inputDetails":[{"inputHash":"2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi","amount":"0.01001"}],
+			"outputDetails":[{"outputHash":"tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732","amount":"0.01"}]}`,
+		"aa00000000000000000000000000000000000000000000000000000000000003": `{"txid":"aa00000000000000000000000000000000000000000000000000000000000003","height":"2504192","transactionTime":"1695177000000","txfee":"0.00001","confirm":"7","virtualSize":"110",
+			"inputDetails":[{"inputHash":"2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi","amount":"0.00201"}],
+			"outputDetails":[{"outputHash":"tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732","amount":"0.002"}]}`,
+	}
+	fillRequests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v5/explorer/address/transaction-list", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, address, r.URL.Query().Get("address"))
+		switch r.URL.Query().Get("endBlockHeight") {
+		case "":
+			w.Write([]byte(firstPage))
+		case "2504192":
+			w.Write([]byte(nextPage))
+		default:
+			t.Errorf("unexpected endBlockHeight %s", r.URL.Query().Get("endBlockHeight"))
+		}
+	})
+	mux.HandleFunc("/api/v5/explorer/transaction/transaction-fills", func(w http.ResponseWriter, r *http.Request) {
+		fillRequests++
+		var data []string
+		for _, txId := range strings.Split(r.URL.Query().Get("txid"), ",") {
+			data = append(data, fills[txId])
+		}
+		fmt.Fprintf(w, `{"code":"0","msg":"","data":[%s]}`, strings.Join(data, ","))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defaultUrl := OkLinkUrl
+	OkLinkUrl = server.URL
+	defer func() { OkLinkUrl = defaultUrl }()
+
+	addr, _ := btcutil.DecodeAddress(address, &chaincfg.TestNet3Params)
+	page, err := (&OkLinkToken{}).GetHistory(addr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, fillRequests)
+	assert.Equal(t, "2504192:aa00000000000000000000000000000000000000000000000000000000000002", page.NextCursor)
+	if assert.Len(t, page.Transactions, 2) {
+		pending := page.Transactions[0]
+		assert.Equal(t, base.TransactionStatusPending, pending.Status)
+		assert.Equal(t, int64(-62000), pending.NetValue.Int64())
+		assert.Equal(t, int64(2000), pending.GasFee.Int64())
+		assert.Equal(t, 14.18, pending.FeeRate)
+		assert.Equal(t, int64(1695177573), pending.Time)
+
+		confirmed := page.Transactions[1]
+		assert.Equal(t, base.TransactionStatusSuccess, confirmed.Status)
+		assert.Equal(t, int64(1000000), confirmed.NetValue.Int64())
+		assert.Equal(t, uint64(7), confirmed.Confirmations)
+		assert.Equal(t, "00000000000000122988c78057b5739633c1e71e31299ab1dbe0857a93bc3319", confirmed.BlockHash)
+	}
+
+	page, err = (&OkLinkToken{}).GetHistory(addr, page.NextCursor)
+	if assert.NoError(t, err) {
+		assert.Empty(t, page.NextCursor)
+		if assert.Len(t, page.Transactions, 1) {
+			assert.Equal(t, "aa00000000000000000000000000000000000000000000000000000000000003", page.Transactions[0].Hash)
+			assert.Equal(t, int64(200000), page.Transactions[0].NetValue.Int64())
+		}
+	}
+	_, err = (&OkLinkToken{}).GetHistory(addr, "2")
+	assert.Error(t, err)
+	_, err = (&OkLinkToken{}).GetHistory(addr, "0:aa00000000000000000000000000000000000000000000000000000000000002")
+	assert.Error(t, err)
+}
+
+func TestOkLinkToken_GetBtcUnspent(t *testing.T) {
+	const address = "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732"
+	pages := map[string]string{
+		"1": `{"code":"0","msg":"","data":[{"page":"1","limit":"20","totalPage":"2","utxoList":[
+			{"txid":"aa00000000000000000000000000000000000000000000000000000000000001","unspentAmount":"0.001","index":"0"}]}]}`,
+		"2": `{"code":"0","msg":"","data":[{"page":"2","limit":"20","totalPage":"2","utxoList":[
+			{"txid":"aa00000000000000000000000000000000000000000000000000000000000002","unspentAmount":"0.002","index":"1"}]}]}`,
+	}
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v5/explorer/address/utxo", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, address, r.URL.Query().Get("address"))
+		w.Write([]byte(pages[r.URL.Query().Get("page")]))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defaultUrl := OkLinkUrl
+	OkLinkUrl = server.URL
+	defer func() { OkLinkUrl = defaultUrl }()
+
+	addr, _ := btcutil.DecodeAddress(address, &chaincfg.TestNet3Params)
+	// amount 为 0 时返回全部
+	unspents, err := (&OkLinkToken{}).GetBtcUnspent(addr, 0)
+	if assert.NoError(t, err) && assert.Len(t, unspents, 2) {
+		assert.Equal(t, uint64(100000), unspents[0].Value)
+		assert.Equal(t, uint32(1), unspents[1].Vout)
+	}
+	assert.Equal(t, 2, requests)
+
+	// 第一页的金额已经足够
+	requests = 0
+	unspents, err = (&OkLinkToken{}).GetBtcUnspent(addr, 50000)
+	if assert.NoError(t, err) {
+		assert.Len(t, unspents, 1)
+	}
+	assert.Equal(t, 1, requests)
+}
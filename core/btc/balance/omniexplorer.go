@@ -0,0 +1,81 @@
+package balance
+
+import (
+	"encoding/json"
+	"fmt"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OmniExplorerUrl OmniExplorer 默认接口地址, 只支持主网
+const OmniExplorerUrl = "https://api.omniexplorer.info"
+
+// OmniExplorerBalance OmniExplorer 返回的地址余额
+type OmniExplorerBalance struct {
+	Error   bool   `json:"error"`
+	Msg     string `json:"msg"`
+	Balance []struct {
+		Id         string `json:"id"`
+		Value      string `json:"value"` // 最小单位
+		Divisible  bool   `json:"divisible"`
+		PendingNeg string `json:"pendingneg"`
+	} `json:"balance"`
+}
+
+// OmniExplorerBackend 通过 OmniExplorer 查询 Omni 资产余额
+type OmniExplorerBackend struct {
+	baseUrl string
+}
+
+// NewOmniExplorerBackend baseUrl 为空时使用 OmniExplorerUrl
+func NewOmniExplorerBackend(baseUrl string) *OmniExplorerBackend {
+	if baseUrl == "" {
+		baseUrl = OmniExplorerUrl
+	}
+	return &OmniExplorerBackend{baseUrl: strings.TrimRight(baseUrl, "/")}
+}
+
+// GetOmniBalance 未持有该资产时返回零余额, 待确认的转出不计入可用余额
+func (b *OmniExplorerBackend) GetOmniBalance(address string, propertyId uint32) (*base.Balance, error) {
+	form := url.Values{"addr": {address}}
+	data, err := utils.DoPost(b.baseUrl+"/v1/address/addr/", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), 3)
+	if err != nil {
+		return nil, log.WithError(err, "DoPost failed")
+	}
+	var result OmniExplorerBalance
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, log.WithError(fmt.Errorf("omniexplorer: %s", strings.TrimSpace(string(data))), "Unmarshal failed")
+	}
+	if result.Error {
+		return nil, log.WithError(fmt.Errorf("omniexplorer: %s", result.Msg), "GetOmniBalance failed")
+	}
+
+	id := strconv.FormatUint(uint64(propertyId), 10)
+	for _, item := range result.Balance {
+		if item.Id != id {
+			continue
+		}
+		decimal := int16(0)
+		if item.Divisible {
+			decimal = 8
+		}
+		total, err := strconv.ParseInt(item.Value, 10, 64)
+		if err != nil {
+			return nil, log.WithError(err, "ParseInt failed")
+		}
+		usable := total
+		// pendingneg 为负数
+		if pending, err := strconv.ParseInt(item.PendingNeg, 10, 64); err == nil && pending < 0 {
+			usable += pending
+		}
+		return &base.Balance{
+			Total:  utils.NewOptAmount(strconv.FormatInt(total, 10), decimal),
+			Usable: utils.NewOptAmount(strconv.FormatInt(usable, 10), decimal),
+		}, nil
+	}
+	return base.EmptyBalance(), nil
+}
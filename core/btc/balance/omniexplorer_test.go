@@ -0,0 +1,36 @@
+package balance
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOmniExplorerBackend_GetOmniBalance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path != "/v1/address/addr/" || r.Form.Get("addr") != "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa" {
+			w.Write([]byte(`{"error":true,"msg":"invalid address"}`))
+			return
+		}
+		w.Write([]byte(`{"balance":[
+			{"id":"1","value":"12","divisible":true,"pendingneg":"0"},
+			{"id":"31","value":"250000000","divisible":true,"pendingneg":"-50000000"}
+		]}`))
+	}))
+	defer server.Close()
+	backend := NewOmniExplorerBackend(server.URL)
+
+	balance, err := backend.GetOmniBalance("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", 31)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.5", balance.Total.AmountString())
+	assert.Equal(t, "2", balance.Usable.AmountString())
+
+	balance, err = backend.GetOmniBalance("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "0", balance.Total.AmountString())
+
+	_, err = backend.GetOmniBalance("bad", 31)
+	assert.ErrorContains(t, err, "invalid address")
+}
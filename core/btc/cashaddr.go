@@ -0,0 +1,187 @@
+package btc
+
+import (
+	"errors"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"strings"
+)
+
+const (
+	// CashAddrMainNetPrefix Bitcoin Cash 主网地址前缀
+	CashAddrMainNetPrefix = "bitcoincash"
+	// CashAddrTestNetPrefix Bitcoin Cash 测试网地址前缀
+	CashAddrTestNetPrefix = "bchtest"
+
+	cashAddrCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	cashAddrTypeP2PKH = 0
+	cashAddrTypeP2SH  = 8
+	cashAddrLength    = 42
+)
+
+var cashAddrGenerator = [5]uint64{0x98f2bc8e61, 0x79b76d99e2, 0xf33e5fb3c4, 0xae2eabe2a8, 0x1e4f43e470}
+
+// CashAddrPrefix 链参数对应的 CashAddr 前缀, 非 BCH 链返回空
+func CashAddrPrefix(chain *chaincfg.Params) string {
+	switch chain.Net {
+	case utils.BchChainMainNet:
+		return CashAddrMainNetPrefix
+	case utils.BchChainTestNet3:
+		return CashAddrTestNetPrefix
+	}
+	return ""
+}
+
+// EncodeCashAddress 将 P2PKH/P2SH 地址编码为 CashAddr, 如 bitcoincash:qp...
+func EncodeCashAddress(addr btcutil.Address, chain *chaincfg.Params) (string, error) {
+	prefix := CashAddrPrefix(chain)
+	if prefix == "" {
+		return "", log.WithError(utils.ErrNotSupported, "not a bitcoin cash chain")
+	}
+	var version byte
+	switch addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		version = cashAddrTypeP2PKH
+	case *btcutil.AddressScriptHash:
+		version = cashAddrTypeP2SH
+	default:
+		return "", log.WithError(utils.ErrAddressTypeNotSupported, "EncodeCashAddress failed")
+	}
+	payload := convertBits(append([]byte{version}, addr.ScriptAddress()...), 8, 5, true)
+	checksum := cashAddrPolymod(prefix, append(payload, make([]byte, 8)...))
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	sb.WriteByte(':')
+	for _, b := range payload {
+		sb.WriteByte(cashAddrCharset[b])
+	}
+	for i := 0; i < 8; i++ {
+		sb.WriteByte(cashAddrCharset[(checksum>>uint(5*(7-i)))&0x1f])
+	}
+	return sb.String(), nil
+}
+
+// DecodeCashAddress 解析 CashAddr, 前缀可以省略, 返回与传统格式相同的地址对象
+func DecodeCashAddress(address string, chain *chaincfg.Params) (btcutil.Address, error) {
+	prefix := CashAddrPrefix(chain)
+	if prefix == "" {
+		return nil, log.WithError(utils.ErrNotSupported, "not a bitcoin cash chain")
+	}
+	if address != strings.ToLower(address) && address != strings.ToUpper(address) {
+		return nil, log.WithError(utils.ErrInvalidAddress, "mixed case cashaddr")
+	}
+	address = strings.ToLower(address)
+	if p, rest, ok := strings.Cut(address, ":"); ok {
+		if p != prefix {
+			return nil, log.WithError(utils.ErrAddressNetworkMismatch, "cashaddr prefix "+p)
+		}
+		address = rest
+	}
+	if len(address) <= 8 {
+		return nil, log.WithError(utils.ErrInvalidAddress, "cashaddr too short")
+	}
+
+	data := make([]byte, len(address))
+	for i := 0; i < len(address); i++ {
+		index := strings.IndexByte(cashAddrCharset, address[i])
+		if index < 0 {
+			return nil, log.WithError(utils.ErrInvalidAddress, "invalid cashaddr character")
+		}
+		data[i] = byte(index)
+	}
+	if cashAddrPolymod(prefix, data) != 0 {
+		return nil, log.WithError(utils.ErrAddressChecksum, "cashaddr checksum mismatch")
+	}
+	payload := convertBits(data[:len(data)-8], 5, 8, false)
+	if payload == nil || len(payload) != 21 {
+		return nil, log.WithError(utils.ErrInvalidAddress, "invalid cashaddr payload")
+	}
+
+	var (
+		addr btcutil.Address
+		err  error
+	)
+	switch payload[0] {
+	case cashAddrTypeP2PKH:
+		addr, err = btcutil.NewAddressPubKeyHash(payload[1:], chain)
+	case cashAddrTypeP2SH:
+		addr, err = btcutil.NewAddressScriptHashFromHash(payload[1:], chain)
+	default:
+		err = errors.New("unknown cashaddr version")
+	}
+	if err != nil {
+		return nil, log.WithError(utils.ErrInvalidAddress, err.Error())
+	}
+	return addr, nil
+}
+
+// DecodeAddress 按链解析地址, BCH 同时支持 CashAddr 和传统格式, 不接受 Litecoin MWEB 地址
+func DecodeAddress(address string, chain *chaincfg.Params) (btcutil.Address, error) {
+	address = strings.TrimSpace(address)
+	// 不带前缀的 160 位 CashAddr 固定为 42 个字符, 传统格式最长 35 个字符
+	if CashAddrPrefix(chain) != "" && (strings.Contains(address, ":") || len(address) == cashAddrLength) {
+		return DecodeCashAddress(address, chain)
+	}
+	if lower := strings.ToLower(address); strings.HasPrefix(lower, "ltcmweb1") || strings.HasPrefix(lower, "tmweb1") {
+		return nil, log.WithError(utils.ErrAddressTypeNotSupported, "MWEB address")
+	}
+	addr, err := btcutil.DecodeAddress(address, chain)
+	if err != nil {
+		return nil, log.WithError(utils.ErrInvalidAddress, err.Error())
+	}
+	if !addr.IsForNet(chain) {
+		return nil, log.WithError(utils.ErrAddressNetworkMismatch, "DecodeAddress failed")
+	}
+	return addr, nil
+}
+
+func cashAddrPolymod(prefix string, data []byte) uint64 {
+	c := uint64(1)
+	update := func(d byte) {
+		c0 := byte(c >> 35)
+		c = ((c & 0x07ffffffff) << 5) ^ uint64(d)
+		for i, g := range cashAddrGenerator {
+			if c0&(1<<uint(i)) != 0 {
+				c ^= g
+			}
+		}
+	}
+	for i := 0; i < len(prefix); i++ {
+		update(prefix[i] & 0x1f)
+	}
+	update(0)
+	for _, d := range data {
+		update(d)
+	}
+	return c ^ 1
+}
+
+// convertBits 在 8 位和 5 位分组之间转换, 不合法时返回 nil
+func convertBits(data []byte, from, to uint, pad bool) []byte {
+	var (
+		acc  uint
+		bits uint
+		out  []byte
+	)
+	maxV := uint(1)<<to - 1
+	for _, b := range data {
+		acc = acc<<from | uint(b)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&maxV))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(to-bits)&maxV))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxV != 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package btc
+
+import (
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"strings"
+	"testing"
+)
+
+func TestCashAddress(t *testing.T) {
+	tests := []struct {
+		legacy   string
+		cashAddr string
+	}{
+		{"1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{"1KXrWXciRDZUpQwQmuM1DbwsKDLYAYsVLR", "bitcoincash:qr95sy3j9xwd2ap32xkykttr4cvcu7as4y0qverfuy"},
+		{"3CWFddi6m4ndiGyKqzYvsFYagqDLPVMTzC", "bitcoincash:ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq"},
+	}
+	chain := &utils.BchMainNetParams
+	for _, tt := range tests {
+		legacy, err := btcutil.DecodeAddress(tt.legacy, &chaincfg.MainNetParams)
+		assert.NoError(t, err)
+		encoded, err := EncodeCashAddress(legacy, chain)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.cashAddr, encoded)
+
+		for _, s := range []string{tt.cashAddr, strings.ToUpper(tt.cashAddr), strings.TrimPrefix(tt.cashAddr, "bitcoincash:"), tt.legacy} {
+			addr, err := DecodeAddress(s, chain)
+			assert.NoError(t, err, s)
+			assert.Equal(t, tt.legacy, addr.EncodeAddress())
+		}
+	}
+}
+
+func TestDecodeCashAddress_Invalid(t *testing.T) {
+	chain := &utils.BchMainNetParams
+	_, err := DecodeCashAddress("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6c", chain)
+	assert.Equal(t, utils.ErrAddressChecksum.ErrCode, err.(*utils.Error).ErrCode)
+
+	_, err = DecodeCashAddress("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvY22gdx6a", chain)
+	assert.Equal(t, utils.ErrInvalidAddress.ErrCode, err.(*utils.Error).ErrCode)
+
+	_, err = DecodeCashAddress("bchtest:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", chain)
+	assert.Equal(t, utils.ErrAddressNetworkMismatch.ErrCode, err.(*utils.Error).ErrCode)
+
+	_, err = DecodeCashAddress("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", &chaincfg.MainNetParams)
+	assert.Equal(t, utils.ErrNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+}
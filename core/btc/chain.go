@@ -8,6 +8,10 @@ import (
 
 type Chain struct {
 	client *Client
+	source TxSource
+
+	proofSource ProofSource
+	headers     *HeaderStore
 }
 
 func NewChain() *Chain {
@@ -24,6 +28,31 @@ func (c *Chain) Client() (*Client, error) {
 	return c.client, nil
 }
 
+// SetTxSource 设置交易明细的数据来源, 未设置时使用 RPC 客户端
+func (c *Chain) SetTxSource(source TxSource) *Chain {
+	c.source = source
+	return c
+}
+
+// SetProofVerifier 设置后 FetchTransactionStatus 只有在默克尔证明与本地区块头一致时才返回成功
+func (c *Chain) SetProofVerifier(source ProofSource, headers *HeaderStore) *Chain {
+	c.proofSource = source
+	c.headers = headers
+	return c
+}
+
+// TxSource 获取交易明细的数据来源
+func (c *Chain) TxSource() (TxSource, error) {
+	if c.source != nil {
+		return c.source, nil
+	}
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	return NewRPCTxSource(client), nil
+}
+
 func (c *Chain) CreateRemoteClientWithTimeout(rpcUrl, user, pass string, chainId int) (*Chain, error) {
 	if c.client != nil && c.client.rpcUrl == rpcUrl {
 		return c, nil
@@ -39,5 +68,13 @@ func (c *Chain) CreateRemoteClientWithTimeout(rpcUrl, user, pass string, chainId
 }
 
 func (c *Chain) MainToken() base.Token {
-	return &Token{chain: c}
+	return NewToken(c)
+}
+
+// coin 链的币种, 未连接客户端时为 BTC
+func (c *Chain) coin() Coin {
+	if c.client == nil {
+		return Coin{}
+	}
+	return NewCoin(c.client.ChainParams())
 }
@@ -1,116 +1,335 @@
 package btc
 
 import (
+	"bytes"
+	"encoding/hex"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"math"
+	"math/big"
 )
 
-func (c *Chain) FetchTransactionDetail(txHash string) (*base.TransactionDetail, error) {
-	hash, err := chainhash.NewHashFromStr(txHash)
+// TxSource 交易明细的数据来源, 如节点 RPC、Esplora
+type TxSource interface {
+	// GetRawTransaction 获取交易, 需要同时解析输入引用的输出
+	GetRawTransaction(hash *chainhash.Hash) (*RawTransaction, error)
+	// ChainParams 链参数, 用于解析地址
+	ChainParams() *chaincfg.Params
+}
+
+// TxStatusSource 只查询交易状态的数据来源, FetchTransactionStatus 优先使用, 避免查询引用的交易
+type TxStatusSource interface {
+	// GetTxStatus 查询交易状态, 交易不存在时 Found 为 false
+	GetTxStatus(txId string) (*TxStatus, error)
+}
+
+// RawTransaction 由数据来源填充的交易数据, 用于生成统一的交易明细
+type RawTransaction struct {
+	Hash    string
+	Inputs  []*RawTxInput
+	Outputs []*wire.TxOut
+	VSize   int64
+	Time    int64     // 交易时间, 未确认时可能为0
+	Block   *RawBlock // 为空表示未确认
+}
+
+// RawTxInput 交易输入及其引用的输出
+type RawTxInput struct {
+	PrevOut  wire.OutPoint
+	Coinbase bool
+	TxOut    *wire.TxOut // 引用的输出, coinbase 输入为空
+}
+
+// RawBlock 交易所在区块
+type RawBlock struct {
+	Hash          string
+	Height        int64
+	Time          int64
+	Confirmations uint64
+}
+
+// NewRawTransaction 由原始交易和引用的输出生成 RawTransaction, prevOuts 与输入一一对应
+func NewRawTransaction(tx *wire.MsgTx, prevOuts []*wire.TxOut, block *RawBlock) *RawTransaction {
+	coinbase := blockchain.IsCoinBaseTx(tx)
+	inputs := make([]*RawTxInput, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		input := &RawTxInput{PrevOut: in.PreviousOutPoint, Coinbase: coinbase}
+		if !coinbase && i < len(prevOuts) {
+			input.TxOut = prevOuts[i]
+		}
+		inputs[i] = input
+	}
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	raw := &RawTransaction{
+		Hash:    tx.TxHash().String(),
+		Inputs:  inputs,
+		Outputs: tx.TxOut,
+		VSize:   (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor,
+		Block:   block,
+	}
+	if block != nil {
+		raw.Time = block.Time
+	}
+	return raw
+}
+
+// NewTransactionDetail 生成交易明细, 计算手续费和费率
+func NewTransactionDetail(raw *RawTransaction, params *chaincfg.Params) *base.TransactionDetail {
+	detail := &base.TransactionDetail{
+		Hash:   raw.Hash,
+		Time:   raw.Time,
+		Status: base.TransactionStatusPending,
+	}
+
+	feeKnown := true
+	totalIn := int64(0)
+	for _, in := range raw.Inputs {
+		input := &base.TransactionInput{
+			TxId: in.PrevOut.Hash.String(),
+			Vout: in.PrevOut.Index,
+		}
+		if in.Coinbase {
+			feeKnown = false
+			input.TxId = ""
+		} else if in.TxOut == nil {
+			feeKnown = false
+		} else {
+			input.Address = scriptAddress(in.TxOut.PkScript, params)
+			input.Value = big.NewInt(in.TxOut.Value)
+			totalIn += in.TxOut.Value
+		}
+		detail.Inputs = append(detail.Inputs, input)
+	}
+
+	totalOut := int64(0)
+	for i, out := range raw.Outputs {
+		detail.Outputs = append(detail.Outputs, &base.TransactionOutput{
+			Index:      uint32(i),
+			Address:    scriptAddress(out.PkScript, params),
+			ScriptType: txscript.GetScriptClass(out.PkScript).String(),
+			Value:      big.NewInt(out.Value),
+		})
+		totalOut += out.Value
+		if detail.To == "" {
+			detail.To = detail.Outputs[i].Address
+		}
+	}
+	if len(detail.Inputs) > 0 {
+		detail.Form = detail.Inputs[0].Address
+	}
+
+	detail.Value = big.NewInt(totalOut)
+	detail.Amount = utils.NewOptAmount(detail.Value.String(), 8).AmountString()
+	detail.GasUsed = uint64(raw.VSize)
+	if feeKnown && len(raw.Inputs) > 0 {
+		fee := totalIn - totalOut
+		detail.GasFee = big.NewInt(fee)
+		if raw.VSize > 0 {
+			// 保留两位小数
+			detail.FeeRate = math.Round(float64(fee)/float64(raw.VSize)*100) / 100
+		}
+	}
+
+	if raw.Block != nil {
+		detail.Status = base.TransactionStatusSuccess
+		detail.BlockHash = raw.Block.Hash
+		detail.BlockNumber = big.NewInt(raw.Block.Height)
+		detail.Confirmations = raw.Block.Confirmations
+		if raw.Block.Time > 0 {
+			detail.Time = raw.Block.Time
+		}
+	}
+	return detail
+}
+
+// scriptAddress 解析脚本对应的地址, 无法解析时返回空
+func scriptAddress(pkScript []byte, params *chaincfg.Params) string {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, params)
+	if err != nil || len(addrs) == 0 {
+		return ""
+	}
+	return addrs[0].EncodeAddress()
+}
+
+// RPCTxSource 通过节点 RPC 获取交易
+type RPCTxSource struct {
+	client *Client
+}
+
+func NewRPCTxSource(client *Client) *RPCTxSource {
+	return &RPCTxSource{client: client}
+}
+
+func (s *RPCTxSource) ChainParams() *chaincfg.Params {
+	return s.client.ChainParams()
+}
+
+// GetTxStatus 实现 TxStatusSource, 只调用一次 getrawtransaction, BlockHeight 为 0
+// 交易不存在时节点返回错误
+func (s *RPCTxSource) GetTxStatus(txId string) (*TxStatus, error) {
+	hash, err := chainhash.NewHashFromStr(txId)
 	if err != nil {
 		return nil, log.WithError(err, "NewHashFromStr failed")
 	}
+	rawResult, err := s.client.RPCClient().GetRawTransactionVerbose(hash)
+	if err != nil {
+		return nil, log.WithError(err, "GetRawTransactionVerbose failed")
+	}
+	status := &TxStatus{
+		Found:     true,
+		Confirmed: rawResult.BlockHash != "" && rawResult.Confirmations > 0,
+		BlockHash: rawResult.BlockHash,
+	}
+	for _, vin := range rawResult.Vin {
+		if vin.IsCoinBase() {
+			continue
+		}
+		prevHash, err := chainhash.NewHashFromStr(vin.Txid)
+		if err != nil {
+			return nil, log.WithError(err, "NewHashFromStr failed")
+		}
+		status.Inputs = append(status.Inputs, wire.OutPoint{Hash: *prevHash, Index: vin.Vout})
+	}
+	return status, nil
+}
 
-	rawResult, err := c.client.rpcClient.GetRawTransactionVerbose(hash)
+func (s *RPCTxSource) GetRawTransaction(hash *chainhash.Hash) (*RawTransaction, error) {
+	rpcClient := s.client.RPCClient()
+	rawResult, err := rpcClient.GetRawTransactionVerbose(hash)
 	if err != nil {
 		return nil, log.WithError(err, "GetRawTransactionVerbose failed")
 	}
-	//	Hex           string `json:"hex"`
-	//	Txid          string `json:"txid"`
-	//	Hash          string `json:"hash,omitempty"`
-	//	Size          int32  `json:"size,omitempty"`
-	//	Vsize         int32  `json:"vsize,omitempty"`
-	//	Weight        int32  `json:"weight,omitempty"`
-	//	Version       uint32 `json:"version"`
-	//	LockTime      uint32 `json:"locktime"`
-	//	Vin           []Vin  `json:"vin"`
-	//	Vout          []Vout `json:"vout"`
-	//	BlockHash     string `json:"blockhash,omitempty"`
-	//	Confirmations uint64 `json:"confirmations,omitempty"`
-	//	Time          int64  `json:"time,omitempty"`
-	//	Blocktime     int64  `json:"blocktime,omitempty"`
-	//&{
-	//Hex 02000000000101847b31f2d6bcf706b7f747aa22bf35d7e16a96ab8b6e2c22a5f271e9e47486340000000017160014b48208851445d7cd8753e32d25bd9f5bdda43f69feffffff03f4a487000000000017a914724d60b094581aec5756c33ec54ca9eb1850fd1b870000000000000000166a146f6d6e69000000008000050d000000001dcd65001c0200000000000017a91474d9b52e9e2457a737e233d336577b2c8b52188a8702473044022027cec650607f72f36a138f0d799dff0fa1b428b701583c4732a0fa3239e7c94802201051a5bae6b3d4bdcceaf4666b8b3fedb96ab27a1351b5eb77470ab04d294aed01210277e3a69e37416feb1896ce38b992a90a15db5e0f07f4bfd576436a0586427b4dff352600
-	//Hash 604520d6133dbacc55d15ea76d42797e88a0cc384153d3eb6524da90dbcc33f6
-	//Txid b1db4a20e9c35e8ef64232d99c5ee941461ef1748a62fd1360364d5e2df3c416
-	//278		Size 大小
-	//197		Vsize 交易费用?
-	//785	  Weight 重量
-	//2	  	  Version 版本
-	//2504191 LockTime 锁定时间
-	//[
-	//	{
-	//		348674e4e971f2a5222c6e8bab966ae1d735bf22aa47f7b706f7bcd6f2317b84
-	//		0
-	//		0x14000076140
-	//		4294967294
-	//		[
-	//			3044022027cec650607f72f36a138f0d799dff0fa1b428b701583c4732a0fa3239e7c94802201051a5bae6b3d4bdcceaf4666b8b3fedb96ab27a1351b5eb77470ab04d294aed01
-	//			0277e3a69e37416feb1896ce38b992a90a15db5e0f07f4bfd576436a0586427b4d
-	//		]
-	//	}
-	//]
-	//[
-	//	{
-	//		0.08889588	第一个人接收的
-	//		0
-	//		{
-	//			OP_HASH160
-	//			724d60b094581aec5756c33ec54ca9eb1850fd1b
-	//			OP_EQUAL
-	//			a914724d60b094581aec5756c33ec54ca9eb1850fd1b87
-	//			0
-	//			scripthash
-	//			[]
-	//		}
-	//	}
-	//	{
-	//		0
-	//		1
-	//		{
-	//			OP_RETURN
-	//			6f6d6e69000000008000050d000000001dcd6500
-	//			6a146f6d6e69000000008000050d000000001dcd6500
-	//			0
-	//			nulldata
-	//			[]
-	//		}
-	//	}
-	//	{
-	//		5.4e-06  第三个人接收的
-	//		2
-	//		{
-	//			OP_HASH160
-	//			74d9b52e9e2457a737e233d336577b2c8b52188a
-	//			OP_EQUAL
-	//			a91474d9b52e9e2457a737e233d336577b2c8b52188a87
-	//			0
-	//			scripthash
-	//			[]
-	//		}
-	//	}
-	//]
-	//00000000000000122988c78057b5739633c1e71e31299ab1dbe0857a93bc3319
-	//7
-	//1695177573
-	//1695177573
-	//}
-	status := base.TransactionStatusPending
-	if rawResult.Confirmations > 0 {
-		status = base.TransactionStatusSuccess
-	}
-	return &base.TransactionDetail{
-		Hash:   txHash,
-		Status: status,
-		Time:   rawResult.Time,
-	}, nil
+	data, err := hex.DecodeString(rawResult.Hex)
+	if err != nil {
+		return nil, log.WithError(err, "DecodeString failed")
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err = tx.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, log.WithError(err, "Deserialize failed")
+	}
+
+	// 节点需要开启 txindex 才能查询引用的交易
+	prevOuts := make([]*wire.TxOut, len(tx.TxIn))
+	if !blockchain.IsCoinBaseTx(tx) {
+		prevTxs := make(map[chainhash.Hash]*wire.MsgTx)
+		for i, in := range tx.TxIn {
+			prevHash := in.PreviousOutPoint.Hash
+			prevTx, ok := prevTxs[prevHash]
+			if !ok {
+				result, err := rpcClient.GetRawTransaction(&prevHash)
+				if err != nil {
+					return nil, log.WithError(err, "GetRawTransaction failed")
+				}
+				prevTx = result.MsgTx()
+				prevTxs[prevHash] = prevTx
+			}
+			if int(in.PreviousOutPoint.Index) >= len(prevTx.TxOut) {
+				return nil, log.WithError(utils.ErrInvalidValue, "prevout index out of range")
+			}
+			prevOuts[i] = prevTx.TxOut[in.PreviousOutPoint.Index]
+		}
+	}
+
+	var block *RawBlock
+	if rawResult.BlockHash != "" && rawResult.Confirmations > 0 {
+		blockHash, err := chainhash.NewHashFromStr(rawResult.BlockHash)
+		if err != nil {
+			return nil, log.WithError(err, "NewHashFromStr failed")
+		}
+		header, err := rpcClient.GetBlockHeaderVerbose(blockHash)
+		if err != nil {
+			return nil, log.WithError(err, "GetBlockHeaderVerbose failed")
+		}
+		block = &RawBlock{
+			Hash:          rawResult.BlockHash,
+			Height:        int64(header.Height),
+			Time:          rawResult.Blocktime,
+			Confirmations: rawResult.Confirmations,
+		}
+	}
+
+	raw := NewRawTransaction(tx, prevOuts, block)
+	if raw.Time == 0 {
+		raw.Time = rawResult.Time
+	}
+	return raw, nil
+}
+
+func (c *Chain) FetchTransactionDetail(txHash string) (*base.TransactionDetail, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return nil, log.WithError(err, "NewHashFromStr failed")
+	}
+	source, err := c.TxSource()
+	if err != nil {
+		return nil, log.WithError(err, "TxSource failed")
+	}
+	raw, err := source.GetRawTransaction(hash)
+	if err != nil {
+		return nil, log.WithError(err, "GetRawTransaction failed")
+	}
+	return NewTransactionDetail(raw, source.ChainParams()), nil
 }
 
 func (c *Chain) FetchTransactionStatus(hash string) (base.TransactionStatus, error) {
-	detail, err := c.FetchTransactionDetail(hash)
+	status, err := c.fetchTransactionStatus(hash)
+	if err != nil {
+		return base.TransactionStatusNone, err
+	}
+	if status != base.TransactionStatusSuccess || c.proofSource == nil || c.headers == nil {
+		return status, nil
+	}
+	return c.verifiedStatus(hash)
+}
+
+// fetchTransactionStatus 数据来源实现 TxStatusSource 时只查询状态, 否则由交易明细得到
+func (c *Chain) fetchTransactionStatus(hash string) (base.TransactionStatus, error) {
+	source, err := c.TxSource()
 	if err != nil {
-		return base.TransactionStatusNone, log.WithError(err, "FetchTransactionDetail failed")
+		return base.TransactionStatusNone, log.WithError(err, "TxSource failed")
+	}
+	statusSource, ok := source.(TxStatusSource)
+	if !ok {
+		detail, err := c.FetchTransactionDetail(hash)
+		if err != nil {
+			return base.TransactionStatusNone, log.WithError(err, "FetchTransactionDetail failed")
+		}
+		return detail.Status, nil
+	}
+	status, err := statusSource.GetTxStatus(hash)
+	if err != nil {
+		return base.TransactionStatusNone, log.WithError(err, "GetTxStatus failed")
+	}
+	switch {
+	case !status.Found:
+		return base.TransactionStatusNone, nil
+	case status.Confirmed:
+		return base.TransactionStatusSuccess, nil
+	}
+	return base.TransactionStatusPending, nil
+}
+
+// verifiedStatus 校验已确认交易的默克尔证明, 区块头还未同步到本地时视为待确认
+func (c *Chain) verifiedStatus(hash string) (base.TransactionStatus, error) {
+	proof, err := c.proofSource.GetMerkleProof(hash)
+	if err != nil {
+		return base.TransactionStatusNone, log.WithError(err, "GetMerkleProof failed")
+	}
+	if proof.TxId.String() != hash {
+		return base.TransactionStatusNone, log.WithError(utils.ErrInvalidProof, "txid mismatch")
+	}
+	if _, err = c.headers.VerifyProof(proof); err != nil {
+		if e, ok := err.(*utils.Error); ok && e.ErrCode == utils.ErrUnknownBlock.ErrCode {
+			return base.TransactionStatusPending, nil
+		}
+		return base.TransactionStatusNone, log.WithError(err, "VerifyProof failed")
 	}
-	return detail.Status, nil
+	return base.TransactionStatusSuccess, nil
 }
@@ -1,6 +1,13 @@
 package btc
 
 import (
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
 	"testing"
 )
@@ -19,3 +26,123 @@ func TestChain_FetchTransactionDetail(t *testing.T) {
 	}
 	t.Log(detail)
 }
+
+// fakeTxSource 返回预设交易的数据来源
+type fakeTxSource struct {
+	raw *RawTransaction
+}
+
+func (s *fakeTxSource) GetRawTransaction(hash *chainhash.Hash) (*RawTransaction, error) {
+	return s.raw, nil
+}
+
+func (s *fakeTxSource) ChainParams() *chaincfg.Params {
+	return &chaincfg.TestNet3Params
+}
+
+// newDetailTestTx 一个 P2WPKH 输入, 支付到 P2SH、OP_RETURN 和 P2WPKH 找零
+func newDetailTestTx(t *testing.T) (*wire.MsgTx, []*wire.TxOut) {
+	params := &chaincfg.TestNet3Params
+	from, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", params)
+	fromScript, _ := txscript.PayToAddrScript(from)
+	toScript, _ := txscript.PayToAddrScript(to)
+	nullData, _ := txscript.NullDataScript([]byte("hdwallet"))
+
+	prevHash, _ := chainhash.NewHashFromStr("348674e4e971f2a5222c6e8bab966ae1d735bf22aa47f7b706f7bcd6f2317b84")
+	tx := wire.NewMsgTx(wire.TxVersion)
+	in := wire.NewTxIn(wire.NewOutPoint(prevHash, 1), nil, nil)
+	in.Witness = wire.TxWitness{make([]byte, 72), make([]byte, 33)}
+	tx.AddTxIn(in)
+	tx.AddTxOut(wire.NewTxOut(60000, toScript))
+	tx.AddTxOut(wire.NewTxOut(0, nullData))
+	tx.AddTxOut(wire.NewTxOut(39000, fromScript))
+	return tx, []*wire.TxOut{wire.NewTxOut(100000, fromScript)}
+}
+
+func TestNewTransactionDetail(t *testing.T) {
+	tx, prevOuts := newDetailTestTx(t)
+	block := &RawBlock{Hash: "00000000000000122988c78057b5739633c1e71e31299ab1dbe0857a93bc3319", Height: 2504192, Time: 1695177573, Confirmations: 7}
+	raw := NewRawTransaction(tx, prevOuts, block)
+	detail := NewTransactionDetail(raw, &chaincfg.TestNet3Params)
+
+	assert.Equal(t, tx.TxHash().String(), detail.Hash)
+	assert.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	assert.Equal(t, int64(1695177573), detail.Time)
+	assert.Equal(t, uint64(7), detail.Confirmations)
+	assert.Equal(t, int64(2504192), detail.BlockNumber.Int64())
+	assert.Equal(t, block.Hash, detail.BlockHash)
+
+	assert.Equal(t, "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", detail.Form)
+	assert.Equal(t, "2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", detail.To)
+	if assert.Len(t, detail.Inputs, 1) {
+		assert.Equal(t, int64(100000), detail.Inputs[0].Value.Int64())
+		assert.Equal(t, uint32(1), detail.Inputs[0].Vout)
+	}
+	if assert.Len(t, detail.Outputs, 3) {
+		assert.Equal(t, "scripthash", detail.Outputs[0].ScriptType)
+		assert.Equal(t, "nulldata", detail.Outputs[1].ScriptType)
+		assert.Equal(t, "", detail.Outputs[1].Address)
+		assert.Equal(t, "witness_v0_keyhash", detail.Outputs[2].ScriptType)
+	}
+
+	assert.Equal(t, int64(1000), detail.GasFee.Int64())
+	assert.Equal(t, uint64(raw.VSize), detail.GasUsed)
+	assert.InDelta(t, 1000/float64(raw.VSize), detail.FeeRate, 0.01)
+	assert.Equal(t, "0.00099", detail.Amount)
+}
+
+func TestChain_FetchTransactionDetailWithSource(t *testing.T) {
+	tx, prevOuts := newDetailTestTx(t)
+	chain := NewChain().SetTxSource(&fakeTxSource{raw: NewRawTransaction(tx, prevOuts, nil)})
+
+	detail, err := chain.FetchTransactionDetail(tx.TxHash().String())
+	assert.NoError(t, err)
+	assert.Equal(t, base.TransactionStatusPending, detail.Status)
+	assert.Equal(t, uint64(0), detail.Confirmations)
+	assert.Nil(t, detail.BlockNumber)
+	assert.Equal(t, int64(1000), detail.GasFee.Int64())
+
+	status, err := chain.FetchTransactionStatus(tx.TxHash().String())
+	assert.NoError(t, err)
+	assert.Equal(t, base.TransactionStatusPending, status)
+}
+
+// statusTxSource 实现 TxStatusSource, 查询状态时不会获取交易明细
+type statusTxSource struct {
+	fakeTxSource
+	status *TxStatus
+}
+
+func (s *statusTxSource) GetRawTransaction(hash *chainhash.Hash) (*RawTransaction, error) {
+	return nil, utils.ErrNotSupported
+}
+
+func (s *statusTxSource) GetTxStatus(txId string) (*TxStatus, error) {
+	return s.status, nil
+}
+
+func TestChain_FetchTransactionStatusWithStatusSource(t *testing.T) {
+	source := &statusTxSource{status: &TxStatus{Found: true}}
+	chain := NewChain().SetTxSource(source)
+	txId := "604520d6133dbacc55d15ea76d42797e88a0cc384153d3eb6524da90dbcc33f6"
+
+	status, err := chain.FetchTransactionStatus(txId)
+	assert.NoError(t, err)
+	assert.Equal(t, base.TransactionStatusPending, status)
+
+	source.status = &TxStatus{Found: true, Confirmed: true, BlockHeight: 100}
+	status, err = chain.FetchTransactionStatus(txId)
+	assert.NoError(t, err)
+	assert.Equal(t, base.TransactionStatusSuccess, status)
+
+	source.status = &TxStatus{}
+	status, err = chain.FetchTransactionStatus(txId)
+	assert.NoError(t, err)
+	assert.Equal(t, base.TransactionStatusNone, status)
+}
+
+func TestChain_FetchTransactionDetailWithoutClient(t *testing.T) {
+	_, err := NewChain().FetchTransactionDetail("604520d6133dbacc55d15ea76d42797e88a0cc384153d3eb6524da90dbcc33f6")
+	assert.Error(t, err)
+}
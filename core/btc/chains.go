@@ -0,0 +1,138 @@
+package btc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+)
+
+const (
+	// SigHashForkID Bitcoin Cash 签名类型标志, 签名摘要使用 BIP143 算法
+	SigHashForkID txscript.SigHashType = 0x40
+
+	// DogeMinRelayFeePerKb Dogecoin 最低转发费率 0.001 DOGE/kB
+	DogeMinRelayFeePerKb btcutil.Amount = 100000
+	// DogeDustLimit Dogecoin 粉尘阈值 0.01 DOGE, 低于该值的输出不被转发
+	DogeDustLimit btcutil.Amount = 1000000
+)
+
+// FeeRules 链的转发费率和粉尘规则
+type FeeRules struct {
+	MinRelayFeePerKb btcutil.Amount // 最低转发费率
+	DustLimit        btcutil.Amount // 固定的粉尘阈值, 为 0 时按 txrules.IsDustOutput 计算
+}
+
+// ChainFeeRules 获取链的费率规则, Dogecoin 使用固定的粉尘阈值
+func ChainFeeRules(chain *chaincfg.Params) FeeRules {
+	if utils.BtcCoinType(chain) == utils.DOGE {
+		return FeeRules{MinRelayFeePerKb: DogeMinRelayFeePerKb, DustLimit: DogeDustLimit}
+	}
+	return FeeRules{MinRelayFeePerKb: txrules.DefaultRelayFeePerKb}
+}
+
+// IsDust 输出是否为粉尘, relayFeePerKb 为 0 时使用链的最低转发费率
+func (r FeeRules) IsDust(out *wire.TxOut, relayFeePerKb btcutil.Amount) bool {
+	if r.DustLimit > 0 {
+		return !txscript.IsUnspendable(out.PkScript) && btcutil.Amount(out.Value) < r.DustLimit
+	}
+	if relayFeePerKb <= 0 {
+		relayFeePerKb = r.MinRelayFeePerKb
+	}
+	return txrules.IsDustOutput(out, relayFeePerKb)
+}
+
+// validate 验证交易的全部输入脚本, BCH 使用 SIGHASH_FORKID 规则
+func (t *Transaction) validate() error {
+	if utils.IsForkIdChain(t.chainParams) {
+		return validateForkIdTx(t.Tx, t.PrevScripts, t.PrevInputValues)
+	}
+	return validateMsgTx(t.Tx, t.PrevScripts, t.PrevInputValues)
+}
+
+// signForkId 使用 SIGHASH_ALL|SIGHASH_FORKID 签名 P2PKH 输入
+func (t *Transaction) signForkId(secrets txauthor.SecretsSource) error {
+	fetcher, err := txauthor.TXPrevOutFetcher(t.Tx, t.PrevScripts, t.PrevInputValues)
+	if err != nil {
+		return log.WithError(err, "TXPrevOutFetcher failed")
+	}
+	sigHashes := txscript.NewTxSigHashes(t.Tx, fetcher)
+	hashType := txscript.SigHashAll | SigHashForkID
+	for i, in := range t.Tx.TxIn {
+		prevScript := t.PrevScripts[i]
+		class, addrs, _, err := txscript.ExtractPkScriptAddrs(prevScript, t.chainParams)
+		if err != nil || class != txscript.PubKeyHashTy {
+			return log.WithError(utils.ErrAddressTypeNotSupported, fmt.Sprintf("input %d is not P2PKH", i))
+		}
+		privateKey, compressed, err := secrets.GetKey(addrs[0])
+		if err != nil {
+			return log.WithError(err, "GetKey failed")
+		}
+		pubKey := privateKey.PubKey().SerializeUncompressed()
+		if compressed {
+			pubKey = privateKey.PubKey().SerializeCompressed()
+		}
+		if !bytes.Equal(prevScript[3:23], btcutil.Hash160(pubKey)) {
+			return log.WithError(utils.ErrInvalidSignature, fmt.Sprintf("input %d is not owned by account", i))
+		}
+		hash, err := txscript.CalcWitnessSigHash(prevScript, sigHashes, hashType, t.Tx, i, int64(t.PrevInputValues[i]))
+		if err != nil {
+			return log.WithError(err, "CalcWitnessSigHash failed")
+		}
+		sig := append(ecdsa.Sign(privateKey, hash).Serialize(), byte(hashType))
+		if in.SignatureScript, err = txscript.NewScriptBuilder().AddData(sig).AddData(pubKey).Script(); err != nil {
+			return log.WithError(err, "Script failed")
+		}
+	}
+	return nil
+}
+
+// validateForkIdTx 验证 SIGHASH_FORKID 签名的 P2PKH 输入
+func validateForkIdTx(tx *wire.MsgTx, prevScripts [][]byte, inputValues []btcutil.Amount) error {
+	fetcher, err := txauthor.TXPrevOutFetcher(tx, prevScripts, inputValues)
+	if err != nil {
+		return err
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+	for i, prevScript := range prevScripts {
+		if txscript.GetScriptClass(prevScript) != txscript.PubKeyHashTy {
+			return fmt.Errorf("输入 %d 不是 P2PKH", i)
+		}
+		pushes, err := txscript.PushedData(tx.TxIn[i].SignatureScript)
+		if err != nil || len(pushes) != 2 || len(pushes[0]) == 0 {
+			return fmt.Errorf("输入 %d 脚本无效", i)
+		}
+		sig, pubKeyData := pushes[0], pushes[1]
+		hashType := txscript.SigHashType(sig[len(sig)-1])
+		if hashType&SigHashForkID == 0 {
+			return fmt.Errorf("输入 %d 缺少 SIGHASH_FORKID", i)
+		}
+		if !bytes.Equal(btcutil.Hash160(pubKeyData), prevScript[3:23]) {
+			return fmt.Errorf("输入 %d 公钥不匹配", i)
+		}
+		pubKey, err := btcec.ParsePubKey(pubKeyData)
+		if err != nil {
+			return fmt.Errorf("输入 %d 公钥无效: %s", i, err)
+		}
+		signature, err := ecdsa.ParseDERSignature(sig[:len(sig)-1])
+		if err != nil {
+			return fmt.Errorf("输入 %d 签名无效: %s", i, err)
+		}
+		hash, err := txscript.CalcWitnessSigHash(prevScript, sigHashes, hashType, tx, i, int64(inputValues[i]))
+		if err != nil {
+			return err
+		}
+		if !signature.Verify(hash, pubKey) {
+			return fmt.Errorf("无法验证交易: 输入 %d 签名错误", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package btc
+
+import (
+	"encoding/hex"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"strings"
+	"testing"
+)
+
+// newChainTestAccount 使用 testAccountKey 的私钥创建指定链的账户
+func newChainTestAccount(t *testing.T, chainId int) *Account {
+	wif, _ := btcutil.DecodeWIF(testAccountKey)
+	account, err := NewAccountWithPrivateKey(hex.EncodeToString(wif.PrivKey.Serialize()), chainId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
+func TestAccount_Litecoin(t *testing.T) {
+	account := newChainTestAccount(t, utils.LtcChainMainNet)
+	assert.Equal(t, utils.LTC, account.CoinType())
+	assert.Equal(t, "LTC", account.Symbol())
+	assert.Equal(t, int16(8), account.Decimal())
+	assert.True(t, strings.HasPrefix(account.LegacyAddress(), "L"))
+
+	address, err := account.NativeSegwitAddress()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(address, "ltc1q"))
+	addr, err := DecodeAddress(address, &utils.LtcMainNetParams)
+	assert.NoError(t, err)
+	assert.Equal(t, address, addr.EncodeAddress())
+
+	nested, err := account.NestedSegwitAddress()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(nested, "M"))
+
+	_, err = DecodeAddress(address, &chaincfg.MainNetParams)
+	assert.Error(t, err)
+	_, err = DecodeAddress("ltcmweb1qq0ur4ucyx9yewwg9ngsg2aw2wmy3sv4rdfs4ax9dswam7yqyge9apz", &utils.LtcMainNetParams)
+	assert.Equal(t, utils.ErrAddressTypeNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestAccount_Dogecoin(t *testing.T) {
+	account := newChainTestAccount(t, utils.DogeChainMainNet)
+	assert.Equal(t, utils.DOGE, account.CoinType())
+	assert.Equal(t, "Dogecoin", account.Name())
+	assert.True(t, strings.HasPrefix(account.LegacyAddress(), "D"))
+
+	_, err := account.NativeSegwitAddress()
+	assert.Equal(t, utils.ErrAddressTypeNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+	_, err = account.AddressOfType(AddressTypeTaproot)
+	assert.Equal(t, utils.ErrAddressTypeNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestGetBtcChainParams_Family(t *testing.T) {
+	for _, chainId := range []int{utils.LtcChainMainNet, utils.LtcChainTestNet4, utils.DogeChainMainNet,
+		utils.DogeChainTestNet, utils.BchChainMainNet, utils.BchChainTestNet3} {
+		params, err := utils.GetBtcChainParams(chainId)
+		assert.NoError(t, err)
+		byName, err := utils.GetBtcChainParam(utils.BtcChainName(params))
+		assert.NoError(t, err)
+		assert.Equal(t, params, byName)
+		id, err := utils.GetBtcChainId(params.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, chainId, id)
+	}
+	assert.Equal(t, "TestNet3", utils.BtcChainName(&chaincfg.TestNet3Params))
+}
+
+func TestNewTransaction_Dogecoin(t *testing.T) {
+	chain := &utils.DogeTestNetParams
+	account := newChainTestAccount(t, utils.DogeChainTestNet)
+	from, _ := btcutil.DecodeAddress(account.LegacyAddress(), chain)
+	script, _ := txscript.PayToAddrScript(from)
+	unspents := newTestUnspents(t, "n43tW32TTVfapiTEstqmhAAoasEcRdAJEm", 500000000)
+	for i := range unspents {
+		unspents[i].ScriptPubKey = hex.EncodeToString(script)
+	}
+
+	// 低于最低转发费率
+	_, err := NewTransaction(unspents, []TransferParam{{To: from, Amount: 100000000}}, from, 1000, chain)
+	assert.Equal(t, utils.ErrFeeTooLow.ErrCode, err.(*utils.Error).ErrCode)
+	// 低于粉尘阈值的输出
+	_, err = NewTransaction(unspents, []TransferParam{{To: from, Amount: 500000}}, from, int64(DogeMinRelayFeePerKb), chain)
+	assert.Equal(t, utils.ErrDustOutput.ErrCode, err.(*utils.Error).ErrCode)
+
+	// 找零低于粉尘阈值时并入手续费
+	tx, err := NewTransaction(unspents, []TransferParam{{To: from, Amount: 499500000}}, from, int64(DogeMinRelayFeePerKb), chain)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, tx.ChangeIndex)
+	assert.Len(t, tx.Tx.TxOut, 1)
+
+	tx, err = NewTransaction(unspents, []TransferParam{{To: from, Amount: 100000000}}, from, int64(DogeMinRelayFeePerKb), chain)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+	assert.Empty(t, tx.Preflight(&PreflightOptions{ChainParams: chain}))
+}
+
+func TestTransaction_SignBitcoinCash(t *testing.T) {
+	chain := &utils.BchTestNet3Params
+	account := newChainTestAccount(t, utils.BchChainTestNet3)
+	from, err := DecodeAddress(account.LegacyAddress(), chain)
+	assert.NoError(t, err)
+	cashAddr, err := EncodeCashAddress(from, chain)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(cashAddr, CashAddrTestNetPrefix+":q"))
+	to, err := DecodeAddress(cashAddr, chain)
+	assert.NoError(t, err)
+
+	unspents := newTestUnspents(t, from.EncodeAddress(), 3000000, 2000000)
+	tx, err := NewTransaction(unspents, []TransferParam{{To: to, Amount: 4000000}}, from, 2000, chain)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+	assert.Empty(t, tx.Preflight(&PreflightOptions{ChainParams: chain}))
+	for _, in := range tx.Tx.TxIn {
+		pushes, _ := txscript.PushedData(in.SignatureScript)
+		assert.Equal(t, byte(txscript.SigHashAll|SigHashForkID), pushes[0][len(pushes[0])-1])
+	}
+
+	// 比特币规则的签名在 BCH 上无效
+	legacy, _ := NewTransaction(unspents, []TransferParam{{To: to, Amount: 4000000}}, from, 2000, &chaincfg.TestNet3Params)
+	btcAccount, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	assert.NoError(t, legacy.SignWithSecretsSource(btcAccount))
+	assert.Error(t, validateForkIdTx(legacy.Tx, legacy.PrevScripts, legacy.PrevInputValues))
+
+	// 修改金额后签名无效
+	tx.Tx.TxOut[0].Value--
+	assert.Error(t, tx.validate())
+}
+
+func TestGetBtcChainParams_SignetAndTestNet4(t *testing.T) {
+	assert.Equal(t, wire.BitcoinNet(utils.BtcChainSigNet), chaincfg.SigNetParams.Net)
+	genesis := utils.TestNet4Params.GenesisBlock
+	assert.Equal(t, "7aa0a7ae1e223414cb807e40cd57e667b718e42aaf9306db9102fe28912b7b4e", genesis.Header.MerkleRoot.String())
+	assert.Equal(t, *utils.TestNet4Params.GenesisHash, genesis.BlockHash())
+
+	testnet3 := newChainTestAccount(t, utils.BtcChainTestNet3)
+	expected, _ := testnet3.NativeSegwitAddress()
+	for _, chainId := range []int{utils.BtcChainSigNet, utils.BtcChainTestNet4} {
+		params, err := utils.GetBtcChainParams(chainId)
+		assert.NoError(t, err)
+		assert.Equal(t, utils.BTC, utils.BtcCoinType(params))
+		byName, err := utils.GetBtcChainParam(utils.BtcChainName(params))
+		assert.NoError(t, err)
+		assert.Equal(t, params, byName)
+		id, err := utils.GetBtcChainId(params.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, chainId, id)
+
+		// 地址编码与 testnet3 相同
+		account := newChainTestAccount(t, chainId)
+		address, err := account.NativeSegwitAddress()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, address)
+		_, err = DecodeAddress(address, params)
+		assert.NoError(t, err)
+		assert.Equal(t, chaincfg.TestNet3Params.Name, rpcChainName(params))
+	}
+
+	// 自定义 challenge 的 signet
+	challenge, _ := hex.DecodeString("512103ad5e0edad18cb1f0fc0d28a3d4f1f3e445640337489abb10404f2d1e086be43051ae")
+	params, err := utils.CustomSignetParams(challenge)
+	assert.NoError(t, err)
+	assert.NotEqual(t, chaincfg.SigNetParams.Net, params.Net)
+	again, _ := utils.CustomSignetParams(challenge)
+	assert.Same(t, params, again)
+	byId, err := utils.GetBtcChainParams(int(params.Net))
+	assert.NoError(t, err)
+	assert.Same(t, params, byId)
+	byName, err := utils.GetBtcChainParam(utils.BtcChainName(params))
+	assert.NoError(t, err)
+	assert.Same(t, params, byName)
+	assert.Equal(t, chaincfg.TestNet3Params.Name, rpcChainName(params))
+
+	defaultSignet, _ := utils.CustomSignetParams(chaincfg.DefaultSignetChallenge)
+	assert.Same(t, &chaincfg.SigNetParams, defaultSignet)
+	_, err = utils.CustomSignetParams(nil)
+	assert.Error(t, err)
+}
+
+func TestToken_ChainCoin(t *testing.T) {
+	token := NewToken(&Chain{client: &Client{chainParams: &utils.DogeMainNetParams}})
+	assert.Equal(t, utils.DOGE, token.CoinType())
+	info, err := token.TokenInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "DOGE", info.Symbol)
+	assert.Equal(t, int16(8), info.Decimal)
+
+	assert.Equal(t, utils.BTC, NewChain().MainToken().(*Token).CoinType())
+	assert.Equal(t, AddressTypeLegacy, defaultAddressType(&utils.BchMainNetParams))
+	assert.Equal(t, AddressTypeNestedSegwit, defaultAddressType(&utils.LtcMainNetParams))
+}
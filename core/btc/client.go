@@ -1,6 +1,7 @@
 package btc
 
 import (
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/rpcclient"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
@@ -13,8 +14,9 @@ var (
 )
 
 type Client struct {
-	rpcClient *rpcclient.Client
-	rpcUrl    string
+	rpcClient   *rpcclient.Client
+	rpcUrl      string
+	chainParams *chaincfg.Params
 }
 
 func (c *Client) RPCClient() *rpcclient.Client {
@@ -22,6 +24,10 @@ func (c *Client) RPCClient() *rpcclient.Client {
 
 }
 
+func (c *Client) ChainParams() *chaincfg.Params {
+	return c.chainParams
+}
+
 func NewClient(url, user, pass string, chainId int) (*Client, error) {
 	if url == "" {
 		return nil, log.WithError(utils.ErrInvalidURL, "NewClient failed")
@@ -47,14 +53,14 @@ func NewClient(url, user, pass string, chainId int) (*Client, error) {
 		Pass:         pass,
 		HTTPPostMode: true,
 		DisableTLS:   false,
-		Params:       params.Name,
+		Params:       rpcChainName(params),
 	}, nil)
 
 	if err != nil {
 		return nil, log.WithError(err, "ChainID failed")
 	}
 
-	c2 := &Client{rpcClient: rpcClient, rpcUrl: url}
+	c2 := &Client{rpcClient: rpcClient, rpcUrl: url, chainParams: params}
 
 	addClient(url, c2)
 	return c2, nil
@@ -71,3 +77,11 @@ func getClient(url string) *Client {
 func addClient(url string, client *Client) {
 	cachedClient.Store(url, client)
 }
+
+// rpcChainName rpcclient 只识别 btcd 内置的网络, signet 和 testnet4 的地址编码与 testnet3 相同
+func rpcChainName(params *chaincfg.Params) string {
+	if params.Net == utils.BtcChainTestNet4 || strings.HasPrefix(params.Name, chaincfg.SigNetParams.Name) {
+		return chaincfg.TestNet3Params.Name
+	}
+	return params.Name
+}
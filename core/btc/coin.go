@@ -1,18 +1,52 @@
 package btc
 
-import "hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+import (
+	"github.com/btcsuite/btcd/chaincfg"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+)
 
+// Coin BTC 系的币种, 零值为 BTC
 type Coin struct {
+	coinType uint32
+}
+
+// NewCoin 根据链参数创建币种, 支持 BTC、LTC、DOGE、BCH
+func NewCoin(chain *chaincfg.Params) Coin {
+	return Coin{coinType: utils.BtcCoinType(chain)}
 }
 
 func (c *Coin) CoinType() uint32 {
-	return utils.BTC
+	if c.coinType == 0 {
+		return utils.BTC
+	}
+	return c.coinType
 }
 
 func (c *Coin) Symbol() string {
+	switch c.CoinType() {
+	case utils.LTC:
+		return "LTC"
+	case utils.DOGE:
+		return "DOGE"
+	case utils.BCH:
+		return "BCH"
+	}
 	return "BTC"
 }
 
 func (c *Coin) Name() string {
+	switch c.CoinType() {
+	case utils.LTC:
+		return "Litecoin"
+	case utils.DOGE:
+		return "Dogecoin"
+	case utils.BCH:
+		return "Bitcoin Cash"
+	}
 	return "Bitcoin"
 }
+
+// Decimal 精度, BTC 系均为 8
+func (c *Coin) Decimal() int16 {
+	return 8
+}
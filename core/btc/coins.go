@@ -0,0 +1,174 @@
+package btc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"os"
+	"sync"
+)
+
+// CoinInfo UTXO 的标签和冻结状态
+type CoinInfo struct {
+	Label  string `json:"label,omitempty"`
+	Frozen bool   `json:"frozen,omitempty"`
+}
+
+// CoinStore 在数据源返回的 UTXO 之上记录标签和冻结状态, 用于手动选币
+// 指定文件路径时每次修改后写入文件, 路径为空时只保存在内存中
+type CoinStore struct {
+	path string
+
+	mu    sync.RWMutex
+	coins map[string]CoinInfo // 以 txid:vout 为键
+}
+
+// NewCoinStore 创建 CoinStore, 文件已存在时加载其中的记录
+func NewCoinStore(path string) (*CoinStore, error) {
+	s := &CoinStore{path: path, coins: make(map[string]CoinInfo)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, log.WithError(err, "ReadFile failed")
+	}
+	if err = json.Unmarshal(data, &s.coins); err != nil {
+		return nil, log.WithError(err, "Unmarshal failed")
+	}
+	return s, nil
+}
+
+func coinKey(txId string, vout uint32) string {
+	return fmt.Sprintf("%s:%d", txId, vout)
+}
+
+// Info 输出的标签和冻结状态
+func (s *CoinStore) Info(txId string, vout uint32) CoinInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.coins[coinKey(txId, vout)]
+}
+
+// SetLabel 设置输出的标签, label 为空时删除标签
+func (s *CoinStore) SetLabel(txId string, vout uint32, label string) error {
+	return s.update(txId, vout, func(info *CoinInfo) {
+		info.Label = label
+	})
+}
+
+// Freeze 冻结输出, 冻结后不会被自动选择
+func (s *CoinStore) Freeze(txId string, vout uint32) error {
+	return s.update(txId, vout, func(info *CoinInfo) {
+		info.Frozen = true
+	})
+}
+
+// Unfreeze 解冻输出
+func (s *CoinStore) Unfreeze(txId string, vout uint32) error {
+	return s.update(txId, vout, func(info *CoinInfo) {
+		info.Frozen = false
+	})
+}
+
+func (s *CoinStore) update(txId string, vout uint32, fn func(info *CoinInfo)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := coinKey(txId, vout)
+	info := s.coins[key]
+	fn(&info)
+	if info == (CoinInfo{}) {
+		delete(s.coins, key)
+	} else {
+		s.coins[key] = info
+	}
+	return s.save()
+}
+
+// Apply 返回带有标签和冻结状态的 unspents 副本
+func (s *CoinStore) Apply(unspents []BtcUnspent) []BtcUnspent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]BtcUnspent, len(unspents))
+	for i, unspent := range unspents {
+		info := s.coins[coinKey(unspent.TxID, unspent.Vout)]
+		unspent.Label, unspent.Frozen = info.Label, info.Frozen
+		result[i] = unspent
+	}
+	return result
+}
+
+// Prune 删除已花费输出 outPoints(txid:vout) 的冻结记录, 标签会保留
+// 数据源只返回部分地址的 UTXO, 不能据此判断其它输出已被花费, 因此只处理调用方指定的输出
+func (s *CoinStore) Prune(outPoints ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range outPoints {
+		info, ok := s.coins[key]
+		if !ok || !info.Frozen {
+			continue
+		}
+		if info.Label == "" {
+			delete(s.coins, key)
+		} else {
+			s.coins[key] = CoinInfo{Label: info.Label}
+		}
+	}
+	return s.save()
+}
+
+// save 路径不为空时写入文件
+func (s *CoinStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.coins, "", " ")
+	if err != nil {
+		return log.WithError(err, "MarshalIndent failed")
+	}
+	if err = utils.WriteFileAtomic(s.path, data); err != nil {
+		return log.WithError(err, "WriteFileAtomic failed")
+	}
+	return nil
+}
+
+// SelectCoins 按 txid:vout 从 unspents 中选出指定的输出, 输出不存在、重复或已冻结时返回错误
+func SelectCoins(unspents []BtcUnspent, outPoints ...string) ([]BtcUnspent, error) {
+	byOutPoint := make(map[string]BtcUnspent, len(unspents))
+	for _, unspent := range unspents {
+		byOutPoint[unspent.OutPoint()] = unspent
+	}
+	selected := make([]BtcUnspent, 0, len(outPoints))
+	seen := make(map[string]bool, len(outPoints))
+	for _, outPoint := range outPoints {
+		unspent, ok := byOutPoint[outPoint]
+		if !ok {
+			return nil, log.WithError(utils.ErrInvalidValue, "unknown coin "+outPoint)
+		}
+		if seen[outPoint] {
+			return nil, log.WithError(utils.ErrDuplicateInput, outPoint)
+		}
+		if unspent.Frozen {
+			return nil, log.WithError(utils.ErrCoinFrozen, outPoint)
+		}
+		seen[outPoint] = true
+		selected = append(selected, unspent)
+	}
+	return selected, nil
+}
+
+// spendableUnspents 过滤掉冻结的输出
+func spendableUnspents(unspents []BtcUnspent) []BtcUnspent {
+	spendable := make([]BtcUnspent, 0, len(unspents))
+	for _, unspent := range unspents {
+		if !unspent.Frozen {
+			spendable = append(spendable, unspent)
+		}
+	}
+	return spendable
+}
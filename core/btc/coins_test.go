@@ -0,0 +1,117 @@
+package btc
+
+import (
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"path/filepath"
+	"testing"
+)
+
+func TestCoinStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coins", "coins.json")
+	store, err := NewCoinStore(path)
+	assert.NoError(t, err)
+	unspents := newTestUnspents(t, "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", 10000, 20000, 30000)
+	txId := unspents[0].TxID
+
+	assert.NoError(t, store.SetLabel(txId, 0, "compliance review"))
+	assert.NoError(t, store.Freeze(txId, 0))
+	assert.NoError(t, store.Freeze(txId, 2))
+	assert.NoError(t, store.Unfreeze(txId, 2))
+
+	// 重新加载后记录不变
+	store, err = NewCoinStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, CoinInfo{Label: "compliance review", Frozen: true}, store.Info(txId, 0))
+	assert.Equal(t, CoinInfo{}, store.Info(txId, 2))
+
+	applied := store.Apply(unspents)
+	assert.Equal(t, "compliance review", applied[0].Label)
+	assert.True(t, applied[0].Frozen)
+	assert.False(t, applied[1].Frozen)
+	assert.False(t, unspents[0].Frozen)
+
+	// 只清除指定输出的冻结状态, 输出被花费后只保留标签
+	assert.NoError(t, store.Freeze(txId, 1))
+	assert.NoError(t, store.Prune(unspents[0].OutPoint()))
+	assert.Equal(t, CoinInfo{Label: "compliance review"}, store.Info(txId, 0))
+	assert.True(t, store.Info(txId, 1).Frozen)
+
+	memory, err := NewCoinStore("")
+	assert.NoError(t, err)
+	assert.NoError(t, memory.Freeze(txId, 1))
+	assert.True(t, memory.Info(txId, 1).Frozen)
+	assert.True(t, applyCoins(memory, unspents)[1].Frozen)
+	assert.Equal(t, unspents, applyCoins(nil, unspents))
+}
+
+func TestNewTransaction_CoinControl(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	from, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", params)
+	unspents := newTestUnspents(t, from.EncodeAddress(), 50000, 20000, 30000)
+	outputs := []TransferParam{{To: to, Amount: 10000}}
+
+	// 冻结的输出不会被自动选择
+	store, _ := NewCoinStore("")
+	assert.NoError(t, store.Freeze(unspents[0].TxID, 0))
+	tx, err := NewTransaction(store.Apply(unspents), outputs, from, 1000, params)
+	assert.NoError(t, err)
+	if assert.Len(t, tx.Tx.TxIn, 1) {
+		assert.Equal(t, uint32(1), tx.Tx.TxIn[0].PreviousOutPoint.Index)
+	}
+	_, err = NewTransaction(store.Apply(unspents), []TransferParam{{To: to, Amount: 60000}}, from, 1000, params)
+	assert.Error(t, err)
+
+	// 手动选币时花费全部指定的输出
+	coins, err := SelectCoins(store.Apply(unspents), unspents[2].OutPoint(), unspents[1].OutPoint())
+	assert.NoError(t, err)
+	tx, err = NewTransactionWithCoins(coins, outputs, from, 1000, params)
+	assert.NoError(t, err)
+	assert.Len(t, tx.Tx.TxIn, 2)
+	assert.Equal(t, uint32(2), tx.Tx.TxIn[0].PreviousOutPoint.Index)
+	assert.Equal(t, btcutil.Amount(50000), tx.TotalInput)
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+
+	_, err = NewTransactionWithCoins(coins, []TransferParam{{To: to, Amount: 50000}}, from, 1000, params)
+	assert.Error(t, err)
+	_, err = NewTransactionWithCoins(store.Apply(unspents), outputs, from, 1000, params)
+	assert.Equal(t, utils.ErrCoinFrozen.ErrCode, err.(*utils.Error).ErrCode)
+	_, err = SelectCoins(unspents, unspents[1].OutPoint(), unspents[1].OutPoint())
+	assert.Equal(t, utils.ErrDuplicateInput.ErrCode, err.(*utils.Error).ErrCode)
+	_, err = SelectCoins(unspents, unspents[0].TxID+":9")
+	assert.Equal(t, utils.ErrInvalidValue.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestWallet_SpendCoins(t *testing.T) {
+	wallet, _ := NewWallet(testMnemonic, utils.BtcChainTestNet3)
+	net := &historyNetParams{fakeNetParams: fakeNetParams{}, used: map[string]bool{}}
+	var unspents []BtcUnspent
+	for index := uint32(0); index < 2; index++ {
+		address, _ := wallet.DeriveAddress(AddressTypeNativeSegwit, ExternalBranch, index)
+		net.used[address.EncodeAddress()] = true
+		net.fakeNetParams[address.EncodeAddress()] = newTestUnspents(t, address.EncodeAddress(), 40000+int64(index)*10000)
+		unspents = append(unspents, net.fakeNetParams[address.EncodeAddress()]...)
+	}
+	assert.NoError(t, wallet.Discover(net, AddressTypeNativeSegwit, 5))
+
+	store, _ := NewCoinStore("")
+	assert.NoError(t, store.Freeze(unspents[1].TxID, unspents[1].Vout))
+	wallet.SetCoinStore(store)
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", &chaincfg.TestNet3Params)
+
+	// 金额大的输出已冻结
+	tx, err := wallet.Spend(net, []TransferParam{{To: to, Amount: 10000}}, AddressTypeNativeSegwit, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, btcutil.Amount(40000), tx.TotalInput)
+
+	_, err = wallet.SpendCoins(net, []string{unspents[1].OutPoint()}, []TransferParam{{To: to, Amount: 10000}}, AddressTypeNativeSegwit, 1000)
+	assert.Equal(t, utils.ErrCoinFrozen.ErrCode, err.(*utils.Error).ErrCode)
+	assert.NoError(t, store.Unfreeze(unspents[1].TxID, unspents[1].Vout))
+	tx, err = wallet.SpendCoins(net, []string{unspents[0].OutPoint(), unspents[1].OutPoint()}, []TransferParam{{To: to, Amount: 10000}}, AddressTypeNativeSegwit, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, btcutil.Amount(90000), tx.TotalInput)
+}
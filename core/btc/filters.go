@@ -0,0 +1,279 @@
+package btc
+
+import (
+	"encoding/hex"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/gcs"
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"sort"
+	"sync"
+)
+
+// BlockFilter BIP158 basic 类型的区块过滤器
+type BlockFilter struct {
+	Filter []byte          // 过滤器数据, 以元素个数 N 开头
+	Header *chainhash.Hash // BIP157 过滤器头, 数据源不提供时为空
+}
+
+// FilterSource 区块过滤器的数据来源, 如开启 blockfilterindex 的 bitcoind
+type FilterSource interface {
+	GetBlockHash(height int64) (*chainhash.Hash, error)
+	GetBlockFilter(blockHash *chainhash.Hash) (*BlockFilter, error)
+	GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error)
+}
+
+func decodeBlockFilter(filter []byte) (*gcs.Filter, error) {
+	f, err := gcs.FromNBytes(builder.DefaultP, builder.DefaultM, filter)
+	if err != nil {
+		return nil, log.WithError(utils.ErrInvalidProof, err.Error())
+	}
+	return f, nil
+}
+
+// MatchBlockFilter 判断区块中是否可能包含 scripts 中的任一脚本, 存在误报, 不存在漏报
+func MatchBlockFilter(filter []byte, blockHash *chainhash.Hash, scripts [][]byte) (bool, error) {
+	f, err := decodeBlockFilter(filter)
+	if err != nil {
+		return false, err
+	}
+	if f.N() == 0 || len(scripts) == 0 {
+		return false, nil
+	}
+	return f.MatchAny(builder.DeriveKey(blockHash), scripts)
+}
+
+// FilterHeader 由过滤器和上一个区块的过滤器头计算 BIP157 过滤器头
+func FilterHeader(filter []byte, prevHeader chainhash.Hash) (chainhash.Hash, error) {
+	f, err := decodeBlockFilter(filter)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	return builder.MakeHeaderForFilter(f, prevHeader)
+}
+
+// FilterScanner 通过区块过滤器扫描脚本相关的 UTXO, 只下载匹配的区块, 不向数据源暴露地址
+type FilterScanner struct {
+	source  FilterSource
+	params  *chaincfg.Params
+	headers *HeaderStore
+
+	mu         sync.Mutex
+	scripts    map[string][]byte
+	unspents   map[wire.OutPoint]*BtcUnspent
+	lastHeight int64
+	lastHeader *chainhash.Hash // 上一个扫描区块的过滤器头
+}
+
+func NewFilterScanner(source FilterSource, params *chaincfg.Params) *FilterScanner {
+	return &FilterScanner{
+		source:     source,
+		params:     params,
+		scripts:    make(map[string][]byte),
+		unspents:   make(map[wire.OutPoint]*BtcUnspent),
+		lastHeight: -1,
+	}
+}
+
+// SetHeaderStore 设置后数据源返回的区块哈希需要与本地已校验的区块头一致
+func (s *FilterScanner) SetHeaderStore(headers *HeaderStore) *FilterScanner {
+	s.headers = headers
+	return s
+}
+
+// AddAddress 添加要扫描的地址
+func (s *FilterScanner) AddAddress(address btcutil.Address) error {
+	if !address.IsForNet(s.params) {
+		return log.WithError(utils.ErrAddressNetworkMismatch, "AddAddress failed")
+	}
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return log.WithError(err, "PayToAddrScript failed")
+	}
+	s.AddScript(script)
+	return nil
+}
+
+// AddScript 添加要扫描的输出脚本
+func (s *FilterScanner) AddScript(script []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[string(script)] = script
+}
+
+// Scan 扫描 startHeight 到 endHeight 的区块, 返回目前所有已发现且未花费的输出
+// 分段扫描时应按高度顺序调用, 已发现的输出在之后的区块中被花费时会被移除
+func (s *FilterScanner) Scan(startHeight, endHeight int64) ([]BtcUnspent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scripts := make([][]byte, 0, len(s.scripts))
+	for _, script := range s.scripts {
+		scripts = append(scripts, script)
+	}
+	for height := startHeight; height <= endHeight; height++ {
+		if err := s.scanBlock(height, scripts); err != nil {
+			return nil, err
+		}
+	}
+	return s.sortedUnspents(), nil
+}
+
+// Unspents 目前所有已发现且未花费的输出
+func (s *FilterScanner) Unspents() []BtcUnspent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedUnspents()
+}
+
+func (s *FilterScanner) sortedUnspents() []BtcUnspent {
+	unspents := make([]BtcUnspent, 0, len(s.unspents))
+	for _, unspent := range s.unspents {
+		unspents = append(unspents, *unspent)
+	}
+	sort.Slice(unspents, func(i, j int) bool {
+		if unspents[i].TxID != unspents[j].TxID {
+			return unspents[i].TxID < unspents[j].TxID
+		}
+		return unspents[i].Vout < unspents[j].Vout
+	})
+	return unspents
+}
+
+func (s *FilterScanner) scanBlock(height int64, scripts [][]byte) error {
+	blockHash, err := s.blockHash(height)
+	if err != nil {
+		return err
+	}
+	filter, err := s.source.GetBlockFilter(blockHash)
+	if err != nil {
+		return log.WithError(err, "GetBlockFilter failed")
+	}
+	if err = s.checkFilterHeader(height, filter); err != nil {
+		return err
+	}
+	matched, err := MatchBlockFilter(filter.Filter, blockHash, scripts)
+	if err != nil {
+		return log.WithError(err, "MatchBlockFilter failed")
+	}
+	if !matched {
+		return nil
+	}
+
+	block, err := s.source.GetBlock(blockHash)
+	if err != nil {
+		return log.WithError(err, "GetBlock failed")
+	}
+	if block.BlockHash() != *blockHash {
+		return log.WithError(utils.ErrInvalidHeader, "block hash mismatch")
+	}
+	merkles := blockchain.BuildMerkleTreeStore(btcutil.NewBlock(block).Transactions(), false)
+	if len(merkles) == 0 || *merkles[len(merkles)-1] != block.Header.MerkleRoot {
+		return log.WithError(utils.ErrInvalidProof, "block merkle root mismatch")
+	}
+	for _, tx := range block.Transactions {
+		for _, in := range tx.TxIn {
+			delete(s.unspents, in.PreviousOutPoint)
+		}
+		txId := tx.TxHash()
+		for i, out := range tx.TxOut {
+			if _, ok := s.scripts[string(out.PkScript)]; !ok {
+				continue
+			}
+			s.unspents[wire.OutPoint{Hash: txId, Index: uint32(i)}] = &BtcUnspent{
+				TxID:         txId.String(),
+				Vout:         uint32(i),
+				ScriptPubKey: hex.EncodeToString(out.PkScript),
+				Amount:       btcutil.Amount(out.Value).ToBTC(),
+				Value:        uint64(out.Value),
+			}
+		}
+	}
+	return nil
+}
+
+func (s *FilterScanner) blockHash(height int64) (*chainhash.Hash, error) {
+	blockHash, err := s.source.GetBlockHash(height)
+	if err != nil {
+		return nil, log.WithError(err, "GetBlockHash failed")
+	}
+	if s.headers != nil {
+		header, err := s.headers.HeaderByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		if header.BlockHash() != *blockHash {
+			return nil, log.WithError(utils.ErrInvalidHeader, "block hash mismatch")
+		}
+	}
+	return blockHash, nil
+}
+
+// checkFilterHeader 数据源提供过滤器头时校验过滤器与过滤器头链一致
+func (s *FilterScanner) checkFilterHeader(height int64, filter *BlockFilter) error {
+	if filter.Header == nil {
+		s.lastHeader = nil
+		return nil
+	}
+	if s.lastHeight != height-1 || s.lastHeader == nil {
+		prev := chainhash.Hash{}
+		if height > 0 {
+			prevHash, err := s.blockHash(height - 1)
+			if err != nil {
+				return err
+			}
+			prevFilter, err := s.source.GetBlockFilter(prevHash)
+			if err != nil {
+				return log.WithError(err, "GetBlockFilter failed")
+			}
+			if prevFilter.Header == nil {
+				return log.WithError(utils.ErrInvalidProof, "missing filter header")
+			}
+			prev = *prevFilter.Header
+		}
+		s.lastHeader = &prev
+	}
+	header, err := FilterHeader(filter.Filter, *s.lastHeader)
+	if err != nil {
+		return err
+	}
+	if header != *filter.Header {
+		return log.WithError(utils.ErrInvalidProof, "filter header mismatch")
+	}
+	s.lastHeight, s.lastHeader = height, filter.Header
+	return nil
+}
+
+// GetBlockHash 实现 FilterSource
+func (s *RPCTxSource) GetBlockHash(height int64) (*chainhash.Hash, error) {
+	return s.client.RPCClient().GetBlockHash(height)
+}
+
+// GetBlockFilter 通过 getblockfilter 获取过滤器, 实现 FilterSource, 节点需要开启 blockfilterindex
+func (s *RPCTxSource) GetBlockFilter(blockHash *chainhash.Hash) (*BlockFilter, error) {
+	filterType := btcjson.FilterTypeBasic
+	result, err := s.client.RPCClient().GetBlockFilter(*blockHash, &filterType)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := hex.DecodeString(result.Filter)
+	if err != nil {
+		return nil, log.WithError(err, "DecodeString failed")
+	}
+	header, err := chainhash.NewHashFromStr(result.Header)
+	if err != nil {
+		return nil, log.WithError(err, "NewHashFromStr failed")
+	}
+	return &BlockFilter{Filter: filter, Header: header}, nil
+}
+
+// GetBlock 实现 FilterSource
+func (s *RPCTxSource) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	return s.client.RPCClient().GetBlock(blockHash)
+}
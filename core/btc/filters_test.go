@@ -0,0 +1,168 @@
+package btc
+
+import (
+	"errors"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+// fakeFilterSource 内存中的 FilterSource, 记录下载过的区块
+type fakeFilterSource struct {
+	blocks     []*wire.MsgBlock
+	filters    []*BlockFilter
+	downloaded []int
+}
+
+// newFakeFilterSource 为每个区块生成过滤器和过滤器头, prevOutScripts 为每个区块中输入引用的输出脚本
+func newFakeFilterSource(t *testing.T, blocks []*wire.MsgBlock, prevOutScripts [][][]byte) *fakeFilterSource {
+	source := &fakeFilterSource{blocks: blocks}
+	prevHeader := chainhash.Hash{}
+	for i, block := range blocks {
+		filter, err := builder.BuildBasicFilter(block, prevOutScripts[i])
+		assert.NoError(t, err)
+		data, _ := filter.NBytes()
+		header, _ := builder.MakeHeaderForFilter(filter, prevHeader)
+		source.filters = append(source.filters, &BlockFilter{Filter: data, Header: &header})
+		prevHeader = header
+	}
+	return source
+}
+
+func (f *fakeFilterSource) index(blockHash *chainhash.Hash) int {
+	for i, block := range f.blocks {
+		if block.BlockHash() == *blockHash {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeFilterSource) GetBlockHash(height int64) (*chainhash.Hash, error) {
+	if height >= int64(len(f.blocks)) {
+		return nil, errors.New("block not found")
+	}
+	hash := f.blocks[height].BlockHash()
+	return &hash, nil
+}
+
+func (f *fakeFilterSource) GetBlockFilter(blockHash *chainhash.Hash) (*BlockFilter, error) {
+	return f.filters[f.index(blockHash)], nil
+}
+
+func (f *fakeFilterSource) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	i := f.index(blockHash)
+	f.downloaded = append(f.downloaded, i)
+	return f.blocks[i], nil
+}
+
+// newFilterTestChain 第 1 个区块向 script 转账, 第 3 个区块花费其中一个输出
+func newFilterTestChain(t *testing.T, script []byte) ([]*wire.MsgBlock, [][][]byte) {
+	other := []byte{0x00, 0x14, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14}
+	newBlock := func(prev *wire.BlockHeader, txs ...*wire.MsgTx) *wire.MsgBlock {
+		block := wire.NewMsgBlock(&wire.BlockHeader{})
+		for _, tx := range txs {
+			block.AddTransaction(tx)
+		}
+		merkles := blockchain.BuildMerkleTreeStore(btcutil.NewBlock(block).Transactions(), false)
+		block.Header = *mineTestHeader(prev, prev.Timestamp.Add(tenMinutes), *merkles[len(merkles)-1])
+		return block
+	}
+	newTx := func(prev wire.OutPoint, outputs ...[]byte) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&prev, nil, nil))
+		for i, pkScript := range outputs {
+			tx.AddTxOut(wire.NewTxOut(int64(i+1)*10000, pkScript))
+		}
+		return tx
+	}
+
+	genesis := &chaincfg.RegressionNetParams.GenesisBlock.Header
+	block0 := newBlock(genesis, newTx(wire.OutPoint{Hash: chainhash.HashH([]byte("0"))}, other))
+	funding := newTx(wire.OutPoint{Hash: chainhash.HashH([]byte("1"))}, script, other, script)
+	block1 := newBlock(&block0.Header, funding)
+	block2 := newBlock(&block1.Header, newTx(wire.OutPoint{Hash: chainhash.HashH([]byte("2"))}, other))
+	block3 := newBlock(&block2.Header, newTx(wire.OutPoint{Hash: funding.TxHash(), Index: 0}, other))
+	return []*wire.MsgBlock{block0, block1, block2, block3}, [][][]byte{{other}, {other}, {other}, {script}}
+}
+
+func TestMatchBlockFilter(t *testing.T) {
+	address, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", &chaincfg.TestNet3Params)
+	script, _ := txscript.PayToAddrScript(address)
+	blocks, prevOutScripts := newFilterTestChain(t, script)
+	source := newFakeFilterSource(t, blocks, prevOutScripts)
+
+	for i, expected := range []bool{false, true, false, true} {
+		hash := blocks[i].BlockHash()
+		matched, err := MatchBlockFilter(source.filters[i].Filter, &hash, [][]byte{script})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, matched, i)
+	}
+
+	_, err := MatchBlockFilter([]byte{0xfd}, &chainhash.Hash{}, [][]byte{script})
+	assert.Equal(t, utils.ErrInvalidProof.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestFilterScanner_Scan(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	testAddress, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", &chaincfg.TestNet3Params)
+	address, _ := btcutil.NewAddressWitnessPubKeyHash(testAddress.ScriptAddress(), params)
+	script, _ := txscript.PayToAddrScript(address)
+	blocks, prevOutScripts := newFilterTestChain(t, script)
+	source := newFakeFilterSource(t, blocks, prevOutScripts)
+
+	scanner := NewFilterScanner(source, params)
+	assert.NoError(t, scanner.AddAddress(address))
+	unspents, err := scanner.Scan(0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, source.downloaded)
+	if assert.Len(t, unspents, 2) {
+		funding := blocks[1].Transactions[0].TxHash().String()
+		assert.Equal(t, BtcUnspent{TxID: funding, Vout: 0, ScriptPubKey: "0014f72c7ed741a66aaafdfb6dfcebfdb37ec5f67e9a", Amount: 0.0001, Value: 10000}, unspents[0])
+		assert.Equal(t, uint32(2), unspents[1].Vout)
+		assert.Equal(t, uint64(30000), unspents[1].Value)
+	}
+
+	// 继续扫描, 第一个输出被花费
+	unspents, err = scanner.Scan(3, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 3}, source.downloaded)
+	if assert.Len(t, unspents, 1) {
+		assert.Equal(t, uint32(2), unspents[0].Vout)
+	}
+	assert.Equal(t, unspents, scanner.Unspents())
+
+	mainAddress, _ := btcutil.DecodeAddress("1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", &chaincfg.MainNetParams)
+	err = scanner.AddAddress(mainAddress)
+	assert.Equal(t, utils.ErrAddressNetworkMismatch.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestFilterScanner_InvalidSource(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	script := []byte{0x51}
+	blocks, prevOutScripts := newFilterTestChain(t, script)
+
+	// 过滤器被替换为不包含该脚本的过滤器, 与过滤器头不一致
+	source := newFakeFilterSource(t, blocks, prevOutScripts)
+	source.filters[1] = &BlockFilter{Filter: source.filters[2].Filter, Header: source.filters[1].Header}
+	scanner := NewFilterScanner(source, params)
+	scanner.AddScript(script)
+	_, err := scanner.Scan(1, 3)
+	assert.Equal(t, utils.ErrInvalidProof.ErrCode, err.(*utils.Error).ErrCode)
+
+	// 区块哈希与本地区块头不一致
+	source = newFakeFilterSource(t, blocks, prevOutScripts)
+	store, _ := NewHeaderStore(params, nil, 0)
+	assert.NoError(t, store.AddHeaders(mineTestChain(&params.GenesisBlock.Header, 1, 'x')...))
+	scanner = NewFilterScanner(source, params).SetHeaderStore(store)
+	scanner.AddScript(script)
+	_, err = scanner.Scan(1, 1)
+	assert.Equal(t, utils.ErrInvalidHeader.ErrCode, err.(*utils.Error).ErrCode)
+}
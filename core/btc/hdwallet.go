@@ -0,0 +1,433 @@
+package btc
+
+import (
+	"fmt"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/tyler-smith/go-bip39"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"sort"
+	"sync"
+)
+
+const (
+	ExternalBranch uint32 = 0 // 收款地址链
+	InternalBranch uint32 = 1 // 找零地址链
+)
+
+// Wallet BIP44/49/84/86 分层确定性钱包, 记录每种地址类型收款链和找零链下一个未使用的序号
+type Wallet struct {
+	Coin
+	chain   *chaincfg.Params
+	master  *hdkeychain.ExtendedKey
+	account uint32
+
+	mu          sync.Mutex
+	accountKeys map[AddressType]*hdkeychain.ExtendedKey
+	next        map[AddressType]*[2]uint32
+	reserved    map[AddressType]map[uint32]struct{} // 已分配给未广播交易的找零序号
+	coins       *CoinStore
+}
+
+// NewWallet 使用助记词创建钱包, 使用第 0 个账户
+func NewWallet(mnemonic string, chainId int) (*Wallet, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, log.WithError(err, "bip39.NewSeedWithErrorChecking failed")
+	}
+	chain, err := utils.GetBtcChainParams(chainId)
+	if err != nil {
+		return nil, log.WithError(err, "ChainID failed")
+	}
+	master, err := hdkeychain.NewMaster(seed, chain)
+	if err != nil {
+		return nil, log.WithError(err, "hdkeychain.NewMaster failed")
+	}
+	return &Wallet{
+		Coin:        NewCoin(chain),
+		chain:       chain,
+		master:      master,
+		accountKeys: make(map[AddressType]*hdkeychain.ExtendedKey),
+		next:        make(map[AddressType]*[2]uint32),
+		reserved:    make(map[AddressType]map[uint32]struct{}),
+	}, nil
+}
+
+func (w *Wallet) ChainParams() *chaincfg.Params {
+	return w.chain
+}
+
+// SetCoinStore 设置后 Unspents 返回的输出带有标签和冻结状态, 冻结的输出不会被 Spend 选择
+func (w *Wallet) SetCoinStore(store *CoinStore) *Wallet {
+	w.coins = store
+	return w
+}
+
+// purpose 地址类型对应的 BIP43 purpose
+func purpose(addrType AddressType) (uint32, error) {
+	switch addrType {
+	case AddressTypeLegacy:
+		return 44, nil
+	case AddressTypeNestedSegwit:
+		return 49, nil
+	case AddressTypeNativeSegwit:
+		return 84, nil
+	case AddressTypeTaproot:
+		return 86, nil
+	}
+	return 0, log.WithError(utils.ErrAddressTypeNotSupported, fmt.Sprintf("address type %d", addrType))
+}
+
+// DerivationPath 地址的派生路径, 如 m/84'/0'/0'/1/3
+func (w *Wallet) DerivationPath(addrType AddressType, branch, index uint32) (string, error) {
+	p, err := purpose(addrType)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("m/%d'/%d'/%d'/%d/%d", p, w.chain.HDCoinType, w.account, branch, index), nil
+}
+
+func (w *Wallet) accountKey(addrType AddressType) (*hdkeychain.ExtendedKey, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if key, ok := w.accountKeys[addrType]; ok {
+		return key, nil
+	}
+	p, err := purpose(addrType)
+	if err != nil {
+		return nil, err
+	}
+	key := w.master
+	for _, n := range []uint32{p, w.chain.HDCoinType, w.account} {
+		if key, err = key.Derive(hdkeychain.HardenedKeyStart + n); err != nil {
+			return nil, log.WithError(err, "key.Derive failed")
+		}
+	}
+	w.accountKeys[addrType] = key
+	return key, nil
+}
+
+// DeriveAccount 派生指定路径的单地址账户, 可用于签名
+func (w *Wallet) DeriveAccount(addrType AddressType, branch, index uint32) (*Account, error) {
+	if branch != ExternalBranch && branch != InternalBranch {
+		return nil, log.WithError(utils.ErrInvalidValue, fmt.Sprintf("branch %d", branch))
+	}
+	key, err := w.accountKey(addrType)
+	if err != nil {
+		return nil, err
+	}
+	if key, err = key.Derive(branch); err != nil {
+		return nil, log.WithError(err, "key.Derive failed")
+	}
+	if key, err = key.Derive(index); err != nil {
+		return nil, log.WithError(err, "key.Derive failed")
+	}
+	pri, err := key.ECPrivKey()
+	if err != nil {
+		return nil, log.WithError(err, "key.ECPrivKey failed")
+	}
+	return newAccountWithKey(pri, w.chain)
+}
+
+func newAccountWithKey(pri *btcec.PrivateKey, chain *chaincfg.Params) (*Account, error) {
+	address, err := btcutil.NewAddressPubKey(pri.PubKey().SerializeCompressed(), chain)
+	if err != nil {
+		return nil, log.WithError(err, "NewAddressPubKey failed")
+	}
+	return &Account{
+		Coin:       NewCoin(chain),
+		privateKey: pri,
+		address:    address,
+		chain:      chain,
+	}, nil
+}
+
+// DeriveAddress 派生指定路径的地址
+func (w *Wallet) DeriveAddress(addrType AddressType, branch, index uint32) (btcutil.Address, error) {
+	account, err := w.DeriveAccount(addrType, branch, index)
+	if err != nil {
+		return nil, err
+	}
+	address, err := account.AddressOfType(addrType)
+	if err != nil {
+		return nil, err
+	}
+	return btcutil.DecodeAddress(address, w.chain)
+}
+
+func (w *Wallet) indexes(addrType AddressType) *[2]uint32 {
+	next, ok := w.next[addrType]
+	if !ok {
+		next = &[2]uint32{}
+		w.next[addrType] = next
+	}
+	return next
+}
+
+// NextIndex 收款链或找零链下一个未使用的序号
+func (w *Wallet) NextIndex(addrType AddressType, branch uint32) uint32 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.indexes(addrType)[branch&1]
+}
+
+// SetNextIndex 恢复钱包时设置下一个未使用的序号, 如从持久化的状态或地址发现的结果中恢复
+func (w *Wallet) SetNextIndex(addrType AddressType, branch, index uint32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.indexes(addrType)[branch&1] = index
+}
+
+// MarkUsed 标记地址已被使用, 下一个未使用的序号至少为 index+1
+func (w *Wallet) MarkUsed(addrType AddressType, branch, index uint32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	next := w.indexes(addrType)
+	if next[branch&1] <= index {
+		next[branch&1] = index + 1
+	}
+}
+
+// NewReceiveAddress 返回下一个未使用的收款地址及其序号, 并标记为已使用
+func (w *Wallet) NewReceiveAddress(addrType AddressType) (btcutil.Address, uint32, error) {
+	w.mu.Lock()
+	next := w.indexes(addrType)
+	index := next[ExternalBranch]
+	next[ExternalBranch]++
+	w.mu.Unlock()
+	address, err := w.DeriveAddress(addrType, ExternalBranch, index)
+	if err != nil {
+		return nil, 0, err
+	}
+	return address, index, nil
+}
+
+// ChangeAddress 下一个未使用且未被未广播交易预留的找零地址及其序号, 不预留该地址
+func (w *Wallet) ChangeAddress(addrType AddressType) (btcutil.Address, uint32, error) {
+	w.mu.Lock()
+	index := w.nextChangeIndex(addrType)
+	w.mu.Unlock()
+	address, err := w.DeriveAddress(addrType, InternalBranch, index)
+	if err != nil {
+		return nil, 0, err
+	}
+	return address, index, nil
+}
+
+func (w *Wallet) nextChangeIndex(addrType AddressType) uint32 {
+	index := w.indexes(addrType)[InternalBranch]
+	for {
+		if _, ok := w.reserved[addrType][index]; !ok {
+			return index
+		}
+		index++
+	}
+}
+
+// reserveChange 预留下一个找零地址, 同时创建的交易使用不同的找零地址
+func (w *Wallet) reserveChange(addrType AddressType) (btcutil.Address, uint32, error) {
+	w.mu.Lock()
+	index := w.nextChangeIndex(addrType)
+	if w.reserved[addrType] == nil {
+		w.reserved[addrType] = make(map[uint32]struct{})
+	}
+	w.reserved[addrType][index] = struct{}{}
+	w.mu.Unlock()
+	address, err := w.DeriveAddress(addrType, InternalBranch, index)
+	if err != nil {
+		w.releaseChange(addrType, index, false)
+		return nil, 0, err
+	}
+	return address, index, nil
+}
+
+// releaseChange 释放预留的找零序号, used 为 true 时标记为已使用
+func (w *Wallet) releaseChange(addrType AddressType, index uint32, used bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.reserved[addrType], index)
+	if next := w.indexes(addrType); used && next[InternalBranch] <= index {
+		next[InternalBranch] = index + 1
+	}
+}
+
+// Addresses 收款链和找零链上已使用的全部地址, 用于查询余额和 UTXO
+func (w *Wallet) Addresses(addrType AddressType) ([]btcutil.Address, error) {
+	var addresses []btcutil.Address
+	err := w.rangeUsed(addrType, func(branch, index uint32, address btcutil.Address) error {
+		addresses = append(addresses, address)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// rangeUsed 依次处理收款链和找零链上已使用的地址
+func (w *Wallet) rangeUsed(addrType AddressType, fn func(branch, index uint32, address btcutil.Address) error) error {
+	w.mu.Lock()
+	next := *w.indexes(addrType)
+	w.mu.Unlock()
+	for _, branch := range []uint32{ExternalBranch, InternalBranch} {
+		for index := uint32(0); index < next[branch]; index++ {
+			address, err := w.DeriveAddress(addrType, branch, index)
+			if err != nil {
+				return err
+			}
+			if err = fn(branch, index, address); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// NewTransaction 创建交易, 找零发送到找零链上下一个未使用的地址
+// 包含找零时预留该地址, 广播成功后标记为已使用, 广播失败后释放, 见 Transaction.SendResult
+func (w *Wallet) NewTransaction(addrType AddressType, unspents []BtcUnspent, params []TransferParam, feePerKb int64) (*Transaction, error) {
+	return w.newTransaction(addrType, func(changeAddress btcutil.Address) (*Transaction, error) {
+		return NewTransaction(unspents, params, changeAddress, feePerKb, w.chain)
+	})
+}
+
+// NewTransactionWithCoins 花费 coins 中的全部输出, 找零处理与 NewTransaction 相同
+func (w *Wallet) NewTransactionWithCoins(addrType AddressType, coins []BtcUnspent, params []TransferParam, feePerKb int64) (*Transaction, error) {
+	return w.newTransaction(addrType, func(changeAddress btcutil.Address) (*Transaction, error) {
+		return NewTransactionWithCoins(coins, params, changeAddress, feePerKb, w.chain)
+	})
+}
+
+func (w *Wallet) newTransaction(addrType AddressType, build func(changeAddress btcutil.Address) (*Transaction, error)) (*Transaction, error) {
+	changeAddress, changeIndex, err := w.reserveChange(addrType)
+	if err != nil {
+		return nil, log.WithError(err, "ChangeAddress failed")
+	}
+	tx, err := build(changeAddress)
+	if err != nil {
+		w.releaseChange(addrType, changeIndex, false)
+		return nil, err
+	}
+	if tx.ChangeIndex < 0 {
+		w.releaseChange(addrType, changeIndex, false)
+		return tx, nil
+	}
+	tx.onSent = func(sendErr error) {
+		w.releaseChange(addrType, changeIndex, sendErr == nil)
+	}
+	return tx, nil
+}
+
+// DefaultGapLimit 地址发现时连续未使用地址的数量上限(BIP44)
+const DefaultGapLimit = 20
+
+// allAddressTypes 钱包支持的全部地址类型
+var allAddressTypes = []AddressType{AddressTypeLegacy, AddressTypeNestedSegwit, AddressTypeNativeSegwit, AddressTypeTaproot}
+
+// Discover 发现收款链和找零链上有交易记录的地址, 连续 gapLimit 个地址没有交易时停止, 更新下一个未使用的序号
+// gapLimit 小于 1 时使用 DefaultGapLimit
+func (w *Wallet) Discover(net NetParams, addrType AddressType, gapLimit int) error {
+	if gapLimit < 1 {
+		gapLimit = DefaultGapLimit
+	}
+	for _, branch := range []uint32{ExternalBranch, InternalBranch} {
+		for index, gap := uint32(0), 0; gap < gapLimit; index++ {
+			address, err := w.DeriveAddress(addrType, branch, index)
+			if err != nil {
+				return err
+			}
+			page, err := net.GetHistory(address, "")
+			if err != nil {
+				return log.WithError(err, "GetHistory failed")
+			}
+			if len(page.Transactions) == 0 {
+				gap++
+				continue
+			}
+			gap = 0
+			w.MarkUsed(addrType, branch, index)
+		}
+	}
+	return nil
+}
+
+// Unspents 查询钱包已使用地址上的全部 UTXO, addrTypes 为空时查询全部地址类型
+// 返回的 KeyRing 包含这些地址的私钥, 可用于签名
+func (w *Wallet) Unspents(net NetParams, addrTypes ...AddressType) ([]BtcUnspent, *KeyRing, error) {
+	if len(addrTypes) == 0 {
+		addrTypes = allAddressTypes
+	}
+	var unspents []BtcUnspent
+	ring := NewKeyRing(w.chain)
+	for _, addrType := range addrTypes {
+		err := w.rangeUsed(addrType, func(branch, index uint32, address btcutil.Address) error {
+			items, err := net.GetBtcUnspent(address, 0)
+			if err != nil {
+				return log.WithError(err, "GetBtcUnspent failed")
+			}
+			if len(items) == 0 {
+				return nil
+			}
+			account, err := w.DeriveAccount(addrType, branch, index)
+			if err != nil {
+				return err
+			}
+			ring.Add(address, account)
+			unspents = append(unspents, items...)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return applyCoins(w.coins, unspents), ring, nil
+}
+
+// Spend 对钱包全部已使用地址的 UTXO 统一选币, 找零发送到 changeType 找零链上新的地址, 返回已签名的交易
+// 每个输入使用其地址对应的派生私钥签名, 返回的交易使用 Broadcast 或 SendRawTransaction 广播
+func (w *Wallet) Spend(net NetParams, params []TransferParam, changeType AddressType, feePerKb int64) (*Transaction, error) {
+	unspents, ring, err := w.Unspents(net)
+	if err != nil {
+		return nil, err
+	}
+	if len(unspents) == 0 {
+		return nil, log.WithError(utils.AmountError, "no unspent outputs")
+	}
+	// 优先使用金额大的 UTXO, 减少输入数量
+	sort.SliceStable(unspents, func(i, j int) bool {
+		return unspents[i].Value > unspents[j].Value
+	})
+	tx, err := w.NewTransaction(changeType, unspents, params, feePerKb)
+	if err != nil {
+		return nil, log.WithError(err, "NewTransaction failed")
+	}
+	if err = tx.SignWithSecretsSource(ring); err != nil {
+		tx.SendResult(err)
+		return nil, log.WithError(err, "SignWithSecretsSource failed")
+	}
+	return tx, nil
+}
+
+// SpendCoins 只花费 outPoints(txid:vout) 指定的输出, 输出须属于钱包已使用的地址且未冻结, 返回已签名的交易
+func (w *Wallet) SpendCoins(net NetParams, outPoints []string, params []TransferParam, changeType AddressType, feePerKb int64) (*Transaction, error) {
+	unspents, ring, err := w.Unspents(net)
+	if err != nil {
+		return nil, err
+	}
+	coins, err := SelectCoins(unspents, outPoints...)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := w.NewTransactionWithCoins(changeType, coins, params, feePerKb)
+	if err != nil {
+		return nil, log.WithError(err, "NewTransactionWithCoins failed")
+	}
+	if err = tx.SignWithSecretsSource(ring); err != nil {
+		tx.SendResult(err)
+		return nil, log.WithError(err, "SignWithSecretsSource failed")
+	}
+	return tx, nil
+}
@@ -0,0 +1,204 @@
+package btc
+
+import (
+	"errors"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestWallet_DeriveAddress(t *testing.T) {
+	wallet, err := NewWallet(testMnemonic, utils.BtcChainMainNet)
+	assert.NoError(t, err)
+	// BIP44/49/84/86 测试向量
+	tests := []struct {
+		addrType AddressType
+		branch   uint32
+		path     string
+		address  string
+	}{
+		{AddressTypeLegacy, ExternalBranch, "m/44'/0'/0'/0/0", "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		{AddressTypeNestedSegwit, ExternalBranch, "m/49'/0'/0'/0/0", "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf"},
+		{AddressTypeNativeSegwit, ExternalBranch, "m/84'/0'/0'/0/0", "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{AddressTypeNativeSegwit, InternalBranch, "m/84'/0'/0'/1/0", "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"},
+		{AddressTypeTaproot, ExternalBranch, "m/86'/0'/0'/0/0", "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+	}
+	for _, tt := range tests {
+		path, err := wallet.DerivationPath(tt.addrType, tt.branch, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.path, path)
+		address, err := wallet.DeriveAddress(tt.addrType, tt.branch, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.address, address.EncodeAddress())
+	}
+
+	_, err = wallet.DeriveAddress(AddressType(9), ExternalBranch, 0)
+	assert.Equal(t, utils.ErrAddressTypeNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+	_, err = wallet.DeriveAddress(AddressTypeLegacy, 2, 0)
+	assert.Equal(t, utils.ErrInvalidValue.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestWallet_Indexes(t *testing.T) {
+	wallet, err := NewWallet(testMnemonic, utils.BtcChainTestNet3)
+	assert.NoError(t, err)
+
+	receive, index, err := wallet.NewReceiveAddress(AddressTypeNativeSegwit)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), index)
+	_, index, _ = wallet.NewReceiveAddress(AddressTypeNativeSegwit)
+	assert.Equal(t, uint32(1), index)
+	assert.Equal(t, uint32(0), wallet.NextIndex(AddressTypeLegacy, ExternalBranch))
+
+	// 找零地址在使用前不前进
+	change, index, err := wallet.ChangeAddress(AddressTypeNativeSegwit)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), index)
+	again, _, _ := wallet.ChangeAddress(AddressTypeNativeSegwit)
+	assert.Equal(t, change, again)
+	assert.NotEqual(t, receive.EncodeAddress(), change.EncodeAddress())
+
+	wallet.MarkUsed(AddressTypeNativeSegwit, InternalBranch, 0)
+	wallet.MarkUsed(AddressTypeNativeSegwit, ExternalBranch, 0)
+	assert.Equal(t, uint32(1), wallet.NextIndex(AddressTypeNativeSegwit, InternalBranch))
+	assert.Equal(t, uint32(2), wallet.NextIndex(AddressTypeNativeSegwit, ExternalBranch))
+
+	addresses, err := wallet.Addresses(AddressTypeNativeSegwit)
+	assert.NoError(t, err)
+	if assert.Len(t, addresses, 3) {
+		assert.Equal(t, receive, addresses[0])
+		assert.Equal(t, change, addresses[2])
+	}
+
+	wallet.SetNextIndex(AddressTypeLegacy, InternalBranch, 5)
+	assert.Equal(t, uint32(5), wallet.NextIndex(AddressTypeLegacy, InternalBranch))
+}
+
+func TestWallet_NewTransaction(t *testing.T) {
+	wallet, err := NewWallet(testMnemonic, utils.BtcChainTestNet3)
+	assert.NoError(t, err)
+	from, _, _ := wallet.NewReceiveAddress(AddressTypeNativeSegwit)
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", &chaincfg.TestNet3Params)
+	unspents := newTestUnspents(t, from.EncodeAddress(), 100000)
+
+	tx, err := wallet.NewTransaction(AddressTypeNativeSegwit, unspents, []TransferParam{{To: to, Amount: 50000}}, 1000)
+	assert.NoError(t, err)
+	change, _ := wallet.DeriveAddress(AddressTypeNativeSegwit, InternalBranch, 0)
+	changeScript, _ := txscript.PayToAddrScript(change)
+	if assert.GreaterOrEqual(t, tx.ChangeIndex, 0) {
+		assert.Equal(t, changeScript, tx.Tx.TxOut[tx.ChangeIndex].PkScript)
+	}
+	// 广播前只预留找零地址, 同时创建的交易使用下一个找零地址
+	assert.Equal(t, uint32(0), wallet.NextIndex(AddressTypeNativeSegwit, InternalBranch))
+	_, index, _ := wallet.ChangeAddress(AddressTypeNativeSegwit)
+	assert.Equal(t, uint32(1), index)
+
+	account, err := wallet.DeriveAccount(AddressTypeNativeSegwit, ExternalBranch, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+	_, err = tx.Broadcast(fakeNetParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), wallet.NextIndex(AddressTypeNativeSegwit, InternalBranch))
+
+	// 广播失败后释放找零地址
+	tx, err = wallet.NewTransaction(AddressTypeNativeSegwit, unspents, []TransferParam{{To: to, Amount: 50000}}, 1000)
+	assert.NoError(t, err)
+	tx.SendResult(errors.New("rejected"))
+	tx.SendResult(nil)
+	assert.Equal(t, uint32(1), wallet.NextIndex(AddressTypeNativeSegwit, InternalBranch))
+	_, index, _ = wallet.ChangeAddress(AddressTypeNativeSegwit)
+	assert.Equal(t, uint32(1), index)
+
+	// 没有找零时不占用找零地址
+	unspents = newTestUnspents(t, from.EncodeAddress(), 50200)
+	tx, err = wallet.NewTransaction(AddressTypeNativeSegwit, unspents, []TransferParam{{To: to, Amount: 50000}}, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, tx.ChangeIndex)
+	assert.Equal(t, uint32(1), wallet.NextIndex(AddressTypeNativeSegwit, InternalBranch))
+}
+
+// historyNetParams 在 fakeNetParams 的基础上按地址返回是否有交易记录
+type historyNetParams struct {
+	fakeNetParams
+	used map[string]bool
+}
+
+func (h *historyNetParams) GetHistory(address btcutil.Address, cursor string) (*HistoryPage, error) {
+	page := &HistoryPage{}
+	if h.used[address.EncodeAddress()] {
+		page.Transactions = append(page.Transactions, &base.TransactionDetail{})
+	}
+	return page, nil
+}
+
+func TestWallet_DiscoverAndSpend(t *testing.T) {
+	wallet, err := NewWallet(testMnemonic, utils.BtcChainTestNet3)
+	assert.NoError(t, err)
+	net := &historyNetParams{fakeNetParams: fakeNetParams{}, used: map[string]bool{}}
+	fund := func(addrType AddressType, branch, index uint32, value int64) {
+		address, _ := wallet.DeriveAddress(addrType, branch, index)
+		net.used[address.EncodeAddress()] = true
+		if value > 0 {
+			net.fakeNetParams[address.EncodeAddress()] = newTestUnspents(t, address.EncodeAddress(), value)
+		}
+	}
+	fund(AddressTypeNativeSegwit, ExternalBranch, 0, 30000)
+	fund(AddressTypeNativeSegwit, ExternalBranch, 1, 0)
+	fund(AddressTypeNativeSegwit, ExternalBranch, 4, 20000)
+	fund(AddressTypeNativeSegwit, InternalBranch, 1, 15000)
+	fund(AddressTypeNestedSegwit, ExternalBranch, 0, 60000)
+	// 超过间隔限制的地址不会被发现
+	fund(AddressTypeNativeSegwit, ExternalBranch, 20, 99999)
+
+	assert.NoError(t, wallet.Discover(net, AddressTypeNativeSegwit, 5))
+	assert.NoError(t, wallet.Discover(net, AddressTypeNestedSegwit, 0))
+	assert.Equal(t, uint32(5), wallet.NextIndex(AddressTypeNativeSegwit, ExternalBranch))
+	assert.Equal(t, uint32(2), wallet.NextIndex(AddressTypeNativeSegwit, InternalBranch))
+	assert.Equal(t, uint32(1), wallet.NextIndex(AddressTypeNestedSegwit, ExternalBranch))
+
+	unspents, ring, err := wallet.Unspents(net)
+	assert.NoError(t, err)
+	assert.Len(t, unspents, 4)
+	assert.Equal(t, 4, ring.Len())
+
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", &chaincfg.TestNet3Params)
+	tx, err := wallet.Spend(net, []TransferParam{{To: to, Amount: 70000}}, AddressTypeNativeSegwit, 1000)
+	assert.NoError(t, err)
+	// 金额最大的两个 UTXO 来自不同类型的地址
+	assert.Len(t, tx.Tx.TxIn, 2)
+	assert.Equal(t, btcutil.Amount(90000), tx.TotalInput)
+	change, _ := wallet.DeriveAddress(AddressTypeNativeSegwit, InternalBranch, 2)
+	changeScript, _ := txscript.PayToAddrScript(change)
+	if assert.GreaterOrEqual(t, tx.ChangeIndex, 0) {
+		assert.Equal(t, changeScript, tx.Tx.TxOut[tx.ChangeIndex].PkScript)
+	}
+	txId, err := tx.Broadcast(net)
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Tx.TxHash().String(), txId)
+	assert.Equal(t, uint32(3), wallet.NextIndex(AddressTypeNativeSegwit, InternalBranch))
+
+	_, err = wallet.Spend(net, []TransferParam{{To: to, Amount: 200000}}, AddressTypeNativeSegwit, 1000)
+	assert.Error(t, err)
+}
+
+func TestKeyRing_GetKey(t *testing.T) {
+	wallet, _ := NewWallet(testMnemonic, utils.BtcChainTestNet3)
+	account, _ := wallet.DeriveAccount(AddressTypeLegacy, ExternalBranch, 0)
+	address, _ := wallet.DeriveAddress(AddressTypeLegacy, ExternalBranch, 0)
+	other, _ := wallet.DeriveAddress(AddressTypeLegacy, ExternalBranch, 1)
+	ring := NewKeyRing(wallet.ChainParams())
+	ring.Add(address, account)
+
+	key, compressed, err := ring.GetKey(address)
+	assert.NoError(t, err)
+	assert.True(t, compressed)
+	assert.Equal(t, account.PrivateKey(), key.Serialize())
+
+	_, _, err = ring.GetKey(other)
+	assert.Equal(t, utils.ErrKeyNotFound.ErrCode, err.(*utils.Error).ErrCode)
+}
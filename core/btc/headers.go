@@ -0,0 +1,308 @@
+package btc
+
+import (
+	"fmt"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// medianTimeBlocks 计算区块中位时间使用的区块数
+	medianTimeBlocks = 11
+	// maxTimeOffset 区块时间最多超前本地时间 2 小时
+	maxTimeOffset = 2 * time.Hour
+	// maxTimewarp BIP94 难度周期第一个区块允许早于上一个区块的时间
+	maxTimewarp = 10 * time.Minute
+	// headerSyncBatch 每次同步的区块头数量
+	headerSyncBatch = 2016
+	// headerSyncRewind 同步遇到分叉时回退的区块数
+	headerSyncRewind = 100
+)
+
+// HeaderSource 区块头的数据来源, 如 Electrum 服务器
+type HeaderSource interface {
+	// GetBlockHeaders 获取从 startHeight 开始的最多 count 个区块头
+	GetBlockHeaders(startHeight int64, count int) ([]*wire.BlockHeader, error)
+}
+
+type headerEntry struct {
+	header wire.BlockHeader
+	hash   chainhash.Hash
+	height int64
+	work   *big.Int // 从检查点开始的累计工作量
+	parent *headerEntry
+}
+
+// HeaderStore 内存中的区块头链, 校验工作量证明、难度调整和链的连续性, 工作量最大的分支为主链
+// 只支持比特币的网络, 其它链的工作量证明或难度算法不同
+type HeaderStore struct {
+	params *chaincfg.Params
+
+	mu       sync.RWMutex
+	entries  map[chainhash.Hash]*headerEntry
+	best     []*headerEntry // 主链, 下标为高度减去检查点高度
+	baseline int64          // 检查点高度
+}
+
+// NewHeaderStore 从可信的检查点开始, checkpoint 为空时从创世区块开始
+func NewHeaderStore(params *chaincfg.Params, checkpoint *wire.BlockHeader, height int64) (*HeaderStore, error) {
+	if utils.BtcCoinType(params) != utils.BTC {
+		return nil, log.WithError(utils.ErrNotSupported, "NewHeaderStore failed")
+	}
+	if checkpoint == nil {
+		checkpoint, height = &params.GenesisBlock.Header, 0
+	}
+	root := &headerEntry{
+		header: *checkpoint,
+		hash:   checkpoint.BlockHash(),
+		height: height,
+		work:   blockchain.CalcWork(checkpoint.Bits),
+	}
+	return &HeaderStore{
+		params:   params,
+		entries:  map[chainhash.Hash]*headerEntry{root.hash: root},
+		best:     []*headerEntry{root},
+		baseline: height,
+	}, nil
+}
+
+// Tip 主链最新的区块
+func (s *HeaderStore) Tip() (chainhash.Hash, int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tip := s.best[len(s.best)-1]
+	return tip.hash, tip.height
+}
+
+// HeaderByHeight 主链上指定高度的区块头
+func (s *HeaderStore) HeaderByHeight(height int64) (*wire.BlockHeader, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry := s.bestAt(height)
+	if entry == nil {
+		return nil, log.WithError(utils.ErrUnknownBlock, fmt.Sprintf("height %d", height))
+	}
+	header := entry.header
+	return &header, nil
+}
+
+// HeaderByHash 获取区块头及其高度, 分叉上的区块 inBest 为 false
+func (s *HeaderStore) HeaderByHash(hash chainhash.Hash) (header *wire.BlockHeader, height int64, inBest bool, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[hash]
+	if !ok {
+		return nil, 0, false, log.WithError(utils.ErrUnknownBlock, hash.String())
+	}
+	h := entry.header
+	return &h, entry.height, s.bestAt(entry.height) == entry, nil
+}
+
+func (s *HeaderStore) bestAt(height int64) *headerEntry {
+	index := height - s.baseline
+	if index < 0 || index >= int64(len(s.best)) {
+		return nil
+	}
+	return s.best[index]
+}
+
+// AddHeaders 按顺序添加区块头, 已存在的跳过, 遇到无效的区块头时停止并返回错误
+func (s *HeaderStore) AddHeaders(headers ...*wire.BlockHeader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, header := range headers {
+		if err := s.addHeader(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *HeaderStore) addHeader(header *wire.BlockHeader) error {
+	hash := header.BlockHash()
+	if _, ok := s.entries[hash]; ok {
+		return nil
+	}
+	parent, ok := s.entries[header.PrevBlock]
+	if !ok {
+		return log.WithError(utils.ErrInvalidHeader, "orphan header "+hash.String())
+	}
+	if err := s.checkHeader(header, &hash, parent); err != nil {
+		return log.WithError(utils.ErrInvalidHeader, err.Error())
+	}
+	entry := &headerEntry{
+		header: *header,
+		hash:   hash,
+		height: parent.height + 1,
+		work:   new(big.Int).Add(parent.work, blockchain.CalcWork(header.Bits)),
+		parent: parent,
+	}
+	s.entries[hash] = entry
+
+	// 工作量更大的分支成为主链, 重组时替换分叉点之后的区块
+	if entry.work.Cmp(s.best[len(s.best)-1].work) <= 0 {
+		return nil
+	}
+	var branch []*headerEntry
+	for e := entry; s.bestAt(e.height) != e; e = e.parent {
+		branch = append(branch, e)
+	}
+	forkIndex := branch[len(branch)-1].height - s.baseline
+	s.best = s.best[:forkIndex]
+	for i := len(branch) - 1; i >= 0; i-- {
+		s.best = append(s.best, branch[i])
+	}
+	return nil
+}
+
+// checkHeader 校验工作量证明、难度和时间戳
+func (s *HeaderStore) checkHeader(header *wire.BlockHeader, hash *chainhash.Hash, parent *headerEntry) error {
+	target := blockchain.CompactToBig(header.Bits)
+	if target.Sign() <= 0 || target.Cmp(s.params.PowLimit) > 0 {
+		return fmt.Errorf("target out of range")
+	}
+	if blockchain.HashToBig(hash).Cmp(target) > 0 {
+		return fmt.Errorf("hash %s above target", hash)
+	}
+	if bits, ok := s.expectedBits(header, parent); ok && bits != header.Bits {
+		return fmt.Errorf("bits %08x, expected %08x", header.Bits, bits)
+	}
+	if !header.Timestamp.After(s.medianTime(parent)) {
+		return fmt.Errorf("timestamp before median time")
+	}
+	// BIP94: 难度周期的第一个区块时间不能比上一个区块早 10 分钟以上
+	interval := int64(s.params.TargetTimespan / s.params.TargetTimePerBlock)
+	if utils.EnforceBIP94(s.params) && (parent.height+1)%interval == 0 &&
+		header.Timestamp.Before(parent.header.Timestamp.Add(-maxTimewarp)) {
+		return fmt.Errorf("timewarp attack")
+	}
+	if header.Timestamp.After(time.Now().Add(maxTimeOffset)) {
+		return fmt.Errorf("timestamp too far in the future")
+	}
+	return nil
+}
+
+// expectedBits 计算下一个区块的难度, 需要的历史区块不在本地时返回 false
+func (s *HeaderStore) expectedBits(header *wire.BlockHeader, parent *headerEntry) (uint32, bool) {
+	interval := int64(s.params.TargetTimespan / s.params.TargetTimePerBlock)
+	height := parent.height + 1
+	if height%interval != 0 {
+		if !s.params.ReduceMinDifficulty {
+			return parent.header.Bits, true
+		}
+		// 测试网: 超过两倍出块时间可以使用最低难度, 否则使用本周期最后一个非最低难度的区块
+		if header.Timestamp.After(parent.header.Timestamp.Add(s.params.MinDiffReductionTime)) {
+			return s.params.PowLimitBits, true
+		}
+		for e := parent; e != nil; e = e.parent {
+			if e.height%interval == 0 || e.header.Bits != s.params.PowLimitBits {
+				return e.header.Bits, true
+			}
+		}
+		return 0, false
+	}
+
+	first := parent
+	for first != nil && first.height > height-interval {
+		first = first.parent
+	}
+	if first == nil {
+		return 0, false
+	}
+	timespan := int64(parent.header.Timestamp.Sub(first.header.Timestamp) / time.Second)
+	targetTimespan := int64(s.params.TargetTimespan / time.Second)
+	minTimespan := targetTimespan / s.params.RetargetAdjustmentFactor
+	maxTimespan := targetTimespan * s.params.RetargetAdjustmentFactor
+	if timespan < minTimespan {
+		timespan = minTimespan
+	} else if timespan > maxTimespan {
+		timespan = maxTimespan
+	}
+	// BIP94 使用周期第一个区块的难度, 避免周期最后一个区块为最低难度时影响调整
+	target := blockchain.CompactToBig(parent.header.Bits)
+	if utils.EnforceBIP94(s.params) {
+		target = blockchain.CompactToBig(first.header.Bits)
+	}
+	target.Mul(target, big.NewInt(timespan))
+	target.Div(target, big.NewInt(targetTimespan))
+	if target.Cmp(s.params.PowLimit) > 0 {
+		target.Set(s.params.PowLimit)
+	}
+	return blockchain.BigToCompact(target), true
+}
+
+// medianTime 最近 11 个区块时间的中位数, 本地区块不足时使用已有的区块
+func (s *HeaderStore) medianTime(parent *headerEntry) time.Time {
+	timestamps := make([]int64, 0, medianTimeBlocks)
+	for e := parent; e != nil && len(timestamps) < medianTimeBlocks; e = e.parent {
+		timestamps = append(timestamps, e.header.Timestamp.Unix())
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return time.Unix(timestamps[len(timestamps)/2], 0)
+}
+
+// VerifyProof 校验默克尔证明, 返回交易在主链上的确认数
+func (s *HeaderStore) VerifyProof(proof *MerkleProof) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entry *headerEntry
+	if proof.BlockHash != nil {
+		if entry = s.entries[*proof.BlockHash]; entry != nil && s.bestAt(entry.height) != entry {
+			return 0, log.WithError(utils.ErrUnknownBlock, "block not in best chain")
+		}
+	} else {
+		entry = s.bestAt(proof.BlockHeight)
+	}
+	if entry == nil {
+		return 0, log.WithError(utils.ErrUnknownBlock, "VerifyProof failed")
+	}
+	if entry.header.MerkleRoot != proof.MerkleRoot {
+		return 0, log.WithError(utils.ErrInvalidProof, "merkle root mismatch")
+	}
+	return s.best[len(s.best)-1].height - entry.height + 1, nil
+}
+
+// Sync 从数据源同步区块头到最新, 返回新的链顶高度
+func (s *HeaderStore) Sync(source HeaderSource) (int64, error) {
+	for {
+		_, tipHeight := s.Tip()
+		headers, err := source.GetBlockHeaders(tipHeight+1, headerSyncBatch)
+		if err != nil {
+			return tipHeight, log.WithError(err, "GetBlockHeaders failed")
+		}
+		if len(headers) == 0 {
+			return tipHeight, nil
+		}
+		// 数据源的链发生了重组, 从更早的高度重新获取以找到分叉点
+		if _, ok := s.header(headers[0].PrevBlock); !ok {
+			start := tipHeight + 1 - headerSyncRewind
+			if start <= s.baseline {
+				start = s.baseline + 1
+			}
+			if headers, err = source.GetBlockHeaders(start, headerSyncBatch); err != nil {
+				return tipHeight, log.WithError(err, "GetBlockHeaders failed")
+			}
+		}
+		if err = s.AddHeaders(headers...); err != nil {
+			return tipHeight, err
+		}
+		if _, newHeight := s.Tip(); newHeight == tipHeight || len(headers) < headerSyncBatch {
+			return newHeight, nil
+		}
+	}
+}
+
+func (s *HeaderStore) header(hash chainhash.Hash) (*headerEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[hash]
+	return entry, ok
+}
@@ -0,0 +1,207 @@
+package btc
+
+import (
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+	"time"
+)
+
+const tenMinutes = 10 * time.Minute
+
+// mineTestHeader 按 regtest 的最低难度挖出区块头
+func mineTestHeader(prev *wire.BlockHeader, timestamp time.Time, merkleRoot chainhash.Hash) *wire.BlockHeader {
+	return mineTestHeaderBits(prev, timestamp, merkleRoot, chaincfg.RegressionNetParams.PowLimitBits)
+}
+
+func mineTestHeaderBits(prev *wire.BlockHeader, timestamp time.Time, merkleRoot chainhash.Hash, bits uint32) *wire.BlockHeader {
+	header := &wire.BlockHeader{
+		Version:    1,
+		PrevBlock:  prev.BlockHash(),
+		MerkleRoot: merkleRoot,
+		Timestamp:  timestamp,
+		Bits:       bits,
+	}
+	target := blockchain.CompactToBig(bits)
+	for {
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return header
+		}
+		header.Nonce++
+	}
+}
+
+func mineTestChain(prev *wire.BlockHeader, n int, tag byte) []*wire.BlockHeader {
+	headers := make([]*wire.BlockHeader, n)
+	for i := range headers {
+		headers[i] = mineTestHeader(prev, prev.Timestamp.Add(tenMinutes), chainhash.HashH([]byte{tag, byte(i)}))
+		prev = headers[i]
+	}
+	return headers
+}
+
+func TestHeaderStore_AddHeaders(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	store, err := NewHeaderStore(params, nil, 0)
+	assert.NoError(t, err)
+	headers := mineTestChain(&params.GenesisBlock.Header, 5, 'a')
+	assert.NoError(t, store.AddHeaders(headers...))
+	// 重复添加被忽略
+	assert.NoError(t, store.AddHeaders(headers[2:]...))
+
+	tip, height := store.Tip()
+	assert.Equal(t, headers[4].BlockHash(), tip)
+	assert.Equal(t, int64(5), height)
+	header, err := store.HeaderByHeight(3)
+	assert.NoError(t, err)
+	assert.Equal(t, *headers[2], *header)
+	_, err = store.HeaderByHeight(6)
+	assert.Equal(t, utils.ErrUnknownBlock.ErrCode, err.(*utils.Error).ErrCode)
+
+	// 不连续的区块头
+	orphan := mineTestChain(headers[4], 2, 'b')[1]
+	err = store.AddHeaders(orphan)
+	assert.Equal(t, utils.ErrInvalidHeader.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestHeaderStore_InvalidHeaders(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	store, _ := NewHeaderStore(params, nil, 0)
+	genesis := &params.GenesisBlock.Header
+	root := chainhash.HashH([]byte("root"))
+
+	// 工作量不足
+	header := mineTestHeader(genesis, genesis.Timestamp.Add(tenMinutes), root)
+	for {
+		header.Nonce++
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(params.PowLimit) > 0 {
+			break
+		}
+	}
+	tests := []*wire.BlockHeader{
+		header,
+		// 难度低于网络下限
+		mineTestHeaderBits(genesis, genesis.Timestamp.Add(tenMinutes), root, 0x2100ffff),
+		// 难度与上一个区块不符
+		mineTestHeaderBits(genesis, genesis.Timestamp.Add(tenMinutes), root, 0x1f7fffff),
+		// 时间早于中位时间
+		mineTestHeader(genesis, genesis.Timestamp, root),
+		// 时间超前太多
+		mineTestHeader(genesis, time.Now().Add(3*time.Hour), root),
+	}
+	for i, tt := range tests {
+		err := store.AddHeaders(tt)
+		if assert.Error(t, err, i) {
+			assert.Equal(t, utils.ErrInvalidHeader.ErrCode, err.(*utils.Error).ErrCode, i)
+		}
+	}
+	_, height := store.Tip()
+	assert.Equal(t, int64(0), height)
+}
+
+func TestHeaderStore_Reorg(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	store, _ := NewHeaderStore(params, nil, 0)
+	genesis := &params.GenesisBlock.Header
+	chainA := mineTestChain(genesis, 3, 'a')
+	chainB := mineTestChain(chainA[0], 3, 'b')
+	assert.NoError(t, store.AddHeaders(chainA...))
+
+	proofA := &MerkleProof{MerkleRoot: chainA[2].MerkleRoot, BlockHeight: 3}
+	confirmations, err := store.VerifyProof(proofA)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), confirmations)
+
+	// 分叉的工作量相同时不切换
+	assert.NoError(t, store.AddHeaders(chainB[:2]...))
+	tip, _ := store.Tip()
+	assert.Equal(t, chainA[2].BlockHash(), tip)
+
+	assert.NoError(t, store.AddHeaders(chainB[2]))
+	tip, height := store.Tip()
+	assert.Equal(t, chainB[2].BlockHash(), tip)
+	assert.Equal(t, int64(4), height)
+
+	// 被重组掉的区块
+	_, err = store.VerifyProof(proofA)
+	assert.Equal(t, utils.ErrInvalidProof.ErrCode, err.(*utils.Error).ErrCode)
+	hashA := chainA[2].BlockHash()
+	_, err = store.VerifyProof(&MerkleProof{MerkleRoot: chainA[2].MerkleRoot, BlockHash: &hashA})
+	assert.Equal(t, utils.ErrUnknownBlock.ErrCode, err.(*utils.Error).ErrCode)
+	_, _, inBest, err := store.HeaderByHash(hashA)
+	assert.NoError(t, err)
+	assert.False(t, inBest)
+
+	// 共同的区块确认数增加
+	hash1 := chainA[0].BlockHash()
+	confirmations, err = store.VerifyProof(&MerkleProof{MerkleRoot: chainA[0].MerkleRoot, BlockHash: &hash1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), confirmations)
+}
+
+func TestHeaderStore_Checkpoint(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	// 主网 0 到 1 号区块
+	checkpoint := &params.GenesisBlock.Header
+	store, err := NewHeaderStore(params, checkpoint, 0)
+	assert.NoError(t, err)
+	block1 := &wire.BlockHeader{
+		Version:    1,
+		PrevBlock:  *params.GenesisHash,
+		MerkleRoot: mustHash("0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098"),
+		Timestamp:  time.Unix(1231469665, 0),
+		Bits:       0x1d00ffff,
+		Nonce:      2573394689,
+	}
+	assert.NoError(t, store.AddHeaders(block1))
+	tip, _ := store.Tip()
+	assert.Equal(t, "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048", tip.String())
+
+	_, err = NewHeaderStore(&utils.LtcMainNetParams, checkpoint, 0)
+	assert.Equal(t, utils.ErrNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func mustHash(s string) chainhash.Hash {
+	hash, err := chainhash.NewHashFromStr(s)
+	if err != nil {
+		panic(err)
+	}
+	return *hash
+}
+
+func TestHeaderStore_BIP94(t *testing.T) {
+	// 使用 regtest 的难度下限, 避免挖矿耗时
+	testnet4 := utils.TestNet4Params
+	testnet4.PowLimit, testnet4.PowLimitBits = chaincfg.RegressionNetParams.PowLimit, chaincfg.RegressionNetParams.PowLimitBits
+	testnet3 := testnet4
+	testnet3.Net = chaincfg.TestNet3Params.Net
+
+	checkpoint := &chaincfg.RegressionNetParams.GenesisBlock.Header
+	var headers []*wire.BlockHeader
+	prev := checkpoint
+	for i := 1; i < 2016; i++ {
+		prev = mineTestHeader(prev, prev.Timestamp.Add(11*time.Minute), chainhash.HashH([]byte{byte(i), byte(i >> 8)}))
+		headers = append(headers, prev)
+	}
+	// 难度周期的第一个区块比上一个区块早 11 分钟
+	timewarp := mineTestHeader(prev, prev.Timestamp.Add(-11*time.Minute), chainhash.HashH([]byte("timewarp")))
+
+	for _, params := range []*chaincfg.Params{&testnet4, &testnet3} {
+		store, err := NewHeaderStore(params, checkpoint, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, store.AddHeaders(headers...))
+		err = store.AddHeaders(timewarp)
+		if utils.EnforceBIP94(params) {
+			assert.Equal(t, utils.ErrInvalidHeader.ErrCode, err.(*utils.Error).ErrCode)
+			assert.NoError(t, store.AddHeaders(mineTestHeader(prev, prev.Timestamp.Add(-9*time.Minute), chainhash.HashH([]byte("ok")))))
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
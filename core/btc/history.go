@@ -0,0 +1,29 @@
+package btc
+
+import (
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"math/big"
+)
+
+// HistoryPage 地址交易历史的一页
+type HistoryPage struct {
+	Transactions []*base.TransactionDetail // 未确认的交易在前, 已确认的按区块倒序
+	NextCursor   string                    // 下一页的游标, 为空表示没有更多
+}
+
+// SetNetValue 根据输入输出计算交易对 address 的余额变化, 写入 detail.NetValue
+func SetNetValue(detail *base.TransactionDetail, address string) *big.Int {
+	value := new(big.Int)
+	for _, in := range detail.Inputs {
+		if in.Address == address && in.Value != nil {
+			value.Sub(value, in.Value)
+		}
+	}
+	for _, out := range detail.Outputs {
+		if out.Address == address && out.Value != nil {
+			value.Add(value, out.Value)
+		}
+	}
+	detail.NetValue = value
+	return value
+}
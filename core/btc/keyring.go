@@ -0,0 +1,55 @@
+package btc
+
+import (
+	"errors"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"sync"
+)
+
+// KeyRing 按地址查找私钥, 实现 txauthor.SecretsSource, 用于签名来自多个地址的输入
+type KeyRing struct {
+	chain *chaincfg.Params
+
+	mu       sync.RWMutex
+	accounts map[string]*Account
+}
+
+func NewKeyRing(chain *chaincfg.Params) *KeyRing {
+	return &KeyRing{chain: chain, accounts: make(map[string]*Account)}
+}
+
+// Add 添加地址及其账户, 地址为输出脚本中的地址, 如 P2SH-P2WPKH 使用 P2SH 地址
+func (r *KeyRing) Add(address btcutil.Address, account *Account) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accounts[address.EncodeAddress()] = account
+}
+
+// Len 地址数量
+func (r *KeyRing) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.accounts)
+}
+
+func (r *KeyRing) GetKey(addr btcutil.Address) (*btcec.PrivateKey, bool, error) {
+	r.mu.RLock()
+	account, ok := r.accounts[addr.EncodeAddress()]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, false, log.WithError(utils.ErrKeyNotFound, addr.EncodeAddress())
+	}
+	return account.GetKey(addr)
+}
+
+func (r *KeyRing) GetScript(addr btcutil.Address) ([]byte, error) {
+	return nil, errors.New("GetScript not supported")
+}
+
+func (r *KeyRing) ChainParams() *chaincfg.Params {
+	return r.chain
+}
@@ -0,0 +1,325 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"strings"
+)
+
+const (
+	// SignedMessagePrefix 传统消息签名的前缀
+	SignedMessagePrefix = "Bitcoin Signed Message:\n"
+	// BIP322Tag BIP322 消息哈希的标签
+	BIP322Tag = "BIP0322-signed-message"
+)
+
+// BIP137 签名头, 签名头 = 起始值 + recid
+const (
+	headerUncompressed byte = 27
+	headerCompressed   byte = 31
+	headerNestedSegwit byte = 35
+	headerNativeSegwit byte = 39
+	headerMax          byte = 42
+)
+
+// MessageHash 传统消息签名的哈希
+func MessageHash(message string) []byte {
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, SignedMessagePrefix)
+	wire.WriteVarString(&buf, 0, message)
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
+// SignMessage 传统消息签名(signmessage), 返回 base64 编码的签名
+// 隔离见证地址使用 Electrum/Trezor 的签名头, 不支持 Taproot 地址
+func (a *Account) SignMessage(message string, addrType AddressType) (string, error) {
+	compressed := a.address.Format() == btcutil.PKFCompressed
+	sig, err := ecdsa.SignCompact(a.privateKey, MessageHash(message), compressed)
+	if err != nil {
+		return "", log.WithError(err, "SignCompact failed")
+	}
+	switch addrType {
+	case AddressTypeLegacy:
+	case AddressTypeNestedSegwit, AddressTypeNativeSegwit:
+		if !compressed {
+			return "", log.WithError(utils.ErrAddressTypeNotSupported, "uncompressed key")
+		}
+		if addrType == AddressTypeNestedSegwit {
+			sig[0] += headerNestedSegwit - headerCompressed
+		} else {
+			sig[0] += headerNativeSegwit - headerCompressed
+		}
+	default:
+		return "", log.WithError(utils.ErrAddressTypeNotSupported, "SignMessage failed")
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// SignMessageBIP322 BIP322 消息签名, 支持 P2WPKH 和 P2TR 地址
+// full 为 true 时返回完整的 to_sign 交易, 否则只返回见证数据(simple)
+func (a *Account) SignMessageBIP322(message string, addrType AddressType, full bool) (string, error) {
+	address, err := a.AddressOfType(addrType)
+	if err != nil {
+		return "", log.WithError(err, "AddressOfType failed")
+	}
+	pkScript, err := addressScript(address, a.chain)
+	if err != nil {
+		return "", log.WithError(err, "addressScript failed")
+	}
+	toSign, err := newBIP322ToSign(message, pkScript)
+	if err != nil {
+		return "", log.WithError(err, "newBIP322ToSign failed")
+	}
+
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	sigHashes := txscript.NewTxSigHashes(toSign, fetcher)
+	var witness wire.TxWitness
+	switch addrType {
+	case AddressTypeNativeSegwit:
+		witness, err = txscript.WitnessSignature(toSign, sigHashes, 0, 0, pkScript,
+			txscript.SigHashAll, a.privateKey, a.address.Format() == btcutil.PKFCompressed)
+	case AddressTypeTaproot:
+		witness, err = txscript.TaprootWitnessSignature(toSign, sigHashes, 0, 0, pkScript,
+			txscript.SigHashDefault, a.privateKey)
+	default:
+		return "", log.WithError(utils.ErrAddressTypeNotSupported, "SignMessageBIP322 failed")
+	}
+	if err != nil {
+		return "", log.WithError(err, "WitnessSignature failed")
+	}
+	toSign.TxIn[0].Witness = witness
+
+	var buf bytes.Buffer
+	if full {
+		err = toSign.Serialize(&buf)
+	} else {
+		err = writeWitness(&buf, witness)
+	}
+	if err != nil {
+		return "", log.WithError(err, "Serialize failed")
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// VerifyMessage 验证消息签名, 根据签名格式自动识别传统签名和 BIP322 签名
+func VerifyMessage(address, message, signature string, chainId int) (bool, error) {
+	params, err := utils.GetBtcChainParams(chainId)
+	if err != nil {
+		return false, log.WithError(err, "ChainID failed")
+	}
+	addr, err := btcutil.DecodeAddress(address, params)
+	if err != nil {
+		return false, log.WithError(err, "DecodeAddress failed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, log.WithError(utils.ErrInvalidSignature, "base64 decode failed")
+	}
+	// BIP322 simple 签名首字节为见证数量, 不会落在签名头的范围内
+	if len(sig) == 65 && sig[0] >= headerUncompressed && sig[0] <= headerMax {
+		return verifyLegacyMessage(addr, message, sig, params)
+	}
+	return verifyBIP322Message(addr, message, sig)
+}
+
+// verifyLegacyMessage 恢复公钥后比对地址
+func verifyLegacyMessage(addr btcutil.Address, message string, sig []byte, params *chaincfg.Params) (bool, error) {
+	header := sig[0]
+	compact := make([]byte, len(sig))
+	copy(compact, sig)
+	if header >= headerNestedSegwit {
+		compact[0] = headerCompressed + (header-headerNestedSegwit)%4
+	}
+	pubKey, compressed, err := ecdsa.RecoverCompact(compact, MessageHash(message))
+	if err != nil {
+		return false, nil
+	}
+
+	var candidates []AddressType
+	switch {
+	case header >= headerNativeSegwit:
+		candidates = []AddressType{AddressTypeNativeSegwit}
+	case header >= headerNestedSegwit:
+		candidates = []AddressType{AddressTypeNestedSegwit}
+	case compressed:
+		// Electrum 对所有地址类型都使用压缩公钥的签名头
+		candidates = []AddressType{AddressTypeLegacy, AddressTypeNestedSegwit, AddressTypeNativeSegwit}
+	default:
+		candidates = []AddressType{AddressTypeLegacy}
+	}
+	for _, addrType := range candidates {
+		derived, err := pubKeyAddress(pubKey, compressed, addrType, params)
+		if err != nil {
+			return false, log.WithError(err, "pubKeyAddress failed")
+		}
+		if derived == addr.EncodeAddress() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// verifyBIP322Message 使用脚本引擎执行 to_sign 交易
+func verifyBIP322Message(addr btcutil.Address, message string, sig []byte) (bool, error) {
+	switch addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		// P2PKH 地址只能使用传统签名
+		return false, log.WithError(utils.ErrInvalidSignature, "verifyBIP322Message failed")
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return false, log.WithError(err, "PayToAddrScript failed")
+	}
+	toSign, err := newBIP322ToSign(message, pkScript)
+	if err != nil {
+		return false, log.WithError(err, "newBIP322ToSign failed")
+	}
+
+	// 依次尝试 simple 和 full 格式
+	if witness, err := readWitness(sig); err == nil {
+		toSign.TxIn[0].Witness = witness
+	} else {
+		signed := wire.NewMsgTx(0)
+		if err = signed.Deserialize(bytes.NewReader(sig)); err != nil {
+			return false, log.WithError(utils.ErrInvalidSignature, "Deserialize failed")
+		}
+		if len(signed.TxIn) != 1 || len(signed.TxOut) != 1 ||
+			signed.TxIn[0].PreviousOutPoint != toSign.TxIn[0].PreviousOutPoint ||
+			!bytes.Equal(signed.TxOut[0].PkScript, toSign.TxOut[0].PkScript) || signed.TxOut[0].Value != 0 {
+			return false, nil
+		}
+		toSign = signed
+	}
+
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	vm, err := txscript.NewEngine(pkScript, toSign, 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(toSign, fetcher), 0, fetcher)
+	if err != nil {
+		return false, log.WithError(err, "NewEngine failed")
+	}
+	return vm.Execute() == nil, nil
+}
+
+// BIP322MessageHash BIP322 的消息哈希
+func BIP322MessageHash(message string) []byte {
+	return chainhash.TaggedHash([]byte(BIP322Tag), []byte(message))[:]
+}
+
+// newBIP322ToSpend 构造 to_spend 虚拟交易
+func newBIP322ToSpend(message string, pkScript []byte) (*wire.MsgTx, error) {
+	sigScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).
+		AddData(BIP322MessageHash(message)).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(0)
+	in := wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), sigScript, nil)
+	in.Sequence = 0
+	tx.AddTxIn(in)
+	tx.AddTxOut(wire.NewTxOut(0, pkScript))
+	return tx, nil
+}
+
+// newBIP322ToSign 构造未签名的 to_sign 虚拟交易
+func newBIP322ToSign(message string, pkScript []byte) (*wire.MsgTx, error) {
+	toSpend, err := newBIP322ToSpend(message, pkScript)
+	if err != nil {
+		return nil, err
+	}
+	hash := toSpend.TxHash()
+	tx := wire.NewMsgTx(0)
+	in := wire.NewTxIn(wire.NewOutPoint(&hash, 0), nil, nil)
+	in.Sequence = 0
+	tx.AddTxIn(in)
+	tx.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+	return tx, nil
+}
+
+func writeWitness(buf *bytes.Buffer, witness wire.TxWitness) error {
+	if err := wire.WriteVarInt(buf, 0, uint64(len(witness))); err != nil {
+		return err
+	}
+	for _, item := range witness {
+		if err := wire.WriteVarBytes(buf, 0, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readWitness 解析 simple 签名, 必须恰好读完全部数据
+func readWitness(data []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(data)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 || count > uint64(len(data)) {
+		return nil, utils.ErrInvalidSignature
+	}
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		if witness[i], err = wire.ReadVarBytes(r, 0, txscript.MaxScriptSize, "witness"); err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, utils.ErrInvalidSignature
+	}
+	return witness, nil
+}
+
+// pubKeyAddress 由公钥生成指定类型的地址
+func pubKeyAddress(pubKey *btcec.PublicKey, compressed bool, addrType AddressType, params *chaincfg.Params) (string, error) {
+	serialized := pubKey.SerializeUncompressed()
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	}
+	pubKeyHash := btcutil.Hash160(serialized)
+	switch addrType {
+	case AddressTypeLegacy:
+		address, err := btcutil.NewAddressPubKeyHash(pubKeyHash, params)
+		if err != nil {
+			return "", err
+		}
+		return address.EncodeAddress(), nil
+	case AddressTypeNativeSegwit, AddressTypeNestedSegwit:
+		witAddr, err := btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, params)
+		if err != nil {
+			return "", err
+		}
+		if addrType == AddressTypeNativeSegwit {
+			return witAddr.EncodeAddress(), nil
+		}
+		witnessProgram, err := txscript.PayToAddrScript(witAddr)
+		if err != nil {
+			return "", err
+		}
+		address, err := btcutil.NewAddressScriptHash(witnessProgram, params)
+		if err != nil {
+			return "", err
+		}
+		return address.EncodeAddress(), nil
+	}
+	return "", utils.ErrAddressTypeNotSupported
+}
+
+// addressScript 地址对应的锁定脚本
+func addressScript(address string, params *chaincfg.Params) ([]byte, error) {
+	addr, err := btcutil.DecodeAddress(strings.TrimSpace(address), params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(addr)
+}
@@ -0,0 +1,124 @@
+package btc
+
+import (
+	"encoding/hex"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+// BIP322 文档中的测试向量
+const (
+	bip322PrivateKey = "L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k"
+	bip322Address    = "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l"
+	bip322Taproot    = "bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3"
+)
+
+func TestBIP322MessageHash(t *testing.T) {
+	assert.Equal(t, "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1", hex.EncodeToString(BIP322MessageHash("")))
+	assert.Equal(t, "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a", hex.EncodeToString(BIP322MessageHash("Hello World")))
+}
+
+func TestNewBIP322ToSign(t *testing.T) {
+	pkScript, _ := addressScript(bip322Address, &chaincfg.MainNetParams)
+	tests := []struct {
+		message string
+		toSpend string
+		toSign  string
+	}{
+		{"", "c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7", "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6"},
+		{"Hello World", "b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b", "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf"},
+	}
+	for _, tt := range tests {
+		toSpend, err := newBIP322ToSpend(tt.message, pkScript)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.toSpend, toSpend.TxHash().String())
+		toSign, err := newBIP322ToSign(tt.message, pkScript)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.toSign, toSign.TxHash().String())
+	}
+}
+
+func TestVerifyMessage_BIP322Vectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		address   string
+		message   string
+		signature string
+		want      bool
+	}{
+		{"p2wpkh empty", bip322Address, "", "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=", true},
+		{"p2wpkh hello", bip322Address, "Hello World", "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=", true},
+		{"p2wpkh wrong message", bip322Address, "", "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=", false},
+		{"p2tr hello", bip322Taproot, "Hello World", "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ==", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyMessage(tt.address, tt.message, tt.signature, utils.BtcChainMainNet)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAccount_SignMessageBIP322(t *testing.T) {
+	account, err := NewAccountWithPrivateKey(bip322PrivateKey, utils.BtcChainMainNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addrType := range []AddressType{AddressTypeNativeSegwit, AddressTypeTaproot} {
+		address, _ := account.AddressOfType(addrType)
+		for _, full := range []bool{false, true} {
+			sig, err := account.SignMessageBIP322("proof of reserves", addrType, full)
+			assert.NoError(t, err)
+			ok, err := VerifyMessage(address, "proof of reserves", sig, utils.BtcChainMainNet)
+			assert.NoError(t, err)
+			assert.True(t, ok, "type %d full %v", addrType, full)
+			ok, _ = VerifyMessage(address, "proof of reserve", sig, utils.BtcChainMainNet)
+			assert.False(t, ok)
+		}
+	}
+
+	_, err = account.SignMessageBIP322("Hello World", AddressTypeLegacy, false)
+	assert.Equal(t, utils.ErrAddressTypeNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestAccount_SignMessage(t *testing.T) {
+	account, err := NewAccountWithPrivateKey("cTFhdQbsU1xQfziSbM3FYz21a1NX6ukms12w5B3jTq1ZSXDQZqVN", utils.BtcChainTestNet3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addrType AddressType
+		address  string
+	}{
+		{AddressTypeLegacy, "n43tW32TTVfapiTEstqmhAAoasEcRdAJEm"},
+		{AddressTypeNestedSegwit, "2MuKWyXzED48Rag2WrrLC97BgtCuteUzLDS"},
+		{AddressTypeNativeSegwit, "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732"},
+	}
+	for _, tt := range tests {
+		sig, err := account.SignMessage("hdwallet", tt.addrType)
+		assert.NoError(t, err)
+		ok, err := VerifyMessage(tt.address, "hdwallet", sig, utils.BtcChainTestNet3)
+		assert.NoError(t, err)
+		assert.True(t, ok, "type %d", tt.addrType)
+
+		ok, _ = VerifyMessage(tt.address, "hdwallet2", sig, utils.BtcChainTestNet3)
+		assert.False(t, ok)
+	}
+
+	// 隔离见证签名头与地址类型不匹配
+	sig, _ := account.SignMessage("hdwallet", AddressTypeNestedSegwit)
+	ok, _ := VerifyMessage("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", "hdwallet", sig, utils.BtcChainTestNet3)
+	assert.False(t, ok)
+
+	// Electrum 对隔离见证地址也使用普通签名头
+	sig, _ = account.SignMessage("hdwallet", AddressTypeLegacy)
+	ok, err = VerifyMessage("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", "hdwallet", sig, utils.BtcChainTestNet3)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = account.SignMessage("hdwallet", AddressTypeTaproot)
+	assert.Equal(t, utils.ErrAddressTypeNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+}
@@ -0,0 +1,174 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+)
+
+// UnsignedTxFormatVersion 未签名交易格式的版本
+const UnsignedTxFormatVersion = 1
+
+// UnsignedTransaction 可移植的未签名交易, 由联网的观察钱包生成, 交给离线设备签名
+type UnsignedTransaction struct {
+	Version     int      `json:"version"`     // 格式版本
+	Network     string   `json:"network"`     // 网络名称, 如 MainNet、TestNet3、ltc-mainnet
+	Tx          string   `json:"tx"`          // 未签名交易的十六进制
+	PrevScripts []string `json:"prevScripts"` // 引用输出的锁定脚本, 与输入一一对应
+	InputValues []int64  `json:"inputValues"` // 引用输出的金额
+	ChangeIndex int      `json:"changeIndex"` // 找零输出的序号, 没有找零为 -1
+	FeePerKb    int64    `json:"feePerKb"`
+}
+
+// ExportUnsigned 导出未签名交易, 已签名的交易返回错误
+func (t *Transaction) ExportUnsigned() (*UnsignedTransaction, error) {
+	for _, in := range t.Tx.TxIn {
+		if len(in.SignatureScript) > 0 || len(in.Witness) > 0 {
+			return nil, log.WithError(utils.ErrInvalidValue, "transaction already signed")
+		}
+	}
+	txHex, err := t.TxHex()
+	if err != nil {
+		return nil, err
+	}
+	u := &UnsignedTransaction{
+		Version:     UnsignedTxFormatVersion,
+		Network:     utils.BtcChainName(t.chainParams),
+		Tx:          txHex,
+		ChangeIndex: t.ChangeIndex,
+		FeePerKb:    t.feePerKb,
+	}
+	for i := range t.Tx.TxIn {
+		u.PrevScripts = append(u.PrevScripts, hex.EncodeToString(t.PrevScripts[i]))
+		u.InputValues = append(u.InputValues, int64(t.PrevInputValues[i]))
+	}
+	return u, nil
+}
+
+// ParseUnsignedTransaction 解析 ExportUnsigned 生成的 JSON
+func ParseUnsignedTransaction(data []byte) (*UnsignedTransaction, error) {
+	var u UnsignedTransaction
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, log.WithError(err, "Unmarshal failed")
+	}
+	return &u, nil
+}
+
+// Transaction 还原为 Transaction, 校验格式版本和输入数量
+func (u *UnsignedTransaction) Transaction() (*Transaction, error) {
+	if u.Version != UnsignedTxFormatVersion {
+		return nil, log.WithError(utils.ErrInvalidValue, "unsupported format version")
+	}
+	chainParams, err := utils.GetBtcChainParam(u.Network)
+	if err != nil {
+		return nil, log.WithError(utils.ErrInvalidValue, err.Error())
+	}
+	tx, err := decodeMsgTx(u.Tx)
+	if err != nil {
+		return nil, log.WithError(err, "decodeMsgTx failed")
+	}
+	if len(u.PrevScripts) != len(tx.TxIn) || len(u.InputValues) != len(tx.TxIn) {
+		return nil, log.WithError(utils.ErrInvalidValue, "previous outputs do not match inputs")
+	}
+	if u.ChangeIndex < -1 || u.ChangeIndex >= len(tx.TxOut) {
+		return nil, log.WithError(utils.ErrInvalidValue, "invalid change index")
+	}
+
+	t := &Transaction{
+		AuthoredTx: txauthor.AuthoredTx{
+			Tx:          tx,
+			ChangeIndex: u.ChangeIndex,
+		},
+		chainParams: chainParams,
+		feePerKb:    u.FeePerKb,
+	}
+	for i := range tx.TxIn {
+		script, err := hex.DecodeString(u.PrevScripts[i])
+		if err != nil {
+			return nil, log.WithError(err, "DecodeString failed")
+		}
+		t.PrevScripts = append(t.PrevScripts, script)
+		t.PrevInputValues = append(t.PrevInputValues, btcutil.Amount(u.InputValues[i]))
+		t.TotalInput += btcutil.Amount(u.InputValues[i])
+	}
+	return t, nil
+}
+
+// SignUnsigned 离线签名, 返回已签名交易的十六进制
+func SignUnsigned(data []byte, account *Account) (string, error) {
+	u, err := ParseUnsignedTransaction(data)
+	if err != nil {
+		return "", err
+	}
+	t, err := u.Transaction()
+	if err != nil {
+		return "", err
+	}
+	if account.chain.Net != t.chainParams.Net {
+		return "", log.WithError(utils.ErrAddressNetworkMismatch, "SignUnsigned failed")
+	}
+	if err = t.SignWithSecretsSource(account); err != nil {
+		return "", log.WithError(err, "SignWithSecretsSource failed")
+	}
+	return t.TxHex()
+}
+
+// VerifySigned 校验签名后的交易与导出时一致(只允许增加签名), 并验证签名
+func (u *UnsignedTransaction) VerifySigned(signedTx string) (*Transaction, error) {
+	t, err := u.Transaction()
+	if err != nil {
+		return nil, err
+	}
+	signed, err := decodeMsgTx(signedTx)
+	if err != nil {
+		return nil, log.WithError(err, "decodeMsgTx failed")
+	}
+
+	// 去掉签名后应与未签名交易完全相同
+	stripped := signed.Copy()
+	for _, in := range stripped.TxIn {
+		in.SignatureScript = nil
+		in.Witness = nil
+	}
+	if stripped.TxHash() != t.Tx.TxHash() {
+		return nil, log.WithError(utils.TransactionHashError, "signed transaction does not match")
+	}
+	t.Tx = signed
+	if err = t.validate(); err != nil {
+		return nil, log.WithError(utils.ErrInvalidSignature, err.Error())
+	}
+	return t, nil
+}
+
+// BroadcastSigned 校验离线签名的交易并执行 Preflight 后广播
+func (u *UnsignedTransaction) BroadcastSigned(net NetParams, signedTx string) (string, error) {
+	t, err := u.VerifySigned(signedTx)
+	if err != nil {
+		return "", err
+	}
+	if issues := t.Preflight(&PreflightOptions{ChainParams: netChainParams(net)}); len(issues) > 0 {
+		return "", log.WithError(issues[0], "Preflight failed")
+	}
+	txId, err := net.PushTx(signedTx, t)
+	if err != nil {
+		return "", log.WithError(err, "PushTx failed")
+	}
+	return txId, nil
+}
+
+func decodeMsgTx(txHex string) (*wire.MsgTx, error) {
+	data, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := &wire.MsgTx{}
+	if err = tx.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
@@ -0,0 +1,131 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+const (
+	testAccountAddress = "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732" // testAccountKey 的 P2WPKH 地址
+	testRecipient      = "2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi"
+)
+
+// newTestTransaction 花费 from 的测试 UTXO 向 testRecipient 转账 amount, 找零到 from, signed 时用 testAccountKey 签名
+func newTestTransaction(t *testing.T, from string, values []int64, amount, feePerKb int64, signed bool) *Transaction {
+	params := &chaincfg.TestNet3Params
+	fromAddress, _ := btcutil.DecodeAddress(from, params)
+	to, _ := btcutil.DecodeAddress(testRecipient, params)
+	tx, err := NewTransaction(newTestUnspents(t, from, values...), []TransferParam{{To: to, Amount: amount}}, fromAddress, feePerKb, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed {
+		account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+		if err = tx.SignWithSecretsSource(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tx
+}
+
+func TestOfflineSigning(t *testing.T) {
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	for _, from := range []string{
+		testAccountAddress,
+		"2MuKWyXzED48Rag2WrrLC97BgtCuteUzLDS",
+		"n43tW32TTVfapiTEstqmhAAoasEcRdAJEm",
+	} {
+		t.Run(from, func(t *testing.T) {
+			tx := newTestTransaction(t, from, []int64{3000000, 2000000}, 4000000, 2000, false)
+			unsigned, err := tx.ExportUnsigned()
+			assert.NoError(t, err)
+			assert.Equal(t, "TestNet3", unsigned.Network)
+			assert.Equal(t, tx.ChangeIndex, unsigned.ChangeIndex)
+			data, err := json.Marshal(unsigned)
+			assert.NoError(t, err)
+
+			// 离线设备
+			signedTx, err := SignUnsigned(data, account)
+			assert.NoError(t, err)
+
+			// 联网设备
+			parsed, err := ParseUnsignedTransaction(data)
+			assert.NoError(t, err)
+			verified, err := parsed.VerifySigned(signedTx)
+			assert.NoError(t, err)
+			assert.Equal(t, tx.ChangeIndex, verified.ChangeIndex)
+			assert.Equal(t, tx.TotalInput, verified.TotalInput)
+
+			txId, err := parsed.BroadcastSigned(fakeNetParams{}, signedTx)
+			assert.NoError(t, err)
+			assert.Equal(t, verified.Tx.TxHash().String(), txId)
+		})
+	}
+}
+
+func TestExportUnsigned_Signed(t *testing.T) {
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	tx := newTestTransaction(t, testAccountAddress, []int64{3000000, 2000000}, 4000000, 2000, false)
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+	_, err := tx.ExportUnsigned()
+	assert.Error(t, err)
+}
+
+func TestSignUnsigned_Invalid(t *testing.T) {
+	tx := newTestTransaction(t, testAccountAddress, []int64{3000000, 2000000}, 4000000, 2000, false)
+	unsigned, _ := tx.ExportUnsigned()
+
+	wif, _ := btcutil.DecodeWIF(testAccountKey)
+	mainAccount, _ := NewAccountWithPrivateKey(hex.EncodeToString(wif.PrivKey.Serialize()), utils.BtcChainMainNet)
+	data, _ := json.Marshal(unsigned)
+	_, err := SignUnsigned(data, mainAccount)
+	assert.Equal(t, utils.ErrAddressNetworkMismatch.ErrCode, err.(*utils.Error).ErrCode)
+
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	bad := *unsigned
+	bad.InputValues = bad.InputValues[:1]
+	data, _ = json.Marshal(&bad)
+	_, err = SignUnsigned(data, account)
+	assert.Error(t, err)
+
+	bad = *unsigned
+	bad.Version = UnsignedTxFormatVersion + 1
+	data, _ = json.Marshal(&bad)
+	_, err = SignUnsigned(data, account)
+	assert.Error(t, err)
+}
+
+func TestVerifySigned_Tampered(t *testing.T) {
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	tx := newTestTransaction(t, testAccountAddress, []int64{3000000, 2000000}, 4000000, 2000, false)
+	unsigned, _ := tx.ExportUnsigned()
+	data, _ := json.Marshal(unsigned)
+	signedTx, err := SignUnsigned(data, account)
+	assert.NoError(t, err)
+
+	// 签名后修改输出金额
+	signed, _ := decodeMsgTx(signedTx)
+	signed.TxOut[0].Value -= 1000
+	var buf bytes.Buffer
+	_ = signed.Serialize(&buf)
+	tampered := hex.EncodeToString(buf.Bytes())
+	_, err = unsigned.VerifySigned(tampered)
+	assert.Equal(t, utils.TransactionHashError.ErrCode, err.(*utils.Error).ErrCode)
+
+	// 未签名的交易
+	_, err = unsigned.VerifySigned(unsigned.Tx)
+	assert.Equal(t, utils.ErrInvalidSignature.ErrCode, err.(*utils.Error).ErrCode)
+
+	// 篡改输入金额后签名无效
+	bad := *unsigned
+	bad.InputValues = append([]int64{}, unsigned.InputValues...)
+	bad.InputValues[0] += 1
+	_, err = bad.VerifySigned(signedTx)
+	assert.Equal(t, utils.ErrInvalidSignature.ErrCode, err.(*utils.Error).ErrCode)
+}
@@ -0,0 +1,175 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"math/big"
+)
+
+const (
+	// OmniPropertyUSDT 主网 USDT 的属性 ID
+	OmniPropertyUSDT uint32 = 31
+	// OmniDustAmount 接收方参考输出的金额
+	OmniDustAmount int64 = 546
+	// OmniPayloadSize simple send 数据的长度
+	OmniPayloadSize = 20
+
+	omniMarker           = "omni"
+	omniTypeSimpleSend   = 0
+	omniDivisibleDecimal = 8
+)
+
+// OmniSimpleSendPayload 构造 simple send 的 OP_RETURN 数据
+// 格式: "omni" + 版本(2字节) + 类型(2字节) + 属性ID(4字节) + 数量(8字节), 均为大端序
+func OmniSimpleSendPayload(propertyId uint32, amount int64) []byte {
+	payload := make([]byte, OmniPayloadSize)
+	copy(payload, omniMarker)
+	binary.BigEndian.PutUint16(payload[4:], 0)
+	binary.BigEndian.PutUint16(payload[6:], omniTypeSimpleSend)
+	binary.BigEndian.PutUint32(payload[8:], propertyId)
+	binary.BigEndian.PutUint64(payload[12:], uint64(amount))
+	return payload
+}
+
+// ParseOmniSimpleSend 解析 simple send 数据, 返回属性ID和数量
+func ParseOmniSimpleSend(payload []byte) (uint32, int64, error) {
+	if len(payload) != OmniPayloadSize || !bytes.HasPrefix(payload, []byte(omniMarker)) {
+		return 0, 0, errors.New("invalid omni payload")
+	}
+	if binary.BigEndian.Uint16(payload[6:]) != omniTypeSimpleSend {
+		return 0, 0, errors.New("not a simple send")
+	}
+	return binary.BigEndian.Uint32(payload[8:]), int64(binary.BigEndian.Uint64(payload[12:])), nil
+}
+
+// OmniBackend Omni 资产的数据来源, 如 Omni Core 节点、OmniExplorer
+type OmniBackend interface {
+	// GetOmniBalance 查询地址持有的 Omni 资产余额
+	GetOmniBalance(address string, propertyId uint32) (*base.Balance, error)
+}
+
+// OmniToken Omni Layer 上的 USDT
+type OmniToken struct {
+	Info       *base.TokenInfo
+	chain      *Chain
+	propertyId uint32
+	backend    OmniBackend
+}
+
+// NewOmniToken 默认使用 USDT 的属性 ID
+func NewOmniToken(chain *Chain, backend OmniBackend) *OmniToken {
+	return &OmniToken{chain: chain, Info: &base.TokenInfo{}, propertyId: OmniPropertyUSDT, backend: backend}
+}
+
+// SetPropertyId 设置属性 ID, 测试网的 USDT 与主网不同
+func (t *OmniToken) SetPropertyId(propertyId uint32) *OmniToken {
+	t.propertyId = propertyId
+	return t
+}
+
+func (t *OmniToken) PropertyId() uint32 {
+	return t.propertyId
+}
+
+func (t *OmniToken) CoinType() uint32 {
+	return utils.USDT
+}
+
+func (t *OmniToken) Symbol() string {
+	return "USDT"
+}
+
+func (t *OmniToken) Name() string {
+	return "Tether USD"
+}
+
+func (t *OmniToken) Chain() base.Chain {
+	return t.chain
+}
+
+func (t *OmniToken) TokenInfo() (*base.TokenInfo, error) {
+	token := base.GetToken(t.CoinType(), "")
+	if token != nil {
+		return token, nil
+	}
+
+	t.Info = &base.TokenInfo{
+		Name:    t.Name(),
+		Symbol:  t.Symbol(),
+		Decimal: omniDivisibleDecimal,
+	}
+	base.AddToken(t.CoinType(), "", t.Info)
+	return t.Info, nil
+}
+
+func (t *OmniToken) BalanceOfAddress(address string) (*base.Balance, error) {
+	if t.backend == nil {
+		return base.EmptyBalance(), log.WithError(utils.ErrClientNotInitialized, "omni backend not set")
+	}
+	balance, err := t.backend.GetOmniBalance(address, t.propertyId)
+	if err != nil {
+		return base.EmptyBalance(), log.WithError(err, "GetOmniBalance failed")
+	}
+	return balance, nil
+}
+
+// BuildTransfer 构造 simple send 交易, amount 为最小单位
+// 输出依次为 OP_RETURN、接收方参考输出、找零, 找零返回发送方地址
+func (t *OmniToken) BuildTransfer(unspents []BtcUnspent, from, to btcutil.Address, amount, feePerKb int64, chainParams *chaincfg.Params) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, log.WithError(utils.AmountError, "BuildTransfer failed")
+	}
+	params := []TransferParam{
+		NewDataParam(OmniSimpleSendPayload(t.propertyId, amount)),
+		{To: to, Amount: OmniDustAmount},
+	}
+	// Omni 以最后一个非发送方的输出作为接收方, 不能随机化找零位置
+	tx, err := newTransaction(unspents, params, from, feePerKb, chainParams)
+	if err != nil {
+		return nil, log.WithError(err, "newTransaction failed")
+	}
+	return tx, nil
+}
+
+// OmniRPCBackend 通过 Omni Core 节点查询余额
+type OmniRPCBackend struct {
+	client *Client
+}
+
+func NewOmniRPCBackend(client *Client) *OmniRPCBackend {
+	return &OmniRPCBackend{client: client}
+}
+
+// GetOmniBalance 调用 omni_getbalance, 按可分割资产处理
+func (b *OmniRPCBackend) GetOmniBalance(address string, propertyId uint32) (*base.Balance, error) {
+	addressParam, _ := json.Marshal(address)
+	propertyParam, _ := json.Marshal(propertyId)
+	data, err := b.client.RPCClient().RawRequest("omni_getbalance", []json.RawMessage{addressParam, propertyParam})
+	if err != nil {
+		return nil, log.WithError(err, "omni_getbalance failed")
+	}
+	var result struct {
+		Balance  string `json:"balance"`
+		Reserved string `json:"reserved"`
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, log.WithError(err, "Unmarshal failed")
+	}
+	usable, err := utils.ParseAmount(result.Balance, omniDivisibleDecimal)
+	if err != nil {
+		return nil, log.WithError(err, "ParseAmount failed")
+	}
+	reserved, err := utils.ParseAmount(result.Reserved, omniDivisibleDecimal)
+	if err != nil {
+		return nil, log.WithError(err, "ParseAmount failed")
+	}
+	total := new(big.Int).Add(usable.BigInt(), reserved.BigInt())
+	return &base.Balance{Total: utils.NewOptAmount(total.String(), omniDivisibleDecimal), Usable: usable}, nil
+}
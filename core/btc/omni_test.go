@@ -0,0 +1,80 @@
+package btc
+
+import (
+	"encoding/hex"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+func TestOmniSimpleSendPayload(t *testing.T) {
+	// 发送 1 USDT
+	payload := OmniSimpleSendPayload(OmniPropertyUSDT, 100000000)
+	assert.Equal(t, "6f6d6e69000000000000001f0000000005f5e100", hex.EncodeToString(payload))
+
+	propertyId, amount, err := ParseOmniSimpleSend(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, OmniPropertyUSDT, propertyId)
+	assert.Equal(t, int64(100000000), amount)
+
+	_, _, err = ParseOmniSimpleSend([]byte("hdwallet"))
+	assert.Error(t, err)
+}
+
+// fakeOmniBackend 返回固定余额
+type fakeOmniBackend map[uint32]string
+
+func (b fakeOmniBackend) GetOmniBalance(address string, propertyId uint32) (*base.Balance, error) {
+	amount := utils.NewOptAmount(b[propertyId], 8)
+	return &base.Balance{Total: amount, Usable: amount}, nil
+}
+
+func TestOmniToken_BalanceOfAddress(t *testing.T) {
+	token := NewOmniToken(NewChain(), fakeOmniBackend{OmniPropertyUSDT: "150000000", 2: "1"})
+	balance, err := token.BalanceOfAddress("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", balance.Total.AmountString())
+
+	_, err = NewOmniToken(NewChain(), nil).BalanceOfAddress("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa")
+	assert.Error(t, err)
+}
+
+func TestOmniToken_BuildTransfer(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	from, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", params)
+	unspents := newTestUnspents(t, from.EncodeAddress(), 20000)
+
+	token := NewOmniToken(NewChain(), nil).SetPropertyId(2)
+	tx, err := token.BuildTransfer(unspents, from, to, 250000000, 1000, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+
+	outs := tx.Tx.TxOut
+	if assert.Len(t, outs, 3) {
+		pushes, _ := txscript.PushedData(outs[0].PkScript)
+		propertyId, amount, err := ParseOmniSimpleSend(pushes[0])
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(2), propertyId)
+		assert.Equal(t, int64(250000000), amount)
+
+		toScript, _ := txscript.PayToAddrScript(to)
+		assert.Equal(t, toScript, outs[1].PkScript)
+		assert.Equal(t, OmniDustAmount, outs[1].Value)
+
+		// 找零在最后并返回发送方
+		fromScript, _ := txscript.PayToAddrScript(from)
+		assert.Equal(t, fromScript, outs[2].PkScript)
+		assert.Equal(t, 2, tx.ChangeIndex)
+	}
+
+	_, err = token.BuildTransfer(unspents, from, to, 0, 1000, params)
+	assert.Error(t, err)
+}
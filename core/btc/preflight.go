@@ -0,0 +1,153 @@
+package btc
+
+import (
+	"fmt"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+)
+
+const (
+	// MaxStandardTxWeight 标准交易的最大重量
+	MaxStandardTxWeight = 400000
+	// MaxStandardScriptSigSize 标准交易输入脚本的最大长度
+	MaxStandardScriptSigSize = 1650
+	// DefaultMaxFeeRate 默认的最高费率 0.1 BTC/kvB, 与 Bitcoin Core 的 maxfeerate 一致
+	DefaultMaxFeeRate = btcutil.SatoshiPerBitcoin / 10
+)
+
+// PreflightOptions 广播前检查的参数, 零值使用默认规则
+type PreflightOptions struct {
+	MinRelayFeePerKb btcutil.Amount          // 最低转发费率, 默认使用 ChainFeeRules
+	MaxFeeRate       btcutil.Amount          // 最高费率 sat/kvB, 默认 DefaultMaxFeeRate
+	ChainParams      *chaincfg.Params        // 广播的目标网络, 为空时不检查
+	TipHeight        int64                   // 当前区块高度, 用于检查 coinbase 成熟度
+	CoinbaseHeights  map[wire.OutPoint]int64 // 引用的 coinbase 输出及其所在区块高度
+}
+
+// Preflight 广播前按节点的标准规则检查已签名的交易, 返回发现的全部问题
+func (t *Transaction) Preflight(opts *PreflightOptions) []*utils.Error {
+	if opts == nil {
+		opts = &PreflightOptions{}
+	}
+	rules := ChainFeeRules(t.chainParams)
+	minRelayFee := opts.MinRelayFeePerKb
+	if minRelayFee <= 0 {
+		minRelayFee = rules.MinRelayFeePerKb
+	}
+	maxFeeRate := opts.MaxFeeRate
+	if maxFeeRate <= 0 {
+		maxFeeRate = DefaultMaxFeeRate
+	}
+
+	var issues []*utils.Error
+	tx := t.Tx
+	networkMismatch := opts.ChainParams != nil && t.chainParams != nil && opts.ChainParams.Net != t.chainParams.Net
+	if networkMismatch {
+		issues = append(issues, utils.ErrAddressNetworkMismatch.WithMessage(
+			fmt.Sprintf("transaction is for %s, broadcasting to %s", t.chainParams.Name, opts.ChainParams.Name)))
+	}
+
+	seen := make(map[wire.OutPoint]struct{}, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		if _, ok := seen[in.PreviousOutPoint]; ok {
+			issues = append(issues, utils.ErrDuplicateInput.WithMessage(fmt.Sprintf("input %d spends %s", i, in.PreviousOutPoint)))
+		}
+		seen[in.PreviousOutPoint] = struct{}{}
+
+		if len(in.SignatureScript) > MaxStandardScriptSigSize {
+			issues = append(issues, utils.ErrNonStandard.WithMessage(fmt.Sprintf("input %d scriptsig-size", i)))
+		} else if !txscript.IsPushOnlyScript(in.SignatureScript) {
+			issues = append(issues, utils.ErrNonStandard.WithMessage(fmt.Sprintf("input %d scriptsig-not-pushonly", i)))
+		}
+		if height, ok := opts.CoinbaseHeights[in.PreviousOutPoint]; ok && t.chainParams != nil {
+			if depth := opts.TipHeight + 1 - height; depth < int64(t.chainParams.CoinbaseMaturity) {
+				issues = append(issues, utils.ErrImmatureCoinbase.WithMessage(
+					fmt.Sprintf("input %d has %d confirmations, requires %d", i, depth, t.chainParams.CoinbaseMaturity)))
+			}
+		}
+	}
+
+	dataOutputs := 0
+	for i, out := range tx.TxOut {
+		switch txscript.GetScriptClass(out.PkScript) {
+		case txscript.NonStandardTy:
+			issues = append(issues, utils.ErrNonStandard.WithMessage(fmt.Sprintf("output %d scriptpubkey", i)))
+			continue
+		case txscript.MultiSigTy:
+			if numPubKeys, _, err := txscript.CalcMultiSigStats(out.PkScript); err != nil || numPubKeys > 3 {
+				issues = append(issues, utils.ErrNonStandard.WithMessage(fmt.Sprintf("output %d bare-multisig", i)))
+			}
+		case txscript.NullDataTy:
+			if dataOutputs++; dataOutputs > 1 {
+				issues = append(issues, utils.ErrNonStandard.WithMessage("multi-op-return"))
+			}
+		}
+		if opts.ChainParams != nil && !networkMismatch && !outputForNet(out.PkScript, opts.ChainParams) {
+			issues = append(issues, utils.ErrAddressNetworkMismatch.WithMessage(
+				fmt.Sprintf("output %d is not a %s address", i, opts.ChainParams.Name)))
+		}
+		if rules.IsDust(out, minRelayFee) {
+			issues = append(issues, utils.ErrDustOutput.WithMessage(fmt.Sprintf("output %d value %d", i, out.Value)))
+		}
+	}
+
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	if weight > MaxStandardTxWeight {
+		issues = append(issues, utils.ErrNonStandard.WithMessage(fmt.Sprintf("tx-size weight %d", weight)))
+	}
+
+	if len(t.PrevScripts) != len(tx.TxIn) || len(t.PrevInputValues) != len(tx.TxIn) {
+		return append(issues, utils.ErrInvalidValue.WithMessage("previous outputs do not match inputs"))
+	}
+	for i, prevScript := range t.PrevScripts {
+		switch txscript.GetScriptClass(prevScript) {
+		case txscript.NonStandardTy, txscript.WitnessUnknownTy:
+			issues = append(issues, utils.ErrNonStandard.WithMessage(fmt.Sprintf("input %d spends non-standard script", i)))
+		}
+	}
+
+	var fee btcutil.Amount
+	for _, value := range t.PrevInputValues {
+		fee += value
+	}
+	for _, out := range tx.TxOut {
+		fee -= btcutil.Amount(out.Value)
+	}
+	vsize := (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+	if minFee := txrules.FeeForSerializeSize(minRelayFee, int(vsize)); fee < minFee {
+		issues = append(issues, utils.ErrFeeTooLow.WithMessage(fmt.Sprintf("fee %d, requires %d", fee, minFee)))
+	} else if feeRate := fee * 1000 / btcutil.Amount(vsize); feeRate > maxFeeRate {
+		issues = append(issues, utils.ErrAbsurdFee.WithMessage(fmt.Sprintf("fee rate %d sat/kvB exceeds %d", feeRate, maxFeeRate)))
+	}
+
+	if err := t.validate(); err != nil {
+		issues = append(issues, utils.ErrInvalidSignature.WithMessage(err.Error()))
+	}
+	return issues
+}
+
+// outputForNet 检查输出的地址是否属于 params 网络
+// 输出脚本本身不含网络信息, 主要检查不支持隔离见证的链上出现了隔离见证输出
+func outputForNet(pkScript []byte, params *chaincfg.Params) bool {
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, params)
+	if err != nil {
+		return false
+	}
+	switch class {
+	case txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy, txscript.WitnessV1TaprootTy, txscript.WitnessUnknownTy:
+		if params.Bech32HRPSegwit == "" {
+			return false
+		}
+	}
+	for _, addr := range addrs {
+		if !addr.IsForNet(params) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package btc
+
+import (
+	"errors"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+func issueCodes(issues []*utils.Error) []int {
+	codes := make([]int, 0, len(issues))
+	for _, issue := range issues {
+		codes = append(codes, issue.ErrCode)
+	}
+	return codes
+}
+
+func TestTransaction_Preflight(t *testing.T) {
+	tx := newTestTransaction(t, testAccountAddress, []int64{10000000}, 100000, 2000, true)
+	assert.Empty(t, tx.Preflight(nil))
+	assert.Empty(t, tx.Preflight(&PreflightOptions{ChainParams: &chaincfg.TestNet3Params}))
+
+	codes := issueCodes(tx.Preflight(&PreflightOptions{ChainParams: &chaincfg.MainNetParams}))
+	assert.Equal(t, []int{utils.ErrAddressNetworkMismatch.ErrCode}, codes)
+
+	// 引用的 coinbase 输出只有 51 个确认
+	outPoint := tx.Tx.TxIn[0].PreviousOutPoint
+	codes = issueCodes(tx.Preflight(&PreflightOptions{TipHeight: 150, CoinbaseHeights: map[wire.OutPoint]int64{outPoint: 100}}))
+	assert.Equal(t, []int{utils.ErrImmatureCoinbase.ErrCode}, codes)
+	assert.Empty(t, tx.Preflight(&PreflightOptions{TipHeight: 199, CoinbaseHeights: map[wire.OutPoint]int64{outPoint: 100}}))
+}
+
+// mainNetParams 报告后端为主网
+type mainNetParams struct {
+	fakeNetParams
+}
+
+func (mainNetParams) ChainParams() *chaincfg.Params {
+	return &chaincfg.MainNetParams
+}
+
+func TestTransaction_PreflightNetwork(t *testing.T) {
+	tx := newTestTransaction(t, testAccountAddress, []int64{10000000}, 100000, 2000, true)
+	// 找零为 P2WPKH, 目标网络不支持隔离见证
+	noSegwit := chaincfg.TestNet3Params
+	noSegwit.Bech32HRPSegwit = ""
+	codes := issueCodes(tx.Preflight(&PreflightOptions{ChainParams: &noSegwit}))
+	assert.Equal(t, []int{utils.ErrAddressNetworkMismatch.ErrCode}, codes)
+
+	// 广播时使用后端的网络检查
+	_, err := tx.Broadcast(mainNetParams{})
+	var u *utils.Error
+	if assert.True(t, errors.As(err, &u)) {
+		assert.Equal(t, utils.ErrAddressNetworkMismatch.ErrCode, u.ErrCode)
+	}
+	_, err = tx.Broadcast(fakeNetParams{})
+	assert.NoError(t, err)
+}
+
+func TestTransaction_PreflightFee(t *testing.T) {
+	codes := issueCodes(newTestTransaction(t, testAccountAddress, []int64{10000000}, 100000, 500, true).Preflight(nil))
+	assert.Equal(t, []int{utils.ErrFeeTooLow.ErrCode}, codes)
+
+	codes = issueCodes(newTestTransaction(t, testAccountAddress, []int64{10000000}, 100000, 20000000, true).Preflight(nil))
+	assert.Equal(t, []int{utils.ErrAbsurdFee.ErrCode}, codes)
+	assert.Empty(t, newTestTransaction(t, testAccountAddress, []int64{10000000}, 100000, 20000000, true).Preflight(&PreflightOptions{MaxFeeRate: btcutil.SatoshiPerBitcoin}))
+}
+
+func TestTransaction_PreflightOutputs(t *testing.T) {
+	tx := newTestTransaction(t, testAccountAddress, []int64{10000000}, 100000, 2000, true)
+	// 修改输出后签名失效
+	tx.Tx.TxOut[0].Value = 200
+	nonStandard, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_TRUE).Script()
+	tx.Tx.AddTxOut(wire.NewTxOut(0, nonStandard))
+	codes := issueCodes(tx.Preflight(nil))
+	assert.Contains(t, codes, utils.ErrDustOutput.ErrCode)
+	assert.Contains(t, codes, utils.ErrNonStandard.ErrCode)
+	assert.Contains(t, codes, utils.ErrInvalidSignature.ErrCode)
+
+	// 找零为 P2WPKH, 粉尘阈值为 294
+	tx = newTestTransaction(t, testAccountAddress, []int64{10000000}, 100000, 2000, true)
+	change := tx.Tx.TxOut[tx.ChangeIndex]
+	change.Value = 294
+	assert.NotContains(t, issueCodes(tx.Preflight(nil)), utils.ErrDustOutput.ErrCode)
+	change.Value = 293
+	assert.Contains(t, issueCodes(tx.Preflight(nil)), utils.ErrDustOutput.ErrCode)
+}
+
+func TestTransaction_PreflightDuplicateInput(t *testing.T) {
+	tx := newTestTransaction(t, testAccountAddress, []int64{10000000}, 100000, 2000, true)
+	in := *tx.Tx.TxIn[0]
+	tx.Tx.AddTxIn(&in)
+	tx.PrevScripts = append(tx.PrevScripts, tx.PrevScripts[0])
+	tx.PrevInputValues = append(tx.PrevInputValues, tx.PrevInputValues[0])
+	codes := issueCodes(tx.Preflight(nil))
+	assert.Contains(t, codes, utils.ErrDuplicateInput.ErrCode)
+}
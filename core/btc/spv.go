@@ -0,0 +1,162 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+)
+
+// maxProofTransactions 证明中区块交易数量的上限, 远大于区块实际能容纳的交易数
+const maxProofTransactions = 1 << 20
+
+// MerkleProof 交易包含在区块中的证明, 需要与本地区块头的默克尔根比对
+type MerkleProof struct {
+	TxId        chainhash.Hash
+	MerkleRoot  chainhash.Hash  // 由证明计算出的默克尔根
+	BlockHash   *chainhash.Hash // 证明所在的区块, 为空时按 BlockHeight 查找
+	BlockHeight int64
+}
+
+// ProofSource 默克尔证明的数据来源, 如 Esplora、节点 RPC
+type ProofSource interface {
+	// GetMerkleProof 获取已确认交易的默克尔证明
+	GetMerkleProof(txId string) (*MerkleProof, error)
+}
+
+// NewMerkleBranchProof 由默克尔路径生成证明, 用于 Esplora 和 Electrum 的 merkle-proof 格式
+// branch 为从叶子到根的兄弟节点, pos 为交易在区块中的序号
+func NewMerkleBranchProof(txId chainhash.Hash, branch []chainhash.Hash, pos int, blockHeight int64) (*MerkleProof, error) {
+	if pos < 0 || (len(branch) < 31 && pos >= 1<<uint(len(branch))) {
+		return nil, log.WithError(utils.ErrInvalidProof, "position out of range")
+	}
+	root := txId
+	for i := range branch {
+		if pos&1 == 1 {
+			root = *blockchain.HashMerkleBranches(&branch[i], &root)
+		} else {
+			root = *blockchain.HashMerkleBranches(&root, &branch[i])
+		}
+		pos >>= 1
+	}
+	return &MerkleProof{TxId: txId, MerkleRoot: root, BlockHeight: blockHeight}, nil
+}
+
+// ParseTxOutProof 解析 bitcoind gettxoutproof 返回的证明(CMerkleBlock), 校验其中的部分默克尔树
+// 返回证明中的区块头, 区块头需要再通过 HeaderStore 校验
+func ParseTxOutProof(data []byte, txId chainhash.Hash) (*MerkleProof, *wire.BlockHeader, error) {
+	var merkleBlock wire.MsgMerkleBlock
+	if err := merkleBlock.BtcDecode(bytes.NewReader(data), wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+		return nil, nil, log.WithError(utils.ErrInvalidProof, err.Error())
+	}
+	root, matched, err := extractPartialMerkleTree(&merkleBlock)
+	if err != nil {
+		return nil, nil, log.WithError(utils.ErrInvalidProof, err.Error())
+	}
+	if root != merkleBlock.Header.MerkleRoot {
+		return nil, nil, log.WithError(utils.ErrInvalidProof, "merkle root mismatch")
+	}
+	found := false
+	for i := range matched {
+		if matched[i] == txId {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil, log.WithError(utils.ErrInvalidProof, "transaction not in proof")
+	}
+	blockHash := merkleBlock.Header.BlockHash()
+	proof := &MerkleProof{TxId: txId, MerkleRoot: root, BlockHash: &blockHash, BlockHeight: -1}
+	return proof, &merkleBlock.Header, nil
+}
+
+// partialMerkleTree 按 BIP37 遍历部分默克尔树
+type partialMerkleTree struct {
+	block     *wire.MsgMerkleBlock
+	bitsUsed  int
+	hashUsed  int
+	matched   []chainhash.Hash
+	malformed bool
+}
+
+func (p *partialMerkleTree) width(height uint) uint32 {
+	return (p.block.Transactions + (1 << height) - 1) >> height
+}
+
+func (p *partialMerkleTree) traverse(height uint, pos uint32) chainhash.Hash {
+	if p.bitsUsed >= len(p.block.Flags)*8 {
+		p.malformed = true
+		return chainhash.Hash{}
+	}
+	flag := p.block.Flags[p.bitsUsed/8]>>(uint(p.bitsUsed)%8)&1 == 1
+	p.bitsUsed++
+	if height == 0 || !flag {
+		if p.hashUsed >= len(p.block.Hashes) {
+			p.malformed = true
+			return chainhash.Hash{}
+		}
+		hash := *p.block.Hashes[p.hashUsed]
+		p.hashUsed++
+		if height == 0 && flag {
+			p.matched = append(p.matched, hash)
+		}
+		return hash
+	}
+	left := p.traverse(height-1, pos*2)
+	right := left
+	if pos*2+1 < p.width(height-1) {
+		right = p.traverse(height-1, pos*2+1)
+		// 左右相同的节点可以构造出不同交易集合的相同默克尔根(CVE-2012-2459)
+		if right == left {
+			p.malformed = true
+		}
+	}
+	return *blockchain.HashMerkleBranches(&left, &right)
+}
+
+func extractPartialMerkleTree(block *wire.MsgMerkleBlock) (chainhash.Hash, []chainhash.Hash, error) {
+	if block.Transactions == 0 || block.Transactions > maxProofTransactions || len(block.Hashes) > int(block.Transactions) {
+		return chainhash.Hash{}, nil, errInvalidPartialTree
+	}
+	p := &partialMerkleTree{block: block}
+	height := uint(0)
+	for p.width(height) > 1 {
+		height++
+	}
+	root := p.traverse(height, 0)
+	// 所有的标志位和哈希都应被使用
+	if p.malformed || (p.bitsUsed+7)/8 != len(block.Flags) || p.hashUsed != len(block.Hashes) {
+		return chainhash.Hash{}, nil, errInvalidPartialTree
+	}
+	return root, p.matched, nil
+}
+
+var errInvalidPartialTree = utils.ErrInvalidProof.WithMessage("malformed partial merkle tree")
+
+// GetMerkleProof 通过 gettxoutproof 获取证明, 实现 ProofSource
+func (s *RPCTxSource) GetMerkleProof(txId string) (*MerkleProof, error) {
+	hash, err := chainhash.NewHashFromStr(txId)
+	if err != nil {
+		return nil, log.WithError(err, "NewHashFromStr failed")
+	}
+	param, _ := json.Marshal([]string{txId})
+	result, err := s.client.RPCClient().RawRequest("gettxoutproof", []json.RawMessage{param})
+	if err != nil {
+		return nil, log.WithError(err, "gettxoutproof failed")
+	}
+	var proofHex string
+	if err = json.Unmarshal(result, &proofHex); err != nil {
+		return nil, log.WithError(err, "Unmarshal failed")
+	}
+	data, err := hex.DecodeString(proofHex)
+	if err != nil {
+		return nil, log.WithError(err, "DecodeString failed")
+	}
+	proof, _, err := ParseTxOutProof(data, *hash)
+	return proof, err
+}
@@ -0,0 +1,175 @@
+package btc
+
+import (
+	"bytes"
+	"errors"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/bloom"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+// newProofTestBlock 生成包含 n 笔交易的区块, 区块头的默克尔根为真实值
+func newProofTestBlock(prev *wire.BlockHeader, n int) *wire.MsgBlock {
+	block := wire.NewMsgBlock(&wire.BlockHeader{})
+	for i := 0; i < n; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.HashH([]byte{byte(i)})}, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(int64(i+1)*1000, []byte{0x51}))
+		block.AddTransaction(tx)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(btcutil.NewBlock(block).Transactions(), false)
+	block.Header = *mineTestHeader(prev, prev.Timestamp.Add(tenMinutes), *merkles[len(merkles)-1])
+	return block
+}
+
+// merkleBranch 计算交易从叶子到根的兄弟节点
+func merkleBranch(block *wire.MsgBlock, pos int) []chainhash.Hash {
+	level := make([]chainhash.Hash, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		level[i] = tx.TxHash()
+	}
+	var branch []chainhash.Hash
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		branch = append(branch, level[pos^1])
+		next := make([]chainhash.Hash, len(level)/2)
+		for i := range next {
+			next[i] = *blockchain.HashMerkleBranches(&level[2*i], &level[2*i+1])
+		}
+		level, pos = next, pos/2
+	}
+	return branch
+}
+
+func TestNewMerkleBranchProof(t *testing.T) {
+	block := newProofTestBlock(&chaincfg.RegressionNetParams.GenesisBlock.Header, 5)
+	for pos := range block.Transactions {
+		proof, err := NewMerkleBranchProof(block.Transactions[pos].TxHash(), merkleBranch(block, pos), pos, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, block.Header.MerkleRoot, proof.MerkleRoot)
+		assert.Equal(t, int64(1), proof.BlockHeight)
+	}
+
+	// 错误的位置得到不同的默克尔根
+	proof, err := NewMerkleBranchProof(block.Transactions[1].TxHash(), merkleBranch(block, 1), 0, 1)
+	assert.NoError(t, err)
+	assert.NotEqual(t, block.Header.MerkleRoot, proof.MerkleRoot)
+
+	_, err = NewMerkleBranchProof(block.Transactions[1].TxHash(), merkleBranch(block, 1), 8, 1)
+	assert.Equal(t, utils.ErrInvalidProof.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func newTxOutProof(t *testing.T, block *wire.MsgBlock, txIds ...chainhash.Hash) []byte {
+	filter := bloom.NewFilter(uint32(len(txIds)), 0, 0.0001, wire.BloomUpdateNone)
+	for i := range txIds {
+		filter.AddHash(&txIds[i])
+	}
+	merkleBlock, _ := bloom.NewMerkleBlock(btcutil.NewBlock(block), filter)
+	var buf bytes.Buffer
+	if err := merkleBlock.BtcEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseTxOutProof(t *testing.T) {
+	block := newProofTestBlock(&chaincfg.RegressionNetParams.GenesisBlock.Header, 7)
+	txId := block.Transactions[4].TxHash()
+	data := newTxOutProof(t, block, txId, block.Transactions[1].TxHash())
+
+	proof, header, err := ParseTxOutProof(data, txId)
+	assert.NoError(t, err)
+	assert.Equal(t, block.Header.MerkleRoot, proof.MerkleRoot)
+	assert.Equal(t, block.BlockHash(), *proof.BlockHash)
+	assert.Equal(t, block.Header, *header)
+
+	// 证明中不包含的交易
+	_, _, err = ParseTxOutProof(data, block.Transactions[2].TxHash())
+	assert.Equal(t, utils.ErrInvalidProof.ErrCode, err.(*utils.Error).ErrCode)
+
+	// 篡改任意哈希后默克尔根不一致
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)-10] ^= 1
+	_, _, err = ParseTxOutProof(tampered, txId)
+	assert.Equal(t, utils.ErrInvalidProof.ErrCode, err.(*utils.Error).ErrCode)
+
+	_, _, err = ParseTxOutProof(data[:90], txId)
+	assert.Equal(t, utils.ErrInvalidProof.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestExtractPartialMerkleTree_Malformed(t *testing.T) {
+	block := newProofTestBlock(&chaincfg.RegressionNetParams.GenesisBlock.Header, 3)
+	var merkleBlock wire.MsgMerkleBlock
+	data := newTxOutProof(t, block, block.Transactions[0].TxHash())
+	assert.NoError(t, merkleBlock.BtcDecode(bytes.NewReader(data), wire.ProtocolVersion, wire.BaseEncoding))
+
+	// 多余的哈希
+	extra := merkleBlock
+	extra.Hashes = append(append([]*chainhash.Hash{}, merkleBlock.Hashes...), merkleBlock.Hashes[0])
+	_, _, err := extractPartialMerkleTree(&extra)
+	assert.Error(t, err)
+
+	// 交易数量为 0
+	empty := merkleBlock
+	empty.Transactions = 0
+	_, _, err = extractPartialMerkleTree(&empty)
+	assert.Error(t, err)
+
+	// 复制最后一笔交易构造相同默克尔根的区块(CVE-2012-2459)
+	dup := newProofTestBlock(&chaincfg.RegressionNetParams.GenesisBlock.Header, 3)
+	dup.AddTransaction(dup.Transactions[2])
+	data = newTxOutProof(t, dup, dup.Transactions[3].TxHash())
+	_, _, err = ParseTxOutProof(data, dup.Transactions[3].TxHash())
+	assert.Error(t, err)
+}
+
+type fakeProofSource struct {
+	proof *MerkleProof
+	err   error
+}
+
+func (f *fakeProofSource) GetMerkleProof(txId string) (*MerkleProof, error) {
+	return f.proof, f.err
+}
+
+func TestChain_FetchTransactionStatusVerified(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	store, err := NewHeaderStore(params, nil, 0)
+	assert.NoError(t, err)
+	block := newProofTestBlock(&params.GenesisBlock.Header, 3)
+	tx := block.Transactions[2]
+	txId := tx.TxHash()
+
+	raw := NewRawTransaction(tx, nil, &RawBlock{Hash: block.BlockHash().String(), Height: 1, Confirmations: 1})
+	proofSource := &fakeProofSource{}
+	chain := NewChain().SetTxSource(&fakeTxSource{raw: raw}).SetProofVerifier(proofSource, store)
+
+	proofSource.proof, _ = NewMerkleBranchProof(txId, merkleBranch(block, 2), 2, 1)
+	// 区块头还未同步
+	status, err := chain.FetchTransactionStatus(txId.String())
+	assert.NoError(t, err)
+	assert.Equal(t, base.TransactionStatusPending, status)
+
+	assert.NoError(t, store.AddHeaders(&block.Header))
+	status, err = chain.FetchTransactionStatus(txId.String())
+	assert.NoError(t, err)
+	assert.Equal(t, base.TransactionStatusSuccess, status)
+
+	// 伪造的证明
+	proofSource.proof, _ = NewMerkleBranchProof(txId, merkleBranch(block, 2), 0, 1)
+	_, err = chain.FetchTransactionStatus(txId.String())
+	assert.Equal(t, utils.ErrInvalidProof.ErrCode, err.(*utils.Error).ErrCode)
+
+	proofSource.err = errors.New("backend down")
+	_, err = chain.FetchTransactionStatus(txId.String())
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package btc
+
+import (
+	"errors"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+)
+
+// NewSweepTransaction 花费全部 unspents 转入 to, 不产生找零, 手续费从转账金额中扣除
+// P2PKH 输入按压缩公钥估算大小, 花费未压缩公钥的输出使用 SweepWIF
+func NewSweepTransaction(unspents []BtcUnspent, to btcutil.Address, feePerKb int64, chainParam *chaincfg.Params) (*Transaction, error) {
+	return newSweepTransaction(unspents, to, feePerKb, chainParam, func(prevScripts [][]byte, txOuts []*wire.TxOut) int {
+		return estimateVirtualSize(prevScripts, txOuts, true)
+	})
+}
+
+// newSweepTransaction estimate 用于估算签名后的交易大小
+func newSweepTransaction(unspents []BtcUnspent, to btcutil.Address, feePerKb int64, chainParam *chaincfg.Params,
+	estimate func(prevScripts [][]byte, txOuts []*wire.TxOut) int) (*Transaction, error) {
+	if len(unspents) == 0 || to == nil || feePerKb <= 0 {
+		return nil, errors.New("invalid params")
+	}
+	txOuts, err := makeTxOutputs([]TransferParam{{To: to}}, chainParam)
+	if err != nil {
+		return nil, log.WithError(err, "makeTxOutputs failed")
+	}
+
+	total, inputs, inputValues, scripts, err := makeInputSource(unspents)(btcutil.MaxSatoshi)
+	if err != nil {
+		return nil, log.WithError(err, "makeInputSource failed")
+	}
+	fee := txrules.FeeForSerializeSize(btcutil.Amount(feePerKb), estimate(scripts, txOuts))
+	txOuts[0].Value = int64(total - fee)
+	if total <= fee || ChainFeeRules(chainParam).IsDust(txOuts[0], 0) {
+		return nil, log.WithError(utils.AmountError, "insufficient funds for fee")
+	}
+
+	return &Transaction{
+		AuthoredTx: txauthor.AuthoredTx{
+			Tx: &wire.MsgTx{
+				Version: wire.TxVersion,
+				TxIn:    inputs,
+				TxOut:   txOuts,
+			},
+			PrevScripts:     scripts,
+			PrevInputValues: inputValues,
+			TotalInput:      total,
+			ChangeIndex:     -1,
+		},
+		chainParams: chainParam,
+		feePerKb:    feePerKb,
+	}, nil
+}
+
+// SweepWIF 将外部私钥(如纸钱包)各类型地址上的全部余额转入 to, 返回已签名的交易
+func SweepWIF(wif string, to btcutil.Address, net NetParams, feePerKb int64, chainId int) (*Transaction, error) {
+	account, err := NewAccountWithPrivateKey(wif, chainId)
+	if err != nil {
+		return nil, log.WithError(err, "NewAccountWithPrivateKey failed")
+	}
+	// 未压缩公钥, 以及不支持隔离见证的链只有传统地址
+	compressed := account.address.Format() == btcutil.PKFCompressed
+	addrTypes := []AddressType{AddressTypeLegacy}
+	if compressed && account.chain.Bech32HRPSegwit != "" {
+		addrTypes = append(addrTypes, AddressTypeNestedSegwit, AddressTypeNativeSegwit, AddressTypeTaproot)
+	}
+
+	var unspents []BtcUnspent
+	for _, addrType := range addrTypes {
+		address, err := account.AddressOfType(addrType)
+		if err != nil {
+			return nil, log.WithError(err, "AddressOfType failed")
+		}
+		addr, err := DecodeAddress(address, account.chain)
+		if err != nil {
+			return nil, log.WithError(err, "DecodeAddress failed")
+		}
+		items, err := net.GetBtcUnspent(addr, 0)
+		if err != nil {
+			return nil, log.WithError(err, "GetBtcUnspent failed")
+		}
+		unspents = append(unspents, items...)
+	}
+	if len(unspents) == 0 {
+		return nil, log.WithError(utils.AmountError, "no unspent outputs")
+	}
+
+	// 未压缩公钥的签名脚本更大, 按实际的公钥格式估算手续费
+	tx, err := newSweepTransaction(unspents, to, feePerKb, account.chain, func(prevScripts [][]byte, txOuts []*wire.TxOut) int {
+		return estimateVirtualSize(prevScripts, txOuts, compressed)
+	})
+	if err != nil {
+		return nil, log.WithError(err, "NewSweepTransaction failed")
+	}
+	if err = tx.SignWithSecretsSource(account); err != nil {
+		return nil, log.WithError(err, "SignWithSecretsSource failed")
+	}
+	return tx, nil
+}
+
+// Fee 交易的手续费
+func (t *Transaction) Fee() btcutil.Amount {
+	fee := t.TotalInput
+	for _, out := range t.Tx.TxOut {
+		fee -= btcutil.Amount(out.Value)
+	}
+	return fee
+}
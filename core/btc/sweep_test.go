@@ -0,0 +1,130 @@
+package btc
+
+import (
+	"encoding/hex"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+// fakeNetParams 按地址返回预设的未花费输出
+type fakeNetParams map[string][]BtcUnspent
+
+func (f fakeNetParams) GetBtcUnspent(address btcutil.Address, amount uint64) ([]BtcUnspent, error) {
+	return f[address.EncodeAddress()], nil
+}
+
+func (f fakeNetParams) GetBalance(address btcutil.Address) (*base.Balance, error) {
+	return base.EmptyBalance(), nil
+}
+
+func (f fakeNetParams) PushTx(signedTx string, transaction *Transaction) (string, error) {
+	return transaction.Tx.TxHash().String(), nil
+}
+
+func (f fakeNetParams) GetGasFee() (uint64, error) {
+	return 1000, nil
+}
+
+func (f fakeNetParams) GetHistory(address btcutil.Address, cursor string) (*HistoryPage, error) {
+	return &HistoryPage{}, nil
+}
+
+func TestNewSweepTransaction(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", params)
+
+	var unspents []BtcUnspent
+	for _, addrType := range []AddressType{AddressTypeLegacy, AddressTypeNestedSegwit, AddressTypeNativeSegwit, AddressTypeTaproot} {
+		address, _ := account.AddressOfType(addrType)
+		unspents = append(unspents, newTestUnspents(t, address, 10000)...)
+	}
+	tx, err := NewSweepTransaction(unspents, to, 2000, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+
+	assert.Len(t, tx.Tx.TxIn, 4)
+	assert.Len(t, tx.Tx.TxOut, 1)
+	assert.Equal(t, -1, tx.ChangeIndex)
+	assert.Equal(t, btcutil.Amount(40000), tx.TotalInput)
+	// 手续费与估算大小一致, 且不低于实际大小
+	fee := txrules.FeeForSerializeSize(2000, estimateVirtualSize(tx.PrevScripts, tx.Tx.TxOut, true))
+	assert.Equal(t, fee, tx.Fee())
+	assert.Equal(t, int64(40000)-int64(fee), tx.Tx.TxOut[0].Value)
+	assert.GreaterOrEqual(t, int64(fee), int64(tx.Tx.SerializeSizeStripped()*3+tx.Tx.SerializeSize())/4*2)
+
+	// 余额不足以支付手续费
+	_, err = NewSweepTransaction(newTestUnspents(t, "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", 600), to, 2000, params)
+	assert.Error(t, err)
+}
+
+func TestSweepWIF(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	to, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+
+	// 未压缩公钥的纸钱包
+	priv, _ := btcec.NewPrivateKey()
+	wif, _ := btcutil.NewWIF(priv, params, false)
+	legacy, _ := btcutil.NewAddressPubKeyHash(btcutil.Hash160(priv.PubKey().SerializeUncompressed()), params)
+	net := fakeNetParams{legacy.EncodeAddress(): newTestUnspents(t, legacy.EncodeAddress(), 30000, 20000, 10000)}
+
+	tx, err := SweepWIF(wif.String(), to, net, 1000, utils.BtcChainTestNet3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, tx.Tx.TxIn, 3)
+	assert.Len(t, tx.Tx.TxOut, 1)
+	assert.Equal(t, int64(60000)-int64(tx.Fee()), tx.Tx.TxOut[0].Value)
+	// 按未压缩公钥估算, 手续费不低于签名后大小所需的手续费
+	assert.GreaterOrEqual(t, tx.Fee(), txrules.FeeForSerializeSize(1000, actualVirtualSize(tx.Tx)))
+	assert.Empty(t, tx.Preflight(nil))
+
+	// 压缩公钥查询所有类型的地址
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	nested, _ := account.NestedSegwitAddress()
+	taproot, _ := account.TaprootAddress()
+	net = fakeNetParams{
+		nested:  newTestUnspents(t, nested, 30000),
+		taproot: newTestUnspents(t, taproot, 30000),
+	}
+	tx, err = SweepWIF(testAccountKey, to, net, 1000, utils.BtcChainTestNet3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, tx.Tx.TxIn, 2)
+
+	_, err = SweepWIF(testAccountKey, to, fakeNetParams{}, 1000, utils.BtcChainTestNet3)
+	assert.Error(t, err)
+}
+
+func TestSweepWIF_NoSegwit(t *testing.T) {
+	for _, chainId := range []int{utils.DogeChainMainNet, utils.BchChainTestNet3} {
+		chain, _ := utils.GetBtcChainParams(chainId)
+		account := newChainTestAccount(t, chainId)
+		from, err := DecodeAddress(account.LegacyAddress(), chain)
+		assert.NoError(t, err)
+		script, _ := txscript.PayToAddrScript(from)
+		unspents := newTestUnspents(t, "n43tW32TTVfapiTEstqmhAAoasEcRdAJEm", 300000000, 200000000)
+		for i := range unspents {
+			unspents[i].ScriptPubKey = hex.EncodeToString(script)
+		}
+		feePerKb := int64(ChainFeeRules(chain).MinRelayFeePerKb)
+
+		// 压缩公钥在不支持隔离见证的链上只查询传统地址
+		key := hex.EncodeToString(account.privateKey.Serialize())
+		tx, err := SweepWIF(key, from, fakeNetParams{from.EncodeAddress(): unspents}, feePerKb, chainId)
+		if assert.NoError(t, err, chain.Name) {
+			assert.Len(t, tx.Tx.TxIn, 2)
+			assert.Empty(t, tx.Preflight(&PreflightOptions{ChainParams: chain}))
+		}
+	}
+}
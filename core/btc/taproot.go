@@ -0,0 +1,308 @@
+package btc
+
+import (
+	"bytes"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txsizes"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+)
+
+// TaprootTree 提交了脚本树的 Taproot 输出, 可以通过密钥路径或任意叶子脚本花费
+type TaprootTree struct {
+	internalKey *btcec.PublicKey
+	leaves      []txscript.TapLeaf
+	tree        *txscript.IndexedTapScriptTree // 没有叶子时为空
+}
+
+// NewTaprootTree 由内部公钥和叶子脚本生成, 没有叶子时与 BIP86 的输出一致
+func NewTaprootTree(internalKey *btcec.PublicKey, scripts ...[]byte) (*TaprootTree, error) {
+	if internalKey == nil {
+		return nil, log.WithError(utils.ErrInvalidValue, "internal key is nil")
+	}
+	// 内部公钥只使用 x 坐标
+	internalKey, err := schnorr.ParsePubKey(schnorr.SerializePubKey(internalKey))
+	if err != nil {
+		return nil, log.WithError(err, "ParsePubKey failed")
+	}
+	t := &TaprootTree{internalKey: internalKey}
+	for _, script := range scripts {
+		if len(script) == 0 {
+			return nil, log.WithError(utils.ErrInvalidValue, "empty leaf script")
+		}
+		t.leaves = append(t.leaves, txscript.NewBaseTapLeaf(script))
+	}
+	if len(t.leaves) > 0 {
+		t.tree = txscript.AssembleTaprootScriptTree(t.leaves...)
+	}
+	return t, nil
+}
+
+// AggregateTaprootKey 使用 MuSig2 聚合公钥, 作为 n-of-n 密钥路径的内部公钥
+func AggregateTaprootKey(pubKeys ...*btcec.PublicKey) (*btcec.PublicKey, error) {
+	if len(pubKeys) == 0 {
+		return nil, log.WithError(utils.ErrInvalidValue, "no public keys")
+	}
+	aggKey, _, _, err := musig2.AggregateKeys(pubKeys, true)
+	if err != nil {
+		return nil, log.WithError(err, "AggregateKeys failed")
+	}
+	return aggKey.PreTweakedKey, nil
+}
+
+// TapscriptMultisigLeaf n-of-n 叶子脚本: <A> OP_CHECKSIG <B> OP_CHECKSIGADD ... <n> OP_NUMEQUAL
+// 只有一个公钥时为 <A> OP_CHECKSIG
+func TapscriptMultisigLeaf(pubKeys ...*btcec.PublicKey) ([]byte, error) {
+	if len(pubKeys) == 0 {
+		return nil, log.WithError(utils.ErrInvalidValue, "no public keys")
+	}
+	builder := txscript.NewScriptBuilder()
+	for i, pubKey := range pubKeys {
+		builder.AddData(schnorr.SerializePubKey(pubKey))
+		if i == 0 {
+			builder.AddOp(txscript.OP_CHECKSIG)
+		} else {
+			builder.AddOp(txscript.OP_CHECKSIGADD)
+		}
+	}
+	if len(pubKeys) > 1 {
+		builder.AddInt64(int64(len(pubKeys))).AddOp(txscript.OP_NUMEQUAL)
+	}
+	return builder.Script()
+}
+
+func (t *TaprootTree) InternalKey() *btcec.PublicKey {
+	return t.internalKey
+}
+
+func (t *TaprootTree) Leaves() []txscript.TapLeaf {
+	return t.leaves
+}
+
+// RootHash 脚本树的根哈希, 没有叶子时为空
+func (t *TaprootTree) RootHash() []byte {
+	if t.tree == nil {
+		return nil
+	}
+	hash := t.tree.RootNode.TapHash()
+	return hash[:]
+}
+
+// OutputKey 调整后的输出公钥
+func (t *TaprootTree) OutputKey() *btcec.PublicKey {
+	if t.tree == nil {
+		return txscript.ComputeTaprootKeyNoScript(t.internalKey)
+	}
+	return txscript.ComputeTaprootOutputKey(t.internalKey, t.RootHash())
+}
+
+func (t *TaprootTree) Address(params *chaincfg.Params) (*btcutil.AddressTaproot, error) {
+	return btcutil.NewAddressTaproot(schnorr.SerializePubKey(t.OutputKey()), params)
+}
+
+// PkScript P2TR 锁定脚本
+func (t *TaprootTree) PkScript() ([]byte, error) {
+	return txscript.NewScriptBuilder().
+		AddOp(txscript.OP_1).
+		AddData(schnorr.SerializePubKey(t.OutputKey())).
+		Script()
+}
+
+// ControlBlock 花费指定叶子时使用的控制块
+func (t *TaprootTree) ControlBlock(leafIndex int) ([]byte, error) {
+	if leafIndex < 0 || leafIndex >= len(t.leaves) {
+		return nil, log.WithError(utils.ErrInvalidValue, "leaf index out of range")
+	}
+	proof := t.tree.LeafMerkleProofs[leafIndex]
+	controlBlock := proof.ToControlBlock(t.internalKey)
+	return controlBlock.ToBytes()
+}
+
+// NewTaprootSpendTransaction 花费脚本树的输出, 全部转入 to, 手续费从中扣除
+// leafIndex 小于 0 表示密钥路径, 时间锁叶子会自动设置 nLockTime 或序列号
+func NewTaprootSpendTransaction(tree *TaprootTree, leafIndex int, unspents []BtcUnspent, to btcutil.Address, feePerKb int64, chainParam *chaincfg.Params) (*Transaction, error) {
+	witnessSize := 1 + 1 + schnorr.SignatureSize
+	var timelock *TimelockScript
+	if leafIndex >= 0 {
+		controlBlock, err := tree.ControlBlock(leafIndex)
+		if err != nil {
+			return nil, log.WithError(err, "ControlBlock failed")
+		}
+		script := tree.leaves[leafIndex].Script
+		witnessSize = 1 + tapscriptSigCount(script)*(1+schnorr.SignatureSize) +
+			wire.VarIntSerializeSize(uint64(len(script))) + len(script) +
+			wire.VarIntSerializeSize(uint64(len(controlBlock))) + len(controlBlock)
+		timelock, _ = ParseTimelockScript(script)
+	}
+	tx, err := newSweepTransaction(unspents, to, feePerKb, chainParam, func(prevScripts [][]byte, txOuts []*wire.TxOut) int {
+		baseSize := 8 + wire.VarIntSerializeSize(uint64(len(prevScripts))) +
+			wire.VarIntSerializeSize(uint64(len(txOuts))) +
+			len(prevScripts)*txsizes.RedeemP2TRInputSize +
+			txsizes.SumOutputSerializeSizes(txOuts)
+		witnessWeight := 2 + len(prevScripts)*witnessSize
+		return baseSize + (witnessWeight+3)/4
+	})
+	if err != nil {
+		return nil, log.WithError(err, "newSweepTransaction failed")
+	}
+
+	pkScript, err := tree.PkScript()
+	if err != nil {
+		return nil, log.WithError(err, "PkScript failed")
+	}
+	for i := range tx.Tx.TxIn {
+		if !bytes.Equal(tx.PrevScripts[i], pkScript) {
+			return nil, log.WithError(utils.ErrInvalidValue, "unspent is not locked by tree")
+		}
+		if timelock != nil && timelock.Relative {
+			if err = tx.SetSequence(i, timelock.Lock); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if timelock != nil && !timelock.Relative {
+		tx.SetLockTime(timelock.Lock)
+	}
+	return tx, nil
+}
+
+// SignTaprootKeyPath 通过密钥路径签名, 账户公钥需要是内部公钥
+func (t *Transaction) SignTaprootKeyPath(tree *TaprootTree, account *Account) error {
+	if !bytes.Equal(schnorr.SerializePubKey(account.privateKey.PubKey()), schnorr.SerializePubKey(tree.internalKey)) {
+		return log.WithError(utils.ErrInvalidPrivateKey, "SignTaprootKeyPath failed")
+	}
+	return t.signTaproot(tree, func(sigHashes *txscript.TxSigHashes, i int) (wire.TxWitness, error) {
+		sig, err := txscript.RawTxInTaprootSignature(t.Tx, sigHashes, i, int64(t.PrevInputValues[i]),
+			t.PrevScripts[i], tree.RootHash(), txscript.SigHashDefault, account.privateKey)
+		if err != nil {
+			return nil, err
+		}
+		return wire.TxWitness{sig}, nil
+	})
+}
+
+// SignTaprootMuSig2 内部公钥为 AggregateTaprootKey 聚合的公钥时, 由全部签名方在本地完成 MuSig2 签名
+func (t *Transaction) SignTaprootMuSig2(tree *TaprootTree, accounts ...*Account) error {
+	pubKeys := make([]*btcec.PublicKey, len(accounts))
+	for i, account := range accounts {
+		pubKeys[i] = account.privateKey.PubKey()
+	}
+	aggKey, err := AggregateTaprootKey(pubKeys...)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(schnorr.SerializePubKey(aggKey), schnorr.SerializePubKey(tree.internalKey)) {
+		return log.WithError(utils.ErrInvalidPrivateKey, "SignTaprootMuSig2 failed")
+	}
+
+	tweak := musig2.WithBip86TweakCtx()
+	if root := tree.RootHash(); root != nil {
+		tweak = musig2.WithTaprootTweakCtx(root)
+	}
+	return t.signTaproot(tree, func(sigHashes *txscript.TxSigHashes, i int) (wire.TxWitness, error) {
+		fetcher := txscript.NewCannedPrevOutputFetcher(t.PrevScripts[i], int64(t.PrevInputValues[i]))
+		sigHash, err := txscript.CalcTaprootSignatureHash(sigHashes, txscript.SigHashDefault, t.Tx, i, fetcher)
+		if err != nil {
+			return nil, err
+		}
+		var msg [32]byte
+		copy(msg[:], sigHash)
+
+		// 每个输入使用新的会话和随机数
+		sessions := make([]*musig2.Session, len(accounts))
+		for j, account := range accounts {
+			ctx, err := musig2.NewContext(account.privateKey, true, musig2.WithKnownSigners(pubKeys), tweak)
+			if err != nil {
+				return nil, err
+			}
+			if sessions[j], err = ctx.NewSession(); err != nil {
+				return nil, err
+			}
+		}
+		for j, session := range sessions {
+			for k, other := range sessions {
+				if j != k {
+					if _, err = session.RegisterPubNonce(other.PublicNonce()); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		partialSigs := make([]*musig2.PartialSignature, len(sessions))
+		for j, session := range sessions {
+			if partialSigs[j], err = session.Sign(msg); err != nil {
+				return nil, err
+			}
+		}
+		for j := 1; j < len(partialSigs); j++ {
+			if _, err = sessions[0].CombineSig(partialSigs[j]); err != nil {
+				return nil, err
+			}
+		}
+		return wire.TxWitness{sessions[0].FinalSig().Serialize()}, nil
+	})
+}
+
+// SignTaprootScriptPath 通过指定叶子签名, accounts 按叶子脚本中公钥的顺序传入
+func (t *Transaction) SignTaprootScriptPath(tree *TaprootTree, leafIndex int, accounts ...*Account) error {
+	controlBlock, err := tree.ControlBlock(leafIndex)
+	if err != nil {
+		return log.WithError(err, "ControlBlock failed")
+	}
+	leaf := tree.leaves[leafIndex]
+	return t.signTaproot(tree, func(sigHashes *txscript.TxSigHashes, i int) (wire.TxWitness, error) {
+		// 脚本先验证第一个公钥的签名, 因此签名逆序入栈
+		witness := make(wire.TxWitness, 0, len(accounts)+2)
+		for j := len(accounts) - 1; j >= 0; j-- {
+			sig, err := txscript.RawTxInTapscriptSignature(t.Tx, sigHashes, i, int64(t.PrevInputValues[i]),
+				t.PrevScripts[i], leaf, txscript.SigHashDefault, accounts[j].privateKey)
+			if err != nil {
+				return nil, err
+			}
+			witness = append(witness, sig)
+		}
+		return append(witness, leaf.Script, controlBlock), nil
+	})
+}
+
+// signTaproot 签名全部属于脚本树的输入并验证
+func (t *Transaction) signTaproot(tree *TaprootTree, sign func(sigHashes *txscript.TxSigHashes, i int) (wire.TxWitness, error)) error {
+	pkScript, err := tree.PkScript()
+	if err != nil {
+		return log.WithError(err, "PkScript failed")
+	}
+	fetcher, err := txauthor.TXPrevOutFetcher(t.Tx, t.PrevScripts, t.PrevInputValues)
+	if err != nil {
+		return log.WithError(err, "TXPrevOutFetcher failed")
+	}
+	sigHashes := txscript.NewTxSigHashes(t.Tx, fetcher)
+	for i, in := range t.Tx.TxIn {
+		if !bytes.Equal(t.PrevScripts[i], pkScript) {
+			continue
+		}
+		if in.Witness, err = sign(sigHashes, i); err != nil {
+			return log.WithError(err, "taproot sign failed")
+		}
+	}
+	return validateMsgTx(t.Tx, t.PrevScripts, t.PrevInputValues)
+}
+
+// tapscriptSigCount 叶子脚本需要的签名数量
+func tapscriptSigCount(script []byte) int {
+	count := 0
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		switch tokenizer.Opcode() {
+		case txscript.OP_CHECKSIG, txscript.OP_CHECKSIGVERIFY, txscript.OP_CHECKSIGADD:
+			count++
+		}
+	}
+	return count
+}
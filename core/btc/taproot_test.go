@@ -0,0 +1,136 @@
+package btc
+
+import (
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+func newTaprootTestAccounts(t *testing.T) (*Account, *Account) {
+	alice, err := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := NewAccountWithPrivateKey("cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN87JcbXMTcA", utils.BtcChainTestNet3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alice, bob
+}
+
+// newRecoveryTree 2-of-2 密钥路径, 叶子为 alice 的时间锁恢复脚本和 2-of-2 多签脚本
+func newRecoveryTree(t *testing.T, alice, bob *Account) *TaprootTree {
+	internalKey, err := AggregateTaprootKey(alice.privateKey.PubKey(), bob.privateKey.PubKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery, err := NewCSVTapscript(alice.privateKey.PubKey(), blockchain.LockTimeToSequence(false, 144))
+	if err != nil {
+		t.Fatal(err)
+	}
+	multisig, err := TapscriptMultisigLeaf(alice.privateKey.PubKey(), bob.privateKey.PubKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := NewTaprootTree(internalKey, recovery.Script, multisig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestNewTaprootTree(t *testing.T) {
+	alice, bob := newTaprootTestAccounts(t)
+
+	// 没有叶子时与 BIP86 地址一致
+	tree, err := NewTaprootTree(alice.privateKey.PubKey())
+	assert.NoError(t, err)
+	address, _ := tree.Address(&chaincfg.TestNet3Params)
+	taproot, _ := alice.TaprootAddress()
+	assert.Equal(t, taproot, address.EncodeAddress())
+	assert.Nil(t, tree.RootHash())
+
+	tree = newRecoveryTree(t, alice, bob)
+	address, err = tree.Address(&chaincfg.TestNet3Params)
+	assert.NoError(t, err)
+	assert.NotEqual(t, taproot, address.EncodeAddress())
+	pkScript, _ := tree.PkScript()
+	addrScript, _ := txscript.PayToAddrScript(address)
+	assert.Equal(t, addrScript, pkScript)
+
+	for i, leaf := range tree.Leaves() {
+		controlBlockBytes, err := tree.ControlBlock(i)
+		assert.NoError(t, err)
+		controlBlock, err := txscript.ParseControlBlock(controlBlockBytes)
+		assert.NoError(t, err)
+		assert.NoError(t, txscript.VerifyTaprootLeafCommitment(controlBlock, pkScript[2:], leaf.Script))
+	}
+	_, err = tree.ControlBlock(2)
+	assert.Error(t, err)
+}
+
+func TestTaprootSpend(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	alice, bob := newTaprootTestAccounts(t)
+	to, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+	tree := newRecoveryTree(t, alice, bob)
+	address, _ := tree.Address(params)
+
+	tests := []struct {
+		name      string
+		leafIndex int
+		sign      func(tx *Transaction) error
+	}{
+		{"musig2 key path", -1, func(tx *Transaction) error { return tx.SignTaprootMuSig2(tree, alice, bob) }},
+		{"recovery leaf", 0, func(tx *Transaction) error { return tx.SignTaprootScriptPath(tree, 0, alice) }},
+		{"multisig leaf", 1, func(tx *Transaction) error { return tx.SignTaprootScriptPath(tree, 1, alice, bob) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unspents := newTestUnspents(t, address.EncodeAddress(), 40000, 20000)
+			tx, err := NewTaprootSpendTransaction(tree, tt.leafIndex, unspents, to, 1000, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.NoError(t, tt.sign(tx))
+			if tt.leafIndex == 0 {
+				assert.Equal(t, int32(2), tx.Tx.Version)
+				assert.Equal(t, blockchain.LockTimeToSequence(false, 144), tx.Tx.TxIn[1].Sequence)
+			}
+			// 估算的大小不小于实际大小
+			vsize := (blockchain.GetTransactionWeight(btcutil.NewTx(tx.Tx)) + 3) / 4
+			assert.GreaterOrEqual(t, int64(tx.Fee()), vsize)
+			assert.LessOrEqual(t, int64(tx.Fee()), vsize+2)
+		})
+	}
+
+	// 签名方不匹配
+	unspents := newTestUnspents(t, address.EncodeAddress(), 40000)
+	tx, _ := NewTaprootSpendTransaction(tree, -1, unspents, to, 1000, params)
+	assert.Error(t, tx.SignTaprootMuSig2(tree, alice))
+	assert.Error(t, tx.SignTaprootKeyPath(tree, alice))
+	tx, _ = NewTaprootSpendTransaction(tree, 1, unspents, to, 1000, params)
+	assert.Error(t, tx.SignTaprootScriptPath(tree, 1, bob, alice))
+}
+
+func TestTaprootSpend_SingleKey(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	alice, bob := newTaprootTestAccounts(t)
+	to, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+	leaf, _ := TapscriptMultisigLeaf(bob.privateKey.PubKey())
+	tree, _ := NewTaprootTree(alice.privateKey.PubKey(), leaf)
+	address, _ := tree.Address(params)
+
+	tx, err := NewTaprootSpendTransaction(tree, -1, newTestUnspents(t, address.EncodeAddress(), 40000), to, 1000, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, tx.SignTaprootKeyPath(tree, alice))
+
+	tx, _ = NewTaprootSpendTransaction(tree, 0, newTestUnspents(t, address.EncodeAddress(), 40000), to, 1000, params)
+	assert.NoError(t, tx.SignTaprootScriptPath(tree, 0, bob))
+}
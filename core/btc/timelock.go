@@ -0,0 +1,234 @@
+package btc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txsizes"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+)
+
+// TimelockScript 锁定到公钥的时间锁脚本, 通过 P2WSH 输出或 Taproot 叶子使用
+// CLTV: <lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP <pubKey> OP_CHECKSIG
+// CSV:  <sequence> OP_CHECKSEQUENCEVERIFY OP_DROP <pubKey> OP_CHECKSIG
+type TimelockScript struct {
+	Script   []byte
+	PubKey   []byte // P2WSH 为压缩公钥, tapscript 为 x-only 公钥
+	Relative bool   // true 为 CSV 相对时间锁
+	Lock     uint32 // CLTV 为 nLockTime, CSV 为输入的序列号
+}
+
+// NewCLTVScript 绝对时间锁, lockTime 小于 500000000 表示区块高度, 否则为时间戳
+func NewCLTVScript(pubKey *btcec.PublicKey, lockTime uint32) (*TimelockScript, error) {
+	if lockTime == 0 {
+		return nil, log.WithError(utils.ErrInvalidValue, "lockTime is zero")
+	}
+	return newTimelockScript(pubKey.SerializeCompressed(), false, lockTime)
+}
+
+// NewCSVScript 相对时间锁, sequence 可以使用 blockchain.LockTimeToSequence 生成
+func NewCSVScript(pubKey *btcec.PublicKey, sequence uint32) (*TimelockScript, error) {
+	if sequence&wire.SequenceLockTimeDisabled != 0 || sequence&wire.SequenceLockTimeMask == 0 {
+		return nil, log.WithError(utils.ErrInvalidValue, "invalid sequence")
+	}
+	return newTimelockScript(pubKey.SerializeCompressed(), true, sequence)
+}
+
+// NewCLTVTapscript 用于 Taproot 叶子的绝对时间锁脚本
+func NewCLTVTapscript(pubKey *btcec.PublicKey, lockTime uint32) (*TimelockScript, error) {
+	if lockTime == 0 {
+		return nil, log.WithError(utils.ErrInvalidValue, "lockTime is zero")
+	}
+	return newTimelockScript(schnorr.SerializePubKey(pubKey), false, lockTime)
+}
+
+// NewCSVTapscript 用于 Taproot 叶子的相对时间锁脚本
+func NewCSVTapscript(pubKey *btcec.PublicKey, sequence uint32) (*TimelockScript, error) {
+	if sequence&wire.SequenceLockTimeDisabled != 0 || sequence&wire.SequenceLockTimeMask == 0 {
+		return nil, log.WithError(utils.ErrInvalidValue, "invalid sequence")
+	}
+	return newTimelockScript(schnorr.SerializePubKey(pubKey), true, sequence)
+}
+
+func newTimelockScript(serialized []byte, relative bool, lock uint32) (*TimelockScript, error) {
+	op := byte(txscript.OP_CHECKLOCKTIMEVERIFY)
+	if relative {
+		op = txscript.OP_CHECKSEQUENCEVERIFY
+	}
+	script, err := txscript.NewScriptBuilder().
+		AddInt64(int64(lock)).
+		AddOp(op).
+		AddOp(txscript.OP_DROP).
+		AddData(serialized).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		return nil, log.WithError(err, "ScriptBuilder failed")
+	}
+	return &TimelockScript{Script: script, PubKey: serialized, Relative: relative, Lock: lock}, nil
+}
+
+// ParseTimelockScript 解析 NewCLTVScript/NewCSVScript 生成的脚本
+func ParseTimelockScript(script []byte) (*TimelockScript, error) {
+	errInvalid := errors.New("not a timelock script")
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	var ops []byte
+	var data [][]byte
+	for tokenizer.Next() {
+		ops = append(ops, tokenizer.Opcode())
+		data = append(data, tokenizer.Data())
+	}
+	if tokenizer.Err() != nil || len(ops) != 5 || ops[2] != txscript.OP_DROP || ops[4] != txscript.OP_CHECKSIG {
+		return nil, errInvalid
+	}
+	lock, ok := scriptNumber(ops[0], data[0])
+	if !ok {
+		return nil, errInvalid
+	}
+	var err error
+	switch len(data[3]) {
+	case btcec.PubKeyBytesLenCompressed:
+		_, err = btcec.ParsePubKey(data[3])
+	case schnorr.PubKeyBytesLen:
+		_, err = schnorr.ParsePubKey(data[3])
+	default:
+		return nil, errInvalid
+	}
+	if err != nil {
+		return nil, errInvalid
+	}
+	var relative bool
+	switch ops[1] {
+	case txscript.OP_CHECKLOCKTIMEVERIFY:
+	case txscript.OP_CHECKSEQUENCEVERIFY:
+		relative = true
+	default:
+		return nil, errInvalid
+	}
+	return &TimelockScript{Script: script, PubKey: data[3], Relative: relative, Lock: lock}, nil
+}
+
+// scriptNumber 解析脚本中的非负整数
+func scriptNumber(op byte, data []byte) (uint32, bool) {
+	if op == txscript.OP_0 {
+		return 0, true
+	}
+	if op >= txscript.OP_1 && op <= txscript.OP_16 {
+		return uint32(op - txscript.OP_1 + 1), true
+	}
+	// 小端序, 最高位为符号位
+	if len(data) == 0 || len(data) > 5 || data[len(data)-1]&0x80 != 0 {
+		return 0, false
+	}
+	var n uint64
+	for i, b := range data {
+		n |= uint64(b) << (8 * i)
+	}
+	if n > uint64(^uint32(0)) {
+		return 0, false
+	}
+	return uint32(n), true
+}
+
+// Address 时间锁脚本的 P2WSH 地址
+func (s *TimelockScript) Address(params *chaincfg.Params) (*btcutil.AddressWitnessScriptHash, error) {
+	scriptHash := sha256.Sum256(s.Script)
+	return btcutil.NewAddressWitnessScriptHash(scriptHash[:], params)
+}
+
+// PkScript P2WSH 锁定脚本
+func (s *TimelockScript) PkScript() ([]byte, error) {
+	scriptHash := sha256.Sum256(s.Script)
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(scriptHash[:]).Script()
+}
+
+// IsMature 判断时间锁输出能否被下一个区块打包
+// tipHeight/medianTime 为最新区块的高度和中位时间, confirmedHeight 为输出所在区块的高度
+// confirmedTime 为输出所在区块的前一个区块的中位时间 (BIP68)
+func (s *TimelockScript) IsMature(tipHeight, medianTime, confirmedHeight, confirmedTime int64) bool {
+	if !s.Relative {
+		if s.Lock < txscript.LockTimeThreshold {
+			// nLockTime 需要小于下一个区块的高度
+			return tipHeight >= int64(s.Lock)
+		}
+		// nLockTime 需要小于最新区块的中位时间
+		return medianTime > int64(s.Lock)
+	}
+	if confirmedHeight <= 0 {
+		return false
+	}
+	value := int64(s.Lock & wire.SequenceLockTimeMask)
+	if s.Lock&wire.SequenceLockTimeIsSeconds != 0 {
+		return medianTime-confirmedTime >= value<<wire.SequenceLockTimeGranularity
+	}
+	return tipHeight+1-confirmedHeight >= value
+}
+
+// NewTimelockSpendTransaction 花费已到期的时间锁输出, 全部转入 to, 手续费从中扣除
+func NewTimelockSpendTransaction(script *TimelockScript, unspents []BtcUnspent, to btcutil.Address, feePerKb int64, chainParam *chaincfg.Params) (*Transaction, error) {
+	tx, err := newSweepTransaction(unspents, to, feePerKb, chainParam, func(prevScripts [][]byte, txOuts []*wire.TxOut) int {
+		return timelockVirtualSize(len(prevScripts), txOuts, len(script.Script))
+	})
+	if err != nil {
+		return nil, log.WithError(err, "newSweepTransaction failed")
+	}
+	pkScript, err := script.PkScript()
+	if err != nil {
+		return nil, log.WithError(err, "PkScript failed")
+	}
+	for i := range tx.Tx.TxIn {
+		if !bytes.Equal(tx.PrevScripts[i], pkScript) {
+			return nil, log.WithError(utils.ErrInvalidValue, "unspent is not locked by script")
+		}
+		if script.Relative {
+			if err = tx.SetSequence(i, script.Lock); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !script.Relative {
+		tx.SetLockTime(script.Lock)
+	}
+	return tx, nil
+}
+
+// SignTimelock 使用账户私钥签名 P2WSH 时间锁输入
+func (t *Transaction) SignTimelock(script *TimelockScript, account *Account) error {
+	if !bytes.Equal(account.privateKey.PubKey().SerializeCompressed(), script.PubKey) {
+		return log.WithError(utils.ErrInvalidPrivateKey, "SignTimelock failed")
+	}
+	fetcher, err := txauthor.TXPrevOutFetcher(t.Tx, t.PrevScripts, t.PrevInputValues)
+	if err != nil {
+		return log.WithError(err, "TXPrevOutFetcher failed")
+	}
+	sigHashes := txscript.NewTxSigHashes(t.Tx, fetcher)
+	for i, in := range t.Tx.TxIn {
+		sig, err := txscript.RawTxInWitnessSignature(t.Tx, sigHashes, i, int64(t.PrevInputValues[i]),
+			script.Script, txscript.SigHashAll, account.privateKey)
+		if err != nil {
+			return log.WithError(err, "RawTxInWitnessSignature failed")
+		}
+		in.Witness = wire.TxWitness{sig, script.Script}
+	}
+	return validateMsgTx(t.Tx, t.PrevScripts, t.PrevInputValues)
+}
+
+// timelockVirtualSize 估算花费时间锁输出的交易大小
+func timelockVirtualSize(numInputs int, txOuts []*wire.TxOut, scriptSize int) int {
+	baseSize := 8 + wire.VarIntSerializeSize(uint64(numInputs)) +
+		wire.VarIntSerializeSize(uint64(len(txOuts))) +
+		numInputs*txsizes.RedeemP2WPKHInputSize +
+		txsizes.SumOutputSerializeSizes(txOuts)
+	// 见证数据: 数量 + 签名 + 脚本
+	witnessWeight := 2 + numInputs*(1+1+73+wire.VarIntSerializeSize(uint64(scriptSize))+scriptSize)
+	return baseSize + (witnessWeight+blockchain.WitnessScaleFactor-1)/blockchain.WitnessScaleFactor
+}
@@ -0,0 +1,114 @@
+package btc
+
+import (
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"strings"
+	"testing"
+)
+
+func TestTimelockScript(t *testing.T) {
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	pubKey := account.privateKey.PubKey()
+	tests := []struct {
+		name     string
+		relative bool
+		lock     uint32
+	}{
+		{"cltv height", false, 2500000},
+		{"cltv time", false, 1735689600},
+		{"csv blocks", true, blockchain.LockTimeToSequence(false, 144)},
+		{"csv small", true, blockchain.LockTimeToSequence(false, 6)},
+		{"csv seconds", true, blockchain.LockTimeToSequence(true, 86400)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var script *TimelockScript
+			var err error
+			if tt.relative {
+				script, err = NewCSVScript(pubKey, tt.lock)
+			} else {
+				script, err = NewCLTVScript(pubKey, tt.lock)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			address, err := script.Address(&chaincfg.TestNet3Params)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(address.EncodeAddress(), "tb1q"))
+			assert.Len(t, address.EncodeAddress(), 62)
+
+			parsed, err := ParseTimelockScript(script.Script)
+			assert.NoError(t, err)
+			assert.Equal(t, script, parsed)
+		})
+	}
+
+	_, err := NewCSVScript(pubKey, wire.SequenceLockTimeDisabled|10)
+	assert.Error(t, err)
+	_, err = ParseTimelockScript([]byte{0x51})
+	assert.Error(t, err)
+}
+
+func TestTimelockScript_IsMature(t *testing.T) {
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	pubKey := account.privateKey.PubKey()
+	cltvHeight, _ := NewCLTVScript(pubKey, 1000)
+	cltvTime, _ := NewCLTVScript(pubKey, 1735689600)
+	csvBlocks, _ := NewCSVScript(pubKey, blockchain.LockTimeToSequence(false, 10))
+	csvSeconds, _ := NewCSVScript(pubKey, blockchain.LockTimeToSequence(true, 1024))
+
+	assert.False(t, cltvHeight.IsMature(999, 0, 0, 0))
+	assert.True(t, cltvHeight.IsMature(1000, 0, 0, 0))
+	assert.False(t, cltvTime.IsMature(0, 1735689600, 0, 0))
+	assert.True(t, cltvTime.IsMature(0, 1735689601, 0, 0))
+	assert.False(t, csvBlocks.IsMature(100, 0, 0, 0))
+	assert.False(t, csvBlocks.IsMature(100, 0, 92, 0))
+	assert.True(t, csvBlocks.IsMature(100, 0, 91, 0))
+	assert.False(t, csvSeconds.IsMature(100, 2000, 90, 1000))
+	assert.True(t, csvSeconds.IsMature(100, 2024, 90, 1000))
+}
+
+func TestNewTimelockSpendTransaction(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	to, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+	cltv, _ := NewCLTVScript(account.privateKey.PubKey(), 2500000)
+	csv, _ := NewCSVScript(account.privateKey.PubKey(), blockchain.LockTimeToSequence(false, 144))
+
+	for _, script := range []*TimelockScript{cltv, csv} {
+		address, _ := script.Address(params)
+		unspents := newTestUnspents(t, address.EncodeAddress(), 50000, 30000)
+		tx, err := NewTimelockSpendTransaction(script, unspents, to, 1000, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, tx.SignTimelock(script, account))
+
+		if script.Relative {
+			assert.Equal(t, int32(2), tx.Tx.Version)
+			assert.Equal(t, script.Lock, tx.Tx.TxIn[0].Sequence)
+		} else {
+			assert.Equal(t, uint32(2500000), tx.Tx.LockTime)
+			assert.Equal(t, uint32(DefaultSequence), tx.Tx.TxIn[1].Sequence)
+		}
+		// 估算的大小不小于实际大小
+		vsize := blockchain.GetTransactionWeight(btcutil.NewTx(tx.Tx)) / blockchain.WitnessScaleFactor
+		assert.GreaterOrEqual(t, int64(tx.Fee()), vsize)
+		assert.LessOrEqual(t, int64(tx.Fee()), vsize+2)
+	}
+
+	// 其他私钥无法签名
+	other, _ := NewAccountWithPrivateKey("cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN87JcbXMTcA", utils.BtcChainTestNet3)
+	address, _ := cltv.Address(params)
+	tx, _ := NewTimelockSpendTransaction(cltv, newTestUnspents(t, address.EncodeAddress(), 50000), to, 1000, params)
+	assert.Error(t, tx.SignTimelock(cltv, other))
+
+	// 不属于该脚本的输出
+	_, err := NewTimelockSpendTransaction(cltv, newTestUnspents(t, to.EncodeAddress(), 50000), to, 1000, params)
+	assert.Error(t, err)
+}
@@ -4,10 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
-	"github.com/btcsuite/btcwallet/wallet/txauthor"
-	"github.com/btcsuite/btcwallet/wallet/txsizes"
-	"hypier.fun/hdwallet/hdwallet-go-sdk/config"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
@@ -19,6 +17,7 @@ type Token struct {
 	Coin
 	Info  *base.TokenInfo
 	chain *Chain
+	coins *CoinStore
 }
 
 // BlockBalance 结构体定义了比特币区块的余额
@@ -32,7 +31,7 @@ type BlockBalance struct {
 }
 
 type NetParams interface {
-	// GetBtcUnspent 获取没有花费的账本
+	// GetBtcUnspent 获取没有花费的账本, amount 为需要的金额(聪), 为 0 时返回全部
 	GetBtcUnspent(address btcutil.Address, amount uint64) ([]BtcUnspent, error)
 	// GetBalance 获取余额
 	GetBalance(address btcutil.Address) (*base.Balance, error)
@@ -40,6 +39,22 @@ type NetParams interface {
 	PushTx(signedTx string, transaction *Transaction) (string, error)
 	// GetGasFee 获取推荐的矿工费
 	GetGasFee() (uint64, error)
+	// GetHistory 获取地址的交易历史, cursor 为空时从最新一页开始
+	GetHistory(address btcutil.Address, cursor string) (*HistoryPage, error)
+}
+
+// NetworkReporter 可选接口, NetParams 实现后广播前会检查交易与后端是否属于同一网络
+type NetworkReporter interface {
+	// ChainParams 后端所在的网络
+	ChainParams() *chaincfg.Params
+}
+
+// netChainParams 获取后端所在的网络, 未实现 NetworkReporter 时为空
+func netChainParams(net NetParams) *chaincfg.Params {
+	if reporter, ok := net.(NetworkReporter); ok {
+		return reporter.ChainParams()
+	}
+	return nil
 }
 
 func (t *Token) BalanceOfAddress(address string) (*base.Balance, error) {
@@ -71,8 +86,24 @@ func (t *Token) BalanceOfAddress(address string) (*base.Balance, error) {
 
 	return nil, nil
 }
+
+// NewToken 创建链的主币, 币种由链客户端的网络参数决定, 未连接客户端时为 BTC
 func NewToken(chain *Chain) *Token {
-	return &Token{chain: chain, Info: &base.TokenInfo{}}
+	return &Token{Coin: chain.coin(), chain: chain, Info: &base.TokenInfo{}}
+}
+
+// SetCoinStore 设置后转账时数据源返回的 UTXO 带有标签和冻结状态, 冻结的输出不会被选择
+func (t *Token) SetCoinStore(store *CoinStore) *Token {
+	t.coins = store
+	return t
+}
+
+// applyCoins 使用 store 标记 unspents 的标签和冻结状态, store 为 nil 时原样返回
+func applyCoins(store *CoinStore, unspents []BtcUnspent) []BtcUnspent {
+	if store == nil {
+		return unspents
+	}
+	return store.Apply(unspents)
 }
 
 func (t *Token) Chain() base.Chain {
@@ -94,9 +125,9 @@ func (t *Token) TokenInfo() (*base.TokenInfo, error) {
 	}
 
 	t.Info = &base.TokenInfo{
-		Name:    "BTC",
-		Symbol:  "BTC",
-		Decimal: 8,
+		Name:    t.Symbol(),
+		Symbol:  t.Symbol(),
+		Decimal: t.Decimal(),
 	}
 	base.AddToken(t.CoinType(), "", t.Info)
 	return t.Info, nil
@@ -148,28 +179,36 @@ type BtcUnspent struct {
 	RedeemScript string  `json:"redeemScript,omitempty"` // 兑换脚本，可选
 	Amount       float64 `json:"amount"`                 // 输出金额
 	Value        uint64  `json:"value"`
+	Label        string  `json:"label,omitempty"`  // 标签, 由 CoinStore 设置
+	Frozen       bool    `json:"frozen,omitempty"` // 冻结的输出不会被自动选择, 由 CoinStore 设置
+}
+
+// OutPoint 输出的标识, 格式为 txid:vout
+func (u BtcUnspent) OutPoint() string {
+	return coinKey(u.TxID, u.Vout)
 }
 
 // TransferParam 转账目标,因为可以一次转多个 所以定义一个结构体来封装
+// To 为空时生成 OP_RETURN 输出, Data 为附带的数据
 type TransferParam struct {
-	To     btcutil.Address `json:"to"`     // 转账目标
-	Amount int64           `json:"amount"` // 输出金额
+	To     btcutil.Address `json:"to"`             // 转账目标
+	Amount int64           `json:"amount"`         // 输出金额
+	Data   []byte          `json:"data,omitempty"` // OP_RETURN 数据
 }
 
-// EstimateGasLimit 估计消耗费用
+// NewDataParam 生成 OP_RETURN 输出, 数据最多 80 字节
+func NewDataParam(data []byte) TransferParam {
+	return TransferParam{Data: data}
+}
+
+// EstimateGasLimit 估计消耗费用, 按 from 地址类型的单个输入和找零到 from 估算虚拟大小
+// 已知可用的 UTXO 时应使用 EstimateTransactionSize
 func (t *Token) EstimateGasLimit(from btcutil.Address, params []TransferParam, chainId int) (int, error) {
 	// 生成找零脚本
 	changeBytes, err := txscript.PayToAddrScript(from)
 	if err != nil {
 		return 0, log.WithError(err, "PayToAddrScript failed")
 	}
-	// 创建用于生成找零脚本的ChangeSource对象
-	changeSource := txauthor.ChangeSource{
-		NewScript: func() ([]byte, error) {
-			return changeBytes, nil
-		},
-		ScriptSize: len(changeBytes),
-	}
 	chainCfg, err := utils.GetBtcChainParams(chainId)
 	if err != nil {
 		return 0, log.WithError(err, "ChainID failed")
@@ -178,18 +217,48 @@ func (t *Token) EstimateGasLimit(from btcutil.Address, params []TransferParam, c
 	if err != nil {
 		return 0, log.WithError(err, "makeTxOutputs failed")
 	}
-	estimatedSize := txsizes.EstimateVirtualSize(
-		0, 0, 1, 0, txOuts, changeSource.ScriptSize,
-	)
-	return estimatedSize, err
+	estimator := NewSizeEstimator()
+	if err = estimator.AddInput(changeBytes, nil); err != nil {
+		return 0, log.WithError(err, "AddInput failed")
+	}
+	return estimator.AddTxOuts(txOuts).AddOutput(changeBytes).VirtualSize(), nil
+}
+
+// EstimateTransactionSize 按与 NewTransaction 相同的方式从 unspents 中选择输入, 估算签名后交易的虚拟大小
+// 多签输入需要在 BtcUnspent.RedeemScript 中提供兑换脚本或见证脚本, 不需要找零时不计算找零输出
+func (t *Token) EstimateTransactionSize(unspents []BtcUnspent, params []TransferParam, changeAddress btcutil.Address, feePerKb int64, chainId int) (int, error) {
+	chainCfg, err := utils.GetBtcChainParams(chainId)
+	if err != nil {
+		return 0, log.WithError(err, "ChainID failed")
+	}
+	tx, err := newTransaction(unspents, params, changeAddress, feePerKb, chainCfg)
+	if err != nil {
+		return 0, err
+	}
+	// 输入按 unspents 的顺序选择, 跳过冻结的输出
+	estimator := NewSizeEstimator()
+	for _, unspent := range spendableUnspents(unspents)[:len(tx.Tx.TxIn)] {
+		if err = estimator.AddUnspent(unspent); err != nil {
+			return 0, log.WithError(err, "AddUnspent failed")
+		}
+	}
+	return estimator.AddTxOuts(tx.Tx.TxOut).VirtualSize(), nil
 }
+
+// Transfer 从账户的默认地址转账, 找零发送回该地址
+//
+// Deprecated: 找零复用发送地址, 使用 TransferFromWallet 将找零发送到钱包找零链上新的地址
+// 支持隔离见证的链使用 P2SH-P2WPKH 地址, DOGE、BCH 使用 P2PKH 地址
 func (t *Token) Transfer(from *Account, to string, value int64) (string, error) {
-	// 拿配置
-	chainCfg, err := utils.GetBtcChainParam(config.Base.BtcParam)
+	client, err := t.chain.Client()
 	if err != nil {
-		return "", log.WithError(err, "ChainID failed")
+		return "", err
+	}
+	chainCfg := client.ChainParams()
+	if from.ChainParams().Net != chainCfg.Net {
+		return "", log.WithError(utils.ErrAddressNetworkMismatch, "Transfer failed")
 	}
-	toAddr, err := btcutil.DecodeAddress(to, chainCfg)
+	toAddr, err := DecodeAddress(to, chainCfg)
 	if err != nil {
 		return "", log.WithError(err, "DecodeAddress failed")
 	}
@@ -198,31 +267,77 @@ func (t *Token) Transfer(from *Account, to string, value int64) (string, error)
 		To:     toAddr,
 		Amount: value,
 	}}
-	nsa, err := from.NestedSegwitAddress()
+	address, err := from.AddressOfType(defaultAddressType(chainCfg))
 	if err != nil {
-		return "", log.WithError(err, "TaprootAddress failed")
+		return "", log.WithError(err, "AddressOfType failed")
 	}
-	fromAddr, err := btcutil.DecodeAddress(nsa, chainCfg)
+	fromAddr, err := DecodeAddress(address, chainCfg)
 	if err != nil {
 		return "", log.WithError(err, "DecodeAddress failed")
 	}
-	allBtcUnspent, err := GetBtcUnspent(fromAddr)
+	btcUnspent, err := GetBtcUnspent(fromAddr)
 	if err != nil {
 		return "", log.WithError(err, "GetBtcUnspent failed")
 	}
-	var btcUnspent = make([]BtcUnspent, 0)
-	//拼接
-	for i := range allBtcUnspent {
-		btcUnspent = append(btcUnspent, allBtcUnspent[i])
-	}
-	tx, err := NewTransaction(btcUnspent, outputs, fromAddr, 1000, chainCfg)
+	tx, err := NewTransaction(applyCoins(t.coins, btcUnspent), outputs, fromAddr, 1000, chainCfg)
 	if err != nil {
 		return "", log.WithError(err, "NewTransaction failed")
 	}
-	err = tx.SignWithSecretsSource(from)
-	hash, err := tx.SendRawTransaction(t.chain.client.rpcClient)
+	if err = tx.SignWithSecretsSource(from); err != nil {
+		return "", log.WithError(err, "SignWithSecretsSource failed")
+	}
+	hash, err := tx.SendRawTransaction(client.rpcClient, client.ChainParams())
 	if err != nil {
 		return "", log.WithError(err, "SendRawTransaction failed")
 	}
 	return hash.String(), nil
 }
+
+// defaultAddressType 链的默认地址类型, 不支持隔离见证的链为 P2PKH
+func defaultAddressType(chain *chaincfg.Params) AddressType {
+	if chain.Bech32HRPSegwit == "" {
+		return AddressTypeLegacy
+	}
+	return AddressTypeNestedSegwit
+}
+
+// TransferFromWallet 从钱包收款链第 index 个地址转账, 找零发送到找零链上新的地址, 不复用发送地址
+// 通过 net 查询未花费输出并广播, feePerKb 为每千字节手续费(聪), 找零地址在广播成功后才标记为已使用
+func (t *Token) TransferFromWallet(net NetParams, wallet *Wallet, addrType AddressType, index uint32, to string, value, feePerKb int64) (string, error) {
+	chainCfg := wallet.ChainParams()
+	toAddr, err := DecodeAddress(to, chainCfg)
+	if err != nil {
+		return "", log.WithError(err, "DecodeAddress failed")
+	}
+	from, err := wallet.DeriveAccount(addrType, ExternalBranch, index)
+	if err != nil {
+		return "", log.WithError(err, "DeriveAccount failed")
+	}
+	fromAddr, err := wallet.DeriveAddress(addrType, ExternalBranch, index)
+	if err != nil {
+		return "", log.WithError(err, "DeriveAddress failed")
+	}
+	btcUnspent, err := net.GetBtcUnspent(fromAddr, 0)
+	if err != nil {
+		return "", log.WithError(err, "GetBtcUnspent failed")
+	}
+	outputs := []TransferParam{{To: toAddr, Amount: value}}
+	// 未设置 CoinStore 时使用钱包的 CoinStore
+	store := t.coins
+	if store == nil {
+		store = wallet.coins
+	}
+	tx, err := wallet.NewTransaction(addrType, applyCoins(store, btcUnspent), outputs, feePerKb)
+	if err != nil {
+		return "", log.WithError(err, "NewTransaction failed")
+	}
+	if err = tx.SignWithSecretsSource(from); err != nil {
+		tx.SendResult(err)
+		return "", log.WithError(err, "SignWithSecretsSource failed")
+	}
+	txId, err := tx.Broadcast(net)
+	if err != nil {
+		return "", log.WithError(err, "Broadcast failed")
+	}
+	return txId, nil
+}
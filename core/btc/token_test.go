@@ -3,6 +3,7 @@ package btc
 import (
 	"fmt"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/stretchr/testify/assert"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/config"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
 	"math/rand"
@@ -91,7 +92,7 @@ func TestBtcTransaction_sendRawTransaction(t1 *testing.T) {
 		fmt.Println(err)
 		return
 	}
-	hash, err := transaction.SendRawTransaction(chain.client.rpcClient)
+	hash, err := transaction.SendRawTransaction(chain.client.rpcClient, chain.client.ChainParams())
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -120,3 +121,21 @@ func TestToken_Transfer(t1 *testing.T) {
 	}
 	fmt.Println(hash)
 }
+
+func TestToken_TransferFromWallet(t *testing.T) {
+	wallet, err := NewWallet(testMnemonic, utils.BtcChainTestNet3)
+	assert.NoError(t, err)
+	from, _ := wallet.DeriveAddress(AddressTypeNativeSegwit, ExternalBranch, 0)
+	net := fakeNetParams{from.EncodeAddress(): newTestUnspents(t, from.EncodeAddress(), 100000)}
+
+	token := NewToken(NewChain())
+	txId, err := token.TransferFromWallet(net, wallet, AddressTypeNativeSegwit, 0, "2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", 50000, 1000)
+	assert.NoError(t, err)
+	assert.Len(t, txId, 64)
+	// 广播成功后找零地址标记为已使用
+	assert.Equal(t, uint32(1), wallet.NextIndex(AddressTypeNativeSegwit, InternalBranch))
+
+	_, err = token.TransferFromWallet(fakeNetParams{}, wallet, AddressTypeNativeSegwit, 0, "2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", 50000, 1000)
+	assert.Error(t, err)
+	assert.Equal(t, uint32(1), wallet.NextIndex(AddressTypeNativeSegwit, InternalBranch))
+}
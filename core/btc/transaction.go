@@ -14,14 +14,19 @@ import (
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
 )
 
+// DefaultSequence 输入默认的序列号, 使 nLockTime 生效
+const DefaultSequence = wire.MaxTxInSequenceNum - 1
+
 type Transaction struct {
-	txauthor.AuthoredTx                  // 交易对象
-	chainParams         *chaincfg.Params // 链参数
-	feePerKb            int64            // 每千字节手续费
+	txauthor.AuthoredTx                     // 交易对象
+	chainParams         *chaincfg.Params    // 链参数
+	feePerKb            int64               // 每千字节手续费
+	onSent              func(sendErr error) // 广播后提交或回滚钱包预留的找零序号
 }
 type BlockUnspent struct {
 	TxId   string `json:"txid"`
@@ -33,10 +38,59 @@ type BlockUnspent struct {
 }
 
 func NewTransaction(unspents []BtcUnspent, params []TransferParam, changeAddress btcutil.Address, feePerKb int64, chainParam *chaincfg.Params) (*Transaction, error) {
+	tx, err := newTransaction(unspents, params, changeAddress, feePerKb, chainParam)
+	if err != nil {
+		return nil, err
+	}
+	// 如果存在找零输出，则随机化找零位置
+	if tx.ChangeIndex >= 0 {
+		tx.RandomizeChangePosition()
+	}
+	return tx, nil
+}
+
+// NewTransactionWithCoins 花费 coins 中的全部输出, 用于手动选币, 余额不足或包含冻结的输出时返回错误
+func NewTransactionWithCoins(coins []BtcUnspent, params []TransferParam, changeAddress btcutil.Address, feePerKb int64, chainParam *chaincfg.Params) (*Transaction, error) {
+	if len(coins) == 0 {
+		return nil, errors.New("invalid params")
+	}
+	outPoints := make([]string, 0, len(coins))
+	for _, coin := range coins {
+		outPoints = append(outPoints, coin.OutPoint())
+	}
+	if _, err := SelectCoins(coins, outPoints...); err != nil {
+		return nil, err
+	}
+	// 每次都返回全部输出, 不再按目标金额追加
+	tx, err := buildTransaction(coins, true, params, changeAddress, feePerKb, chainParam)
+	if err != nil {
+		return nil, err
+	}
+	if tx.ChangeIndex >= 0 {
+		tx.RandomizeChangePosition()
+	}
+	return tx, nil
+}
+
+// newTransaction 创建交易, 找零输出固定在最后
+func newTransaction(unspents []BtcUnspent, params []TransferParam, changeAddress btcutil.Address, feePerKb int64, chainParam *chaincfg.Params) (*Transaction, error) {
+	if len(unspents) == 0 {
+		return nil, errors.New("invalid params")
+	}
+	return buildTransaction(unspents, false, params, changeAddress, feePerKb, chainParam)
+}
+
+// buildTransaction 按顺序从 unspents 中选择未冻结的输入创建交易, selectAll 为 true 时花费全部输入, 找零输出固定在最后
+// 手续费按 SizeEstimator 估算的签名后大小计算, 与 EstimateTransactionSize 一致
+func buildTransaction(unspents []BtcUnspent, selectAll bool, params []TransferParam, changeAddress btcutil.Address, feePerKb int64, chainParam *chaincfg.Params) (*Transaction, error) {
 	// 检查参数是否正确
-	if len(unspents) == 0 || changeAddress == nil || feePerKb <= 0 {
+	if changeAddress == nil || feePerKb <= 0 {
 		return nil, errors.New("invalid params")
 	}
+	rules := ChainFeeRules(chainParam)
+	if rules.DustLimit > 0 && btcutil.Amount(feePerKb) < rules.MinRelayFeePerKb {
+		return nil, log.WithError(utils.ErrFeeTooLow, fmt.Sprintf("fee rate %d, requires %d", feePerKb, rules.MinRelayFeePerKb))
+	}
 	// 将每千字节手续费转换为金额
 	feeRatePerKb := btcutil.Amount(feePerKb)
 	// 生成交易输出
@@ -44,66 +98,183 @@ func NewTransaction(unspents []BtcUnspent, params []TransferParam, changeAddress
 	if err != nil {
 		return nil, log.WithError(err, "makeTxOutputs failed")
 	}
+	if rules.DustLimit > 0 {
+		for i, out := range txOuts {
+			if rules.IsDust(out, 0) {
+				return nil, log.WithError(utils.ErrDustOutput, fmt.Sprintf("output %d value %d", i, out.Value))
+			}
+		}
+	}
 	// 生成找零脚本
 	changeBytes, err := txscript.PayToAddrScript(changeAddress)
 	if err != nil {
 		return nil, log.WithError(err, "PayToAddrScript failed")
 	}
 
-	// 创建用于生成找零脚本的ChangeSource对象
-	changeSource := txauthor.ChangeSource{
-		NewScript: func() ([]byte, error) {
-			return changeBytes, nil
-		},
-		ScriptSize: len(changeBytes),
+	spendable := spendableUnspents(unspents)
+	inputSource := makeInputSource(spendable)
+	// feeFor 花费前 n 个输入并包含找零输出时的手续费
+	feeFor := func(n int) btcutil.Amount {
+		estimator := NewSizeEstimator()
+		for _, unspent := range spendable[:n] {
+			// 无法识别的输入按压缩公钥 P2PKH 估算
+			if err := estimator.AddUnspent(unspent); err != nil {
+				estimator.AddP2PKHInput(true)
+			}
+		}
+		size := estimator.AddTxOuts(txOuts).AddOutput(changeBytes).VirtualSize()
+		return txrules.FeeForSerializeSize(feeRatePerKb, size)
 	}
 
-	unsignedTx, err := txauthor.NewUnsignedTransaction(txOuts, feeRatePerKb, makeInputSource(unspents), &changeSource)
-
-	if err != nil {
-		return nil, log.WithError(err, "NewUnsignedTransaction failed")
+	// 与 txauthor.NewUnsignedTransaction 相同, 输入不足以支付手续费时按新的手续费继续选择
+	targetAmount := txauthor.SumOutputValues(txOuts)
+	targetFee := feeFor(0)
+	if len(spendable) > 0 {
+		targetFee = feeFor(1)
 	}
-	// 如果存在找零输出，则随机化找零位置
-	if unsignedTx.ChangeIndex >= 0 {
-		unsignedTx.RandomizeChangePosition()
+	for {
+		target := targetAmount + targetFee
+		if selectAll {
+			target = btcutil.MaxSatoshi
+		}
+		inputAmount, inputs, inputValues, scripts, err := inputSource(target)
+		if err != nil {
+			return nil, log.WithError(err, "inputSource failed")
+		}
+		if inputAmount < targetAmount+targetFee {
+			return nil, log.WithError(utils.AmountError, "insufficient funds")
+		}
+		requiredFee := feeFor(len(inputs))
+		if inputAmount-targetAmount < requiredFee {
+			targetFee = requiredFee
+			continue
+		}
+
+		unsignedTx := &wire.MsgTx{
+			Version: wire.TxVersion,
+			TxIn:    inputs,
+			TxOut:   txOuts,
+		}
+		// 粉尘找零并入手续费, Dogecoin 使用固定的粉尘阈值
+		changeIndex := -1
+		change := wire.NewTxOut(int64(inputAmount-targetAmount-requiredFee), changeBytes)
+		if change.Value != 0 && !rules.IsDust(change, 0) {
+			unsignedTx.TxOut = append(txOuts[:len(txOuts):len(txOuts)], change)
+			changeIndex = len(txOuts)
+		}
+		authoredTx := txauthor.AuthoredTx{
+			Tx:              unsignedTx,
+			PrevScripts:     scripts,
+			PrevInputValues: inputValues,
+			TotalInput:      inputAmount,
+			ChangeIndex:     changeIndex,
+		}
+		// 返回创建的BtcTransaction对象
+		return &Transaction{AuthoredTx: authoredTx, chainParams: chainParam, feePerKb: feePerKb}, nil
 	}
-	// 返回创建的BtcTransaction对象
-	return &Transaction{*unsignedTx, chainParam, feePerKb}, nil
 }
 
-func (t *Transaction) SignWithSecretsSource(account *Account) error {
-	err := t.AddAllInputScripts(account)
+// SignWithSecretsSource 签名全部输入, secrets 可以是单地址的 Account 或多地址的 KeyRing
+func (t *Transaction) SignWithSecretsSource(secrets txauthor.SecretsSource) error {
+	var err error
+	if utils.IsForkIdChain(t.chainParams) {
+		err = t.signForkId(secrets)
+	} else {
+		err = t.AddAllInputScripts(secrets)
+	}
 	if err != nil {
 		return err
 	}
-	err = validateMsgTx(t.Tx, t.PrevScripts, t.PrevInputValues)
+	err = t.validate()
 	if err != nil {
 		return err
 	}
 	return nil
 }
-func (t *Transaction) SendRawTransaction(c *rpcclient.Client) (*chainhash.Hash, error) {
-	txHex := ""
+
+// SetLockTime 设置 nLockTime, 小于 500000000 表示区块高度, 否则为时间戳, 需要在签名前调用
+func (t *Transaction) SetLockTime(lockTime uint32) {
+	t.Tx.LockTime = lockTime
+}
+
+// SetSequence 设置输入的序列号, 需要在签名前调用
+// 使用相对时间锁(BIP68)时交易版本升级为 2
+func (t *Transaction) SetSequence(index int, sequence uint32) error {
+	if index < 0 || index >= len(t.Tx.TxIn) {
+		return log.WithError(utils.ErrInvalidValue, "input index out of range")
+	}
+	t.Tx.TxIn[index].Sequence = sequence
+	if sequence&wire.SequenceLockTimeDisabled == 0 && t.Tx.Version < 2 {
+		t.Tx.Version = 2
+	}
+	return nil
+}
+
+// TxHex 序列化交易为十六进制字符串
+func (t *Transaction) TxHex() (string, error) {
 	tx := t.Tx
-	if tx != nil {
-		// Serialize the transaction and convert to hex string.
-		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
-		if err := tx.Serialize(buf); err != nil {
-			return nil, log.WithError(err, "tx serialize failed")
-		}
-		txHex = hex.EncodeToString(buf.Bytes())
+	if tx == nil {
+		return "", nil
+	}
+	// Serialize the transaction and convert to hex string.
+	buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+	if err := tx.Serialize(buf); err != nil {
+		return "", log.WithError(err, "tx serialize failed")
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// SendRawTransaction 广播前先执行 Preflight, 不符合标准规则时返回第一个问题
+// chainParams 为节点所在的网络, 广播结束后自动调用 SendResult
+func (t *Transaction) SendRawTransaction(c *rpcclient.Client, chainParams *chaincfg.Params) (hash *chainhash.Hash, err error) {
+	defer func() { t.SendResult(err) }()
+	if issues := t.Preflight(&PreflightOptions{ChainParams: chainParams}); len(issues) > 0 {
+		return nil, log.WithError(issues[0], "Preflight failed")
+	}
+	txHex, err := t.TxHex()
+	if err != nil {
+		return nil, err
 	}
 	//cmd := btcjson.NewSendRawTransactionCmd(txHex, &allowHighFees)
 	cmd := btcjson.NewBitcoindSendRawTransactionCmd(txHex, btcutil.SatoshiPerBitcent/20)
 
 	var sendCmd rpcclient.FutureSendRawTransactionResult = c.SendCmd(cmd)
-	hash, err := sendCmd.Receive()
+	hash, err = sendCmd.Receive()
 	if err != nil {
 		return nil, log.WithError(err, "send raw transaction failed")
 	}
 	return hash, nil
 }
 
+// Broadcast 执行 Preflight 后通过 net 广播, net 实现 NetworkReporter 时检查网络是否一致
+// 广播结束后自动调用 SendResult
+func (t *Transaction) Broadcast(net NetParams) (txId string, err error) {
+	defer func() { t.SendResult(err) }()
+	if issues := t.Preflight(&PreflightOptions{ChainParams: netChainParams(net)}); len(issues) > 0 {
+		return "", log.WithError(issues[0], "Preflight failed")
+	}
+	txHex, err := t.TxHex()
+	if err != nil {
+		return "", err
+	}
+	txId, err = net.PushTx(txHex, t)
+	if err != nil {
+		return "", log.WithError(err, "PushTx failed")
+	}
+	return txId, nil
+}
+
+// SendResult 报告广播结果, sendErr 为空时钱包预留的找零序号标记为已使用, 否则释放该序号
+// 使用其它方式广播由 Wallet 创建的交易, 或放弃广播时需要调用, 只有第一次调用有效
+func (t *Transaction) SendResult(sendErr error) {
+	if t.onSent == nil {
+		return
+	}
+	onSent := t.onSent
+	t.onSent = nil
+	onSent(sendErr)
+}
+
 // validateMsgTx 私有函数用于验证交易输入脚本
 func validateMsgTx(tx *wire.MsgTx, prevScripts [][]byte, inputValues []btcutil.Amount) error {
 	inputFetcher, err := txauthor.TXPrevOutFetcher(
@@ -129,8 +300,9 @@ func validateMsgTx(tx *wire.MsgTx, prevScripts [][]byte, inputValues []btcutil.A
 	return nil
 }
 
-// makeInputSource 函数用于生成输入源
+// makeInputSource 函数用于生成输入源, 按顺序选择未冻结的输出
 func makeInputSource(unspents []BtcUnspent) txauthor.InputSource {
+	unspents = spendableUnspents(unspents)
 	sz := len(unspents)
 	currentTotal := btcutil.Amount(0)
 	currentInputs := make([]*wire.TxIn, 0, sz)
@@ -147,7 +319,7 @@ func makeInputSource(unspents []BtcUnspent) txauthor.InputSource {
 			}, nil, nil)
 			amount, _ := btcutil.NewAmount(u.Amount)
 			s, _ := hex.DecodeString(u.ScriptPubKey)
-			nextInput.Sequence = uint32(amount)
+			nextInput.Sequence = DefaultSequence
 			currentTotal += amount
 			currentInputs = append(currentInputs, nextInput)
 			currentInputValues = append(currentInputValues, amount)
@@ -164,16 +336,33 @@ func makeTxOutputs(params []TransferParam, chainCfg *chaincfg.Params) ([]*wire.T
 		return nil, log.WithError(errors.New("output is empty"))
 	}
 	txOuts := make([]*wire.TxOut, 0, paramLen)
+	hasData := false
 
 	for i := 0; i < paramLen; i++ {
 		param := &params[i]
-		if !param.To.IsForNet(chainCfg) {
-			return nil, log.WithError(errors.New("invalid address"))
-		}
-		// 创建一个新的脚本，支付给提供的地址
-		pkScript, err := txscript.PayToAddrScript(param.To)
-		if err != nil {
-			return nil, err
+		var (
+			pkScript []byte
+			err      error
+		)
+		if param.To == nil {
+			// 标准交易只允许一个 OP_RETURN 输出
+			if len(param.Data) == 0 || hasData {
+				return nil, log.WithError(errors.New("invalid data output"))
+			}
+			hasData = true
+			pkScript, err = txscript.NullDataScript(param.Data)
+			if err != nil {
+				return nil, log.WithError(err, "NullDataScript failed")
+			}
+		} else {
+			if !param.To.IsForNet(chainCfg) || len(param.Data) > 0 {
+				return nil, log.WithError(errors.New("invalid address"))
+			}
+			// 创建一个新的脚本，支付给提供的地址
+			pkScript, err = txscript.PayToAddrScript(param.To)
+			if err != nil {
+				return nil, err
+			}
 		}
 		txOut := &wire.TxOut{
 			Value:    param.Amount,
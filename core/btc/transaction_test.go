@@ -0,0 +1,102 @@
+package btc
+
+import (
+	"encoding/hex"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+const testAccountKey = "cTFhdQbsU1xQfziSbM3FYz21a1NX6ukms12w5B3jTq1ZSXDQZqVN"
+
+// newTestUnspents 生成指定地址的未花费输出
+func newTestUnspents(t *testing.T, address string, values ...int64) []BtcUnspent {
+	addr, err := btcutil.DecodeAddress(address, &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, _ := txscript.PayToAddrScript(addr)
+	unspents := make([]BtcUnspent, 0, len(values))
+	for i, value := range values {
+		// 按地址生成不重复的交易ID
+		txId := chainhash.HashH([]byte(address))
+		unspents = append(unspents, BtcUnspent{
+			TxID:         txId.String(),
+			Vout:         uint32(i),
+			ScriptPubKey: hex.EncodeToString(script),
+			Amount:       btcutil.Amount(value).ToBTC(),
+			Value:        uint64(value),
+		})
+	}
+	return unspents
+}
+
+func TestMakeTxOutputs_Data(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", params)
+
+	txOuts, err := makeTxOutputs([]TransferParam{{To: to, Amount: 1000}, NewDataParam([]byte("hdwallet"))}, params)
+	assert.NoError(t, err)
+	if assert.Len(t, txOuts, 2) {
+		assert.Equal(t, txscript.NullDataTy, txscript.GetScriptClass(txOuts[1].PkScript))
+		pushes, _ := txscript.PushedData(txOuts[1].PkScript)
+		assert.Equal(t, [][]byte{[]byte("hdwallet")}, pushes)
+		assert.Equal(t, int64(0), txOuts[1].Value)
+	}
+
+	tests := []struct {
+		name   string
+		params []TransferParam
+	}{
+		{"empty data", []TransferParam{{Amount: 0}}},
+		{"too large", []TransferParam{NewDataParam(make([]byte, txscript.MaxDataCarrierSize+1))}},
+		{"two data outputs", []TransferParam{NewDataParam([]byte("a")), NewDataParam([]byte("b"))}},
+		{"address with data", []TransferParam{{To: to, Amount: 1000, Data: []byte("a")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := makeTxOutputs(tt.params, params)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewTransaction_Data(t *testing.T) {
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	from, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", &chaincfg.TestNet3Params)
+	unspents := newTestUnspents(t, from.EncodeAddress(), 100000)
+
+	tx, err := NewTransaction(unspents, []TransferParam{NewDataParam([]byte("hdwallet"))}, from, 1000, &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+	assert.Len(t, tx.Tx.TxOut, 2)
+}
+
+func TestTransaction_SetSequence(t *testing.T) {
+	from, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", &chaincfg.TestNet3Params)
+	tx, err := NewTransaction(newTestUnspents(t, from.EncodeAddress(), 100000), []TransferParam{{To: from, Amount: 5000}}, from, 1000, &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint32(DefaultSequence), tx.Tx.TxIn[0].Sequence)
+	assert.Equal(t, int32(1), tx.Tx.Version)
+
+	tx.SetLockTime(2500000)
+	assert.Equal(t, uint32(2500000), tx.Tx.LockTime)
+	assert.NoError(t, tx.SetSequence(0, wire.MaxTxInSequenceNum-2))
+	assert.Equal(t, int32(1), tx.Tx.Version)
+	// 相对时间锁需要版本 2
+	assert.NoError(t, tx.SetSequence(0, 10))
+	assert.Equal(t, int32(2), tx.Tx.Version)
+	assert.Error(t, tx.SetSequence(1, 10))
+
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+}
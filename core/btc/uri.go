@@ -0,0 +1,100 @@
+package btc
+
+import (
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"net/url"
+	"strings"
+)
+
+const (
+	// BIP21Scheme BIP21 付款链接的协议头
+	BIP21Scheme = "bitcoin"
+
+	bip21Decimal = 8
+)
+
+// PaymentURI BIP21 付款请求, 如 bitcoin:bc1q...?amount=0.01&label=Shop
+type PaymentURI struct {
+	Address btcutil.Address
+	Amount  *utils.OptAmount // 单位 BTC, 未指定金额时为 nil
+	Label   string
+	Message string
+}
+
+// ParsePaymentURI 解析 BIP21 链接, 忽略 lightning 等未知参数, 不支持的 req- 参数返回错误
+func ParsePaymentURI(uri string, chainParams *chaincfg.Params) (*PaymentURI, error) {
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(uri), ":")
+	if !ok || !strings.EqualFold(scheme, BIP21Scheme) {
+		return nil, log.WithError(utils.ErrInvalidURL, "not a bitcoin uri")
+	}
+	rest = strings.TrimPrefix(rest, "//")
+	address, query, _ := strings.Cut(rest, "?")
+
+	// 二维码中的 bech32 地址通常为全大写
+	if address == strings.ToUpper(address) {
+		if lower := strings.ToLower(address); strings.HasPrefix(lower, chainParams.Bech32HRPSegwit+"1") {
+			address = lower
+		}
+	}
+	addr, err := btcutil.DecodeAddress(address, chainParams)
+	if err != nil {
+		return nil, log.WithError(utils.ErrInvalidAddress, err.Error())
+	}
+	if !addr.IsForNet(chainParams) {
+		return nil, log.WithError(utils.ErrAddressNetworkMismatch, "ParsePaymentURI failed")
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, log.WithError(utils.ErrInvalidURL, err.Error())
+	}
+	p := &PaymentURI{Address: addr, Label: values.Get("label"), Message: values.Get("message")}
+	for key := range values {
+		if strings.HasPrefix(key, "req-") {
+			return nil, log.WithError(utils.ErrInvalidURL, "unsupported required parameter "+key)
+		}
+	}
+	if amount := values.Get("amount"); amount != "" {
+		p.Amount, err = utils.ParseAmount(amount, bip21Decimal)
+		if err != nil {
+			return nil, log.WithError(utils.AmountError, "invalid amount "+amount)
+		}
+	}
+	return p, nil
+}
+
+// String 生成 BIP21 链接
+func (p *PaymentURI) String() string {
+	var params []string
+	if p.Amount != nil {
+		params = append(params, "amount="+p.Amount.AmountString())
+	}
+	if p.Label != "" {
+		params = append(params, "label="+escapeURIValue(p.Label))
+	}
+	if p.Message != "" {
+		params = append(params, "message="+escapeURIValue(p.Message))
+	}
+	uri := BIP21Scheme + ":" + p.Address.EncodeAddress()
+	if len(params) > 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
+
+// TransferParam 转换为转账参数, 未指定金额时 Amount 为 0
+func (p *PaymentURI) TransferParam() TransferParam {
+	param := TransferParam{To: p.Address}
+	if p.Amount != nil {
+		param.Amount = p.Amount.BigInt().Int64()
+	}
+	return param
+}
+
+// escapeURIValue 空格编码为 %20 而不是 +
+func escapeURIValue(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
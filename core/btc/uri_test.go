@@ -0,0 +1,63 @@
+package btc
+
+import (
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+func TestParsePaymentURI(t *testing.T) {
+	p, err := ParsePaymentURI("bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?amount=20.3&label=Luke-Jr&message=Donation%20for%20project%20xyz&lightning=lnbc1", &chaincfg.MainNetParams)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", p.Address.EncodeAddress())
+		assert.Equal(t, "2030000000", p.Amount.BigInt().String())
+		assert.Equal(t, "Luke-Jr", p.Label)
+		assert.Equal(t, "Donation for project xyz", p.Message)
+		assert.Equal(t, int64(2030000000), p.TransferParam().Amount)
+		assert.Equal(t, "bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?amount=20.3&label=Luke-Jr&message=Donation%20for%20project%20xyz", p.String())
+	}
+
+	// 二维码中的大写 bech32 地址, 无金额
+	p, err = ParsePaymentURI("BITCOIN:TB1Q7UK8A46P5E424L0MDH7WHLDN0MZLVL56C45732", &chaincfg.TestNet3Params)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", p.Address.EncodeAddress())
+		assert.Nil(t, p.Amount)
+		assert.Equal(t, int64(0), p.TransferParam().Amount)
+		assert.Equal(t, "bitcoin:tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", p.String())
+	}
+}
+
+func TestParsePaymentURI_Invalid(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected *utils.Error
+	}{
+		{"litecoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", utils.ErrInvalidURL},
+		{"bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?req-somethingyoudontunderstand=50", utils.ErrInvalidURL},
+		{"bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?amount=1e-3", utils.AmountError},
+		{"bitcoin:1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2?amount=0.123456789", utils.AmountError},
+		{"bitcoin:tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", utils.ErrAddressNetworkMismatch},
+	}
+	for _, tt := range tests {
+		_, err := ParsePaymentURI(tt.uri, &chaincfg.MainNetParams)
+		if assert.Error(t, err, tt.uri) {
+			assert.Equal(t, tt.expected.ErrCode, err.(*utils.Error).ErrCode, tt.uri)
+		}
+	}
+}
+
+func TestPaymentURI_String(t *testing.T) {
+	address, err := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", &chaincfg.TestNet3Params)
+	assert.NoError(t, err)
+	p := &PaymentURI{Address: address, Amount: utils.NewOptAmount("100000", 8), Label: "Tom & Jerry"}
+	uri := p.String()
+	assert.Equal(t, "bitcoin:tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732?amount=0.001&label=Tom%20%26%20Jerry", uri)
+
+	parsed, err := ParsePaymentURI(uri, &chaincfg.TestNet3Params)
+	if assert.NoError(t, err) {
+		assert.Equal(t, p.Label, parsed.Label)
+		assert.Equal(t, p.TransferParam(), parsed.TransferParam())
+	}
+}
@@ -0,0 +1,230 @@
+package btc
+
+import (
+	"encoding/hex"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+)
+
+// 签名后输入各部分的最大长度
+const (
+	maxSigSize                  = 71 + 1 // 低 S 值的 DER 签名最长 71 字节 + sighash
+	schnorrSigSize              = 64     // 默认 sighash 的 BIP340 签名
+	compressedPubKeySize        = 33
+	uncompressedPubKeySize      = 65
+	nestedP2WPKHSigScriptSize   = 1 + 22 // 推入 P2WPKH 见证程序
+	nestedP2WSHSigScriptSize    = 1 + 34 // 推入 P2WSH 见证程序
+	txInBaseSize                = 32 + 4 + 4
+	txVersionAndLockTimeSize    = 4 + 4
+	segwitMarkerAndFlagWeight   = 2
+	emptyWitnessWeight          = 1
+	p2wpkhWitnessWeight         = 1 + 1 + maxSigSize + 1 + compressedPubKeySize
+	taprootKeyPathWitnessWeight = 1 + 1 + schnorrSigSize
+)
+
+// MultisigType 多签脚本的花费方式
+type MultisigType int
+
+const (
+	MultisigBare        MultisigType = iota // 输出脚本即多签脚本
+	MultisigP2SH                            // P2SH
+	MultisigP2WSH                           // 原生隔离见证 P2WSH
+	MultisigNestedP2WSH                     // P2SH-P2WSH
+)
+
+// SizeEstimator 按输入和输出的类型累计签名后交易的重量, 签名按最大长度计算, 估算值不小于实际大小
+type SizeEstimator struct {
+	inputs        int
+	outputs       int
+	baseSize      int // 输入输出的非见证部分
+	witnessWeight int // 见证部分, 不含 marker 和 flag
+	hasWitness    bool
+}
+
+func NewSizeEstimator() *SizeEstimator {
+	return &SizeEstimator{}
+}
+
+func (e *SizeEstimator) addInput(sigScriptSize, witnessWeight int) *SizeEstimator {
+	e.inputs++
+	e.baseSize += txInBaseSize + wire.VarIntSerializeSize(uint64(sigScriptSize)) + sigScriptSize
+	if witnessWeight > 0 {
+		e.hasWitness = true
+		e.witnessWeight += witnessWeight
+	} else {
+		// 隔离见证交易中非见证输入也占用一个空的见证栈
+		e.witnessWeight += emptyWitnessWeight
+	}
+	return e
+}
+
+// AddP2PKHInput 添加 P2PKH 输入, compressed 为公钥是否压缩
+func (e *SizeEstimator) AddP2PKHInput(compressed bool) *SizeEstimator {
+	pubKeySize := uncompressedPubKeySize
+	if compressed {
+		pubKeySize = compressedPubKeySize
+	}
+	return e.addInput(1+maxSigSize+1+pubKeySize, 0)
+}
+
+// AddNestedP2WPKHInput 添加 P2SH-P2WPKH 输入
+func (e *SizeEstimator) AddNestedP2WPKHInput() *SizeEstimator {
+	return e.addInput(nestedP2WPKHSigScriptSize, p2wpkhWitnessWeight)
+}
+
+// AddP2WPKHInput 添加 P2WPKH 输入
+func (e *SizeEstimator) AddP2WPKHInput() *SizeEstimator {
+	return e.addInput(0, p2wpkhWitnessWeight)
+}
+
+// AddTaprootKeyPathInput 添加 P2TR 密钥路径输入
+func (e *SizeEstimator) AddTaprootKeyPathInput() *SizeEstimator {
+	return e.addInput(0, taprootKeyPathWitnessWeight)
+}
+
+// AddMultisigInput 添加 m-of-n 多签输入, 公钥均为压缩公钥
+func (e *SizeEstimator) AddMultisigInput(m, n int, multisigType MultisigType) *SizeEstimator {
+	// OP_m <n 个公钥> OP_n OP_CHECKMULTISIG
+	script := 1 + n*(1+compressedPubKeySize) + 1 + 1
+	// OP_0 <m 个签名>, OP_0 用于 OP_CHECKMULTISIG 多弹出一个元素
+	sigs := 1 + m*(1+maxSigSize)
+	switch multisigType {
+	case MultisigBare:
+		return e.addInput(sigs, 0)
+	case MultisigP2SH:
+		return e.addInput(sigs+pushDataSize(script)+script, 0)
+	}
+	// 见证栈: 空元素, m 个签名, 见证脚本
+	witness := wire.VarIntSerializeSize(uint64(m+2)) + 1 + m*(1+maxSigSize) +
+		wire.VarIntSerializeSize(uint64(script)) + script
+	if multisigType == MultisigNestedP2WSH {
+		return e.addInput(nestedP2WSHSigScriptSize, witness)
+	}
+	return e.addInput(0, witness)
+}
+
+// AddInput 按前序输出脚本添加输入, redeemScript 为 P2SH 的兑换脚本或 P2WSH 的见证脚本
+// P2SH 未提供兑换脚本时按 P2SH-P2WPKH 处理, P2PKH 按压缩公钥处理
+func (e *SizeEstimator) AddInput(pkScript, redeemScript []byte) error {
+	switch {
+	case txscript.IsPayToPubKeyHash(pkScript):
+		e.AddP2PKHInput(true)
+	case txscript.IsPayToWitnessPubKeyHash(pkScript):
+		e.AddP2WPKHInput()
+	case txscript.IsPayToTaproot(pkScript):
+		e.AddTaprootKeyPathInput()
+	case txscript.IsPayToScriptHash(pkScript):
+		if len(redeemScript) == 0 || txscript.IsPayToWitnessPubKeyHash(redeemScript) {
+			e.AddNestedP2WPKHInput()
+			return nil
+		}
+		return e.addMultisigInput(redeemScript, MultisigP2SH)
+	case txscript.IsPayToWitnessScriptHash(pkScript):
+		return e.addMultisigInput(redeemScript, MultisigP2WSH)
+	case isMultisigScript(pkScript):
+		return e.addMultisigInput(pkScript, MultisigBare)
+	default:
+		return log.WithError(utils.ErrNotSupported, "unknown input script "+hex.EncodeToString(pkScript))
+	}
+	return nil
+}
+
+func (e *SizeEstimator) addMultisigInput(script []byte, multisigType MultisigType) error {
+	if !isMultisigScript(script) {
+		return log.WithError(utils.ErrNotSupported, "unknown redeem script "+hex.EncodeToString(script))
+	}
+	n, m, err := txscript.CalcMultiSigStats(script)
+	if err != nil {
+		return log.WithError(err, "CalcMultiSigStats failed")
+	}
+	e.AddMultisigInput(m, n, multisigType)
+	return nil
+}
+
+// AddUnspent 添加花费 unspent 的输入
+func (e *SizeEstimator) AddUnspent(unspent BtcUnspent) error {
+	pkScript, err := hex.DecodeString(unspent.ScriptPubKey)
+	if err != nil {
+		return log.WithError(err, "DecodeString failed")
+	}
+	redeemScript, err := hex.DecodeString(unspent.RedeemScript)
+	if err != nil {
+		return log.WithError(err, "DecodeString failed")
+	}
+	return e.AddInput(pkScript, redeemScript)
+}
+
+// AddOutput 添加输出, 包括 OP_RETURN 输出
+func (e *SizeEstimator) AddOutput(pkScript []byte) *SizeEstimator {
+	e.outputs++
+	e.baseSize += 8 + wire.VarIntSerializeSize(uint64(len(pkScript))) + len(pkScript)
+	return e
+}
+
+// AddTxOuts 添加全部输出
+func (e *SizeEstimator) AddTxOuts(txOuts []*wire.TxOut) *SizeEstimator {
+	for _, out := range txOuts {
+		e.AddOutput(out.PkScript)
+	}
+	return e
+}
+
+// Weight 签名后交易的重量
+func (e *SizeEstimator) Weight() int {
+	baseSize := txVersionAndLockTimeSize +
+		wire.VarIntSerializeSize(uint64(e.inputs)) +
+		wire.VarIntSerializeSize(uint64(e.outputs)) +
+		e.baseSize
+	weight := baseSize * blockchain.WitnessScaleFactor
+	if e.hasWitness {
+		weight += segwitMarkerAndFlagWeight + e.witnessWeight
+	}
+	return weight
+}
+
+// VirtualSize 签名后交易的虚拟大小, 重量除以 4 向上取整
+func (e *SizeEstimator) VirtualSize() int {
+	return (e.Weight() + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+}
+
+func isMultisigScript(script []byte) bool {
+	ok, err := txscript.IsMultisigScript(script)
+	return err == nil && ok
+}
+
+// pushDataSize 推入 size 字节数据所需的操作码长度
+func pushDataSize(size int) int {
+	switch {
+	case size < txscript.OP_PUSHDATA1:
+		return 1
+	case size <= 0xff:
+		return 2
+	case size <= 0xffff:
+		return 3
+	}
+	return 5
+}
+
+// estimateVirtualSize 按输入脚本类型估算签名后的交易大小, P2PKH 输入按 compressed 指定的公钥格式估算
+// 无法识别的输入按压缩公钥 P2PKH 估算
+func estimateVirtualSize(prevScripts [][]byte, txOuts []*wire.TxOut, compressed bool) int {
+	estimator := NewSizeEstimator()
+	for _, pkScript := range prevScripts {
+		if txscript.IsPayToPubKeyHash(pkScript) {
+			estimator.AddP2PKHInput(compressed)
+			continue
+		}
+		if err := estimator.AddInput(pkScript, nil); err != nil {
+			estimator.AddP2PKHInput(true)
+		}
+	}
+	return estimator.AddTxOuts(txOuts).VirtualSize()
+}
+
+// EstimateVirtualSize 估算签名后的虚拟大小, 已包含找零输出, 需要在签名前调用
+func (t *Transaction) EstimateVirtualSize() int {
+	return estimateVirtualSize(t.PrevScripts, t.Tx.TxOut, true)
+}
@@ -0,0 +1,173 @@
+package btc
+
+import (
+	"encoding/hex"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+// actualVirtualSize 已签名交易的虚拟大小
+func actualVirtualSize(tx *wire.MsgTx) int {
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	return int((weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor)
+}
+
+// assertEstimate 估算值不小于实际大小, 每个 ECDSA 签名通常多估 1 字节, r 或 s 有前导零时最多多估 2 字节, 另有长度编码的误差
+func assertEstimate(t *testing.T, estimate int, tx *wire.MsgTx, ecdsaSigs int, msgAndArgs ...interface{}) {
+	actual := actualVirtualSize(tx)
+	assert.GreaterOrEqual(t, estimate, actual, msgAndArgs...)
+	assert.LessOrEqual(t, estimate-actual, 2*ecdsaSigs+2, msgAndArgs...)
+}
+
+func TestSizeEstimator_SingleSig(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	account, _ := NewAccountWithPrivateKey(testAccountKey, utils.BtcChainTestNet3)
+	to, _ := btcutil.DecodeAddress("2MzQfDPhMpCHpuGcKLwMtBNWJXpXismGLfi", params)
+	outputs := []TransferParam{{To: to, Amount: 20000}, NewDataParam([]byte("hdwallet"))}
+	token := NewToken(NewChain())
+
+	addrTypes := []AddressType{AddressTypeLegacy, AddressTypeNestedSegwit, AddressTypeNativeSegwit, AddressTypeTaproot}
+	var all []BtcUnspent
+	for _, addrType := range addrTypes {
+		address, _ := account.AddressOfType(addrType)
+		change, _ := btcutil.DecodeAddress(address, params)
+		unspents := newTestUnspents(t, address, 15000, 15000)
+		all = append(all, unspents...)
+
+		quote, err := token.EstimateTransactionSize(unspents, outputs, change, 1000, utils.BtcChainTestNet3)
+		assert.NoError(t, err)
+		tx, err := NewTransaction(unspents, outputs, change, 1000, params)
+		assert.NoError(t, err)
+		estimate := tx.EstimateVirtualSize()
+		assert.Equal(t, quote, estimate, addrType)
+		assert.NoError(t, tx.SignWithSecretsSource(account))
+		ecdsaSigs := len(tx.Tx.TxIn)
+		if addrType == AddressTypeTaproot {
+			ecdsaSigs = 0
+		}
+		assertEstimate(t, estimate, tx.Tx, ecdsaSigs, addrType)
+
+		// 单个输入的报价与签名后的交易一致
+		gasLimit, err := token.EstimateGasLimit(change, []TransferParam{{To: to, Amount: 5000}}, utils.BtcChainTestNet3)
+		assert.NoError(t, err)
+		tx, _ = NewTransaction(unspents[:1], []TransferParam{{To: to, Amount: 5000}}, change, 1000, params)
+		assert.NoError(t, tx.SignWithSecretsSource(account))
+		assertEstimate(t, gasLimit, tx.Tx, ecdsaSigs/2, addrType)
+	}
+
+	// 混合输入, 不需要找零时不计算找零输出
+	change, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+	tx, err := NewTransaction(all, []TransferParam{{To: to, Amount: 119000}}, change, 1000, params)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, tx.ChangeIndex)
+	quote, err := token.EstimateTransactionSize(all, []TransferParam{{To: to, Amount: 119000}}, change, 1000, utils.BtcChainTestNet3)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.SignWithSecretsSource(account))
+	assertEstimate(t, quote, tx.Tx, 6)
+}
+
+func TestSizeEstimator_Multisig(t *testing.T) {
+	params := &chaincfg.TestNet3Params
+	var keys []*btcec.PrivateKey
+	var pubKeys []*btcutil.AddressPubKey
+	for i := 0; i < 3; i++ {
+		// 固定私钥, 签名长度确定
+		key, _ := btcec.PrivKeyFromBytes(chainhash.HashB([]byte{byte(i)}))
+		pubKey, _ := btcutil.NewAddressPubKey(key.PubKey().SerializeCompressed(), params)
+		keys = append(keys, key)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	multisig, _ := txscript.MultiSigScript(pubKeys, 2)
+	p2sh, _ := btcutil.NewAddressScriptHash(multisig, params)
+	p2shScript, _ := txscript.PayToAddrScript(p2sh)
+	witnessHash := chainhash.HashB(multisig)
+	p2wsh, _ := btcutil.NewAddressWitnessScriptHash(witnessHash, params)
+	p2wshScript, _ := txscript.PayToAddrScript(p2wsh)
+	nestedProgram := append([]byte{txscript.OP_0, txscript.OP_DATA_32}, witnessHash...)
+	nested, _ := btcutil.NewAddressScriptHash(nestedProgram, params)
+	nestedScript, _ := txscript.PayToAddrScript(nested)
+	dataScript, _ := txscript.NullDataScript([]byte("hdwallet"))
+
+	tests := []struct {
+		name         string
+		pkScript     []byte
+		multisigType MultisigType
+	}{
+		{"bare", multisig, MultisigBare},
+		{"p2sh", p2shScript, MultisigP2SH},
+		{"p2wsh", p2wshScript, MultisigP2WSH},
+		{"p2sh-p2wsh", nestedScript, MultisigNestedP2WSH},
+	}
+	for _, tt := range tests {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.HashH([]byte(tt.name))}, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(90000, p2wshScript))
+		tx.AddTxOut(wire.NewTxOut(0, dataScript))
+		prevScripts := [][]byte{tt.pkScript}
+		fetcher, _ := txauthor.TXPrevOutFetcher(tx, prevScripts, []btcutil.Amount{100000})
+		hashes := txscript.NewTxSigHashes(tx, fetcher)
+
+		var sigs [][]byte
+		for _, key := range keys[:2] {
+			var sig []byte
+			var err error
+			if tt.multisigType == MultisigP2WSH || tt.multisigType == MultisigNestedP2WSH {
+				sig, err = txscript.RawTxInWitnessSignature(tx, hashes, 0, 100000, multisig, txscript.SigHashAll, key)
+			} else {
+				sig, err = txscript.RawTxInSignature(tx, 0, multisig, txscript.SigHashAll, key)
+			}
+			assert.NoError(t, err)
+			sigs = append(sigs, sig)
+		}
+		builder := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(sigs[0]).AddData(sigs[1])
+		switch tt.multisigType {
+		case MultisigBare:
+			tx.TxIn[0].SignatureScript, _ = builder.Script()
+		case MultisigP2SH:
+			tx.TxIn[0].SignatureScript, _ = builder.AddData(multisig).Script()
+		case MultisigNestedP2WSH:
+			tx.TxIn[0].SignatureScript, _ = txscript.NewScriptBuilder().AddData(nestedProgram).Script()
+			fallthrough
+		case MultisigP2WSH:
+			tx.TxIn[0].Witness = wire.TxWitness{nil, sigs[0], sigs[1], multisig}
+		}
+		assert.NoError(t, validateMsgTx(tx, prevScripts, []btcutil.Amount{100000}), tt.name)
+
+		estimate := NewSizeEstimator().AddMultisigInput(2, 3, tt.multisigType).AddTxOuts(tx.TxOut).VirtualSize()
+		assertEstimate(t, estimate, tx, 2, tt.name)
+
+		// 由输出脚本和兑换脚本识别多签类型, P2SH-P2WSH 需要显式指定
+		if tt.multisigType != MultisigNestedP2WSH {
+			estimator := NewSizeEstimator()
+			err := estimator.AddUnspent(BtcUnspent{ScriptPubKey: hex.EncodeToString(tt.pkScript), RedeemScript: hex.EncodeToString(multisig)})
+			assert.NoError(t, err)
+			assert.Equal(t, estimate, estimator.AddTxOuts(tx.TxOut).VirtualSize(), tt.name)
+
+			// 创建交易时按相同的估算支付手续费
+			unspents := []BtcUnspent{{TxID: chainhash.HashH([]byte(tt.name)).String(), ScriptPubKey: hex.EncodeToString(tt.pkScript),
+				RedeemScript: hex.EncodeToString(multisig), Amount: 0.001, Value: 100000}}
+			change, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", params)
+			outputs := []TransferParam{{To: p2wsh, Amount: 50000}}
+			quote, err := NewToken(NewChain()).EstimateTransactionSize(unspents, outputs, change, 2000, utils.BtcChainTestNet3)
+			assert.NoError(t, err)
+			built, err := NewTransaction(unspents, outputs, change, 2000, params)
+			assert.NoError(t, err)
+			assert.Equal(t, txrules.FeeForSerializeSize(2000, quote), built.Fee(), tt.name)
+		}
+	}
+
+	err := NewSizeEstimator().AddInput(p2wshScript, nil)
+	assert.Equal(t, utils.ErrNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+	err = NewSizeEstimator().AddInput([]byte{txscript.OP_TRUE}, nil)
+	assert.Equal(t, utils.ErrNotSupported.ErrCode, err.(*utils.Error).ErrCode)
+}
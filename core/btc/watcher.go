@@ -0,0 +1,422 @@
+package btc
+
+import (
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"sync"
+	"time"
+)
+
+// WatchEventType 监控事件类型
+type WatchEventType int
+
+const (
+	WatchEventMempool      WatchEventType = iota + 1 // 交易出现在内存池
+	WatchEventConfirmation                           // 确认数变化, 重组时可能减少
+	WatchEventConfirmed                              // 确认数达到目标, 之后不再监控
+	WatchEventReplaced                               // 输入被其它交易花费(RBF)
+	WatchEventDropped                                // 交易从内存池消失且输入未被花费
+)
+
+const (
+	// DefaultWatchConfirmations 默认的目标确认数
+	DefaultWatchConfirmations = 6
+	// DefaultWatchDropAfter 连续多少次查询不到交易后判定为丢弃
+	DefaultWatchDropAfter = 3
+)
+
+// WatchEvent 监控事件
+type WatchEvent struct {
+	Type          WatchEventType
+	TxId          string
+	Address       string // 通过地址发现的交易为该地址, 否则为空
+	Confirmations int64
+	BlockHeight   int64
+	BlockHash     string
+	ReplacedBy    string // WatchEventReplaced 时为替换的交易
+}
+
+// TxStatus 数据源返回的交易状态
+type TxStatus struct {
+	Found       bool // 交易在内存池或区块中
+	Confirmed   bool
+	BlockHeight int64
+	BlockHash   string
+	Inputs      []wire.OutPoint // 交易的输入, 用于判断是否被替换
+}
+
+// WatchSource 监控器的数据源, 如 Esplora
+type WatchSource interface {
+	// TipHeight 最新区块高度
+	TipHeight() (int64, error)
+	// GetTxStatus 查询交易状态, 交易不存在时 Found 为 false
+	GetTxStatus(txId string) (*TxStatus, error)
+	// GetOutspend 查询花费该输出的交易, 未花费时返回空
+	GetOutspend(outpoint wire.OutPoint) (string, error)
+	// AddressTxIds 地址最近的交易, 包含内存池中的交易
+	AddressTxIds(address btcutil.Address) ([]string, error)
+}
+
+// BlockNotifier 新区块通知, 如 bitcoind 的 ZMQ hashblock 或 Electrum 的区块头订阅
+type BlockNotifier interface {
+	// BlockNotifications 返回通知的通道, 通道中为新区块的高度
+	BlockNotifications() (<-chan int64, error)
+}
+
+type watchedTx struct {
+	address       string
+	seen          bool
+	misses        int
+	confirmations int64
+	blockHash     string
+	inputs        []wire.OutPoint
+}
+
+// Watcher 跟踪交易和地址的内存池及确认状态, 通过回调通知事件
+type Watcher struct {
+	source        WatchSource
+	confirmations int64
+	dropAfter     int
+	handler       func(event *WatchEvent)
+
+	pollMu    sync.Mutex // 同一时间只执行一次 Poll
+	mu        sync.Mutex
+	txs       map[string]*watchedTx
+	addresses map[string]*watchedAddress
+	pending   []*WatchEvent // 查询过程中产生的事件, 解锁后再回调
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+type watchedAddress struct {
+	address btcutil.Address
+	known   map[string]struct{} // 已知的交易, 为 nil 时表示还没有查询过
+}
+
+// NewWatcher confirmations 为目标确认数, 小于 1 时使用 DefaultWatchConfirmations
+func NewWatcher(source WatchSource, confirmations int64, handler func(event *WatchEvent)) *Watcher {
+	if confirmations < 1 {
+		confirmations = DefaultWatchConfirmations
+	}
+	return &Watcher{
+		source:        source,
+		confirmations: confirmations,
+		dropAfter:     DefaultWatchDropAfter,
+		handler:       handler,
+		txs:           make(map[string]*watchedTx),
+		addresses:     make(map[string]*watchedAddress),
+	}
+}
+
+// SetDropAfter 设置连续查询不到多少次后判定交易被丢弃
+func (w *Watcher) SetDropAfter(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if n > 0 {
+		w.dropAfter = n
+	}
+}
+
+// WatchTx 跟踪交易, 如 Token.Transfer 返回的哈希
+func (w *Watcher) WatchTx(txId string) {
+	w.watchTx(txId, "", nil)
+}
+
+// WatchTransaction 跟踪已广播的交易, 输入已知时不需要从数据源查询
+func (w *Watcher) WatchTransaction(tx *Transaction) {
+	inputs := make([]wire.OutPoint, 0, len(tx.Tx.TxIn))
+	for _, in := range tx.Tx.TxIn {
+		inputs = append(inputs, in.PreviousOutPoint)
+	}
+	w.watchTx(tx.Tx.TxHash().String(), "", inputs)
+}
+
+func (w *Watcher) watchTx(txId, address string, inputs []wire.OutPoint) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.txs[txId]; !ok {
+		w.txs[txId] = &watchedTx{address: address, inputs: inputs}
+	}
+}
+
+// WatchAddress 跟踪地址, 之后出现的新交易会自动加入跟踪, 已有的历史交易不产生事件
+func (w *Watcher) WatchAddress(address btcutil.Address) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := address.EncodeAddress()
+	if _, ok := w.addresses[key]; !ok {
+		w.addresses[key] = &watchedAddress{address: address}
+	}
+}
+
+// Unwatch 停止跟踪交易或地址
+func (w *Watcher) Unwatch(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.txs, id)
+	delete(w.addresses, id)
+}
+
+// Watching 正在跟踪的交易数量
+func (w *Watcher) Watching() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.txs)
+}
+
+// Poll 查询一次全部跟踪的地址和交易, 返回遇到的第一个错误, 出错的交易在下次查询时重试
+// 查询数据源时不持有锁, 回调在查询结束后依次执行, 回调中可以继续调用 WatchTx 等方法
+func (w *Watcher) Poll() error {
+	w.pollMu.Lock()
+	defer w.pollMu.Unlock()
+
+	var firstErr error
+	setErr := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := w.pollAddresses(); err != nil {
+		setErr(err)
+	}
+	if err := w.pollTxs(); err != nil {
+		setErr(err)
+	}
+
+	w.mu.Lock()
+	events := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	if w.handler != nil {
+		for _, event := range events {
+			w.handler(event)
+		}
+	}
+	return firstErr
+}
+
+// pollAddresses 查询跟踪的地址, 新出现的交易加入跟踪
+func (w *Watcher) pollAddresses() error {
+	w.mu.Lock()
+	addresses := make(map[string]*watchedAddress, len(w.addresses))
+	for key, addr := range w.addresses {
+		addresses[key] = addr
+	}
+	w.mu.Unlock()
+
+	var firstErr error
+	results := make(map[string][]string, len(addresses))
+	for key, addr := range addresses {
+		txIds, err := w.source.AddressTxIds(addr.address)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		results[key] = txIds
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key, txIds := range results {
+		// 查询期间地址可能已经取消跟踪
+		addr, ok := w.addresses[key]
+		if !ok || addr != addresses[key] {
+			continue
+		}
+		first := addr.known == nil
+		if first {
+			addr.known = make(map[string]struct{}, len(txIds))
+		}
+		for _, txId := range txIds {
+			if _, ok := addr.known[txId]; ok {
+				continue
+			}
+			addr.known[txId] = struct{}{}
+			if _, ok := w.txs[txId]; !first && !ok {
+				w.txs[txId] = &watchedTx{address: key}
+			}
+		}
+	}
+	return firstErr
+}
+
+// txPollResult 一次查询的交易状态
+type txPollResult struct {
+	status  *TxStatus
+	spender string // 交易查询不到时花费其输入的其它交易
+}
+
+// pollTxs 查询跟踪的交易, 根据结果产生事件
+func (w *Watcher) pollTxs() error {
+	w.mu.Lock()
+	inputs := make(map[string][]wire.OutPoint, len(w.txs))
+	for txId, tx := range w.txs {
+		inputs[txId] = tx.inputs
+	}
+	w.mu.Unlock()
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	tipHeight, err := w.source.TipHeight()
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	results := make(map[string]*txPollResult, len(inputs))
+	for txId, txInputs := range inputs {
+		result, err := w.queryTx(txId, txInputs)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		results[txId] = result
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for txId, result := range results {
+		// 查询期间交易可能已经取消跟踪
+		if tx, ok := w.txs[txId]; ok {
+			w.applyTx(txId, tx, result, tipHeight)
+		}
+	}
+	return firstErr
+}
+
+// queryTx 查询交易状态, 交易查询不到时查询其输入是否被其它交易花费
+func (w *Watcher) queryTx(txId string, inputs []wire.OutPoint) (*txPollResult, error) {
+	status, err := w.source.GetTxStatus(txId)
+	if err != nil {
+		return nil, err
+	}
+	result := &txPollResult{status: status}
+	if status.Found {
+		return result, nil
+	}
+	for _, outpoint := range inputs {
+		spender, err := w.source.GetOutspend(outpoint)
+		if err != nil {
+			return nil, err
+		}
+		if spender != "" && spender != txId {
+			result.spender = spender
+			break
+		}
+	}
+	return result, nil
+}
+
+func (w *Watcher) applyTx(txId string, tx *watchedTx, result *txPollResult, tipHeight int64) {
+	status := result.status
+	if !status.Found {
+		if tx.confirmations > 0 {
+			tx.confirmations = 0
+			tx.blockHash = ""
+			w.emit(&WatchEvent{Type: WatchEventConfirmation, TxId: txId, Address: tx.address})
+		}
+		w.applyMissing(txId, tx, result.spender)
+		return
+	}
+	tx.misses = 0
+	if len(status.Inputs) > 0 {
+		tx.inputs = status.Inputs
+	}
+	if !tx.seen {
+		tx.seen = true
+		// 第一次查询到时已经打包的交易没有经过内存池
+		if !status.Confirmed {
+			w.emit(&WatchEvent{Type: WatchEventMempool, TxId: txId, Address: tx.address})
+		}
+	}
+
+	confirmations := int64(0)
+	if status.Confirmed && tipHeight >= status.BlockHeight {
+		confirmations = tipHeight - status.BlockHeight + 1
+	}
+	// 重组后交易可能回到内存池或被打包到其它区块, 确认数随之回退
+	reorged := tx.blockHash != "" && tx.blockHash != status.BlockHash
+	if confirmations != tx.confirmations || reorged {
+		tx.confirmations = confirmations
+		tx.blockHash = status.BlockHash
+		w.emit(&WatchEvent{Type: WatchEventConfirmation, TxId: txId, Address: tx.address,
+			Confirmations: confirmations, BlockHeight: status.BlockHeight, BlockHash: status.BlockHash})
+	}
+	if confirmations >= w.confirmations {
+		delete(w.txs, txId)
+		w.emit(&WatchEvent{Type: WatchEventConfirmed, TxId: txId, Address: tx.address,
+			Confirmations: confirmations, BlockHeight: status.BlockHeight, BlockHash: status.BlockHash})
+	}
+}
+
+// applyMissing 交易查询不到时, 输入被其它交易花费为替换, 连续多次查询不到为丢弃
+func (w *Watcher) applyMissing(txId string, tx *watchedTx, spender string) {
+	if spender != "" {
+		delete(w.txs, txId)
+		w.emit(&WatchEvent{Type: WatchEventReplaced, TxId: txId, Address: tx.address, ReplacedBy: spender})
+		return
+	}
+	if tx.misses++; tx.misses >= w.dropAfter {
+		delete(w.txs, txId)
+		w.emit(&WatchEvent{Type: WatchEventDropped, TxId: txId, Address: tx.address})
+	}
+}
+
+func (w *Watcher) emit(event *WatchEvent) {
+	w.pending = append(w.pending, event)
+}
+
+// Start 按 interval 定期查询, notifier 不为空时收到新区块通知也会立即查询
+func (w *Watcher) Start(interval time.Duration, notifier BlockNotifier) error {
+	var blocks <-chan int64
+	if notifier != nil {
+		var err error
+		if blocks, err = notifier.BlockNotifications(); err != nil {
+			return err
+		}
+	}
+	w.mu.Lock()
+	if w.stop != nil {
+		w.mu.Unlock()
+		return nil
+	}
+	w.stop = make(chan struct{})
+	stop := w.stop
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			_ = w.Poll()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			case _, ok := <-blocks:
+				if !ok {
+					blocks = nil
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止定期查询
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	stop := w.stop
+	w.stop = nil
+	w.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		w.wg.Wait()
+	}
+}
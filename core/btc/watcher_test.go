@@ -0,0 +1,278 @@
+package btc
+
+import (
+	"errors"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// fakeWatchSource 内存中的 WatchSource
+type fakeWatchSource struct {
+	tip       int64
+	txs       map[string]*TxStatus
+	outspends map[wire.OutPoint]string
+	history   map[string][]string
+	err       error
+}
+
+func newFakeWatchSource() *fakeWatchSource {
+	return &fakeWatchSource{
+		tip:       100,
+		txs:       make(map[string]*TxStatus),
+		outspends: make(map[wire.OutPoint]string),
+		history:   make(map[string][]string),
+	}
+}
+
+func (f *fakeWatchSource) TipHeight() (int64, error) {
+	return f.tip, f.err
+}
+
+func (f *fakeWatchSource) GetTxStatus(txId string) (*TxStatus, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if status, ok := f.txs[txId]; ok {
+		return status, nil
+	}
+	return &TxStatus{}, nil
+}
+
+func (f *fakeWatchSource) GetOutspend(outpoint wire.OutPoint) (string, error) {
+	return f.outspends[outpoint], f.err
+}
+
+func (f *fakeWatchSource) AddressTxIds(address btcutil.Address) ([]string, error) {
+	return f.history[address.EncodeAddress()], f.err
+}
+
+type watchRecorder struct {
+	events []*WatchEvent
+}
+
+func (r *watchRecorder) handle(event *WatchEvent) {
+	r.events = append(r.events, event)
+}
+
+func (r *watchRecorder) take() []*WatchEvent {
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func testTxId(s string) string {
+	return chainhash.HashH([]byte(s)).String()
+}
+
+func TestWatcher_Confirmations(t *testing.T) {
+	source := newFakeWatchSource()
+	recorder := &watchRecorder{}
+	w := NewWatcher(source, 3, recorder.handle)
+	txId := testTxId("tx")
+	w.WatchTx(txId)
+
+	// 还未被节点接收
+	assert.NoError(t, w.Poll())
+	assert.Empty(t, recorder.take())
+
+	source.txs[txId] = &TxStatus{Found: true}
+	assert.NoError(t, w.Poll())
+	events := recorder.take()
+	assert.Len(t, events, 1)
+	assert.Equal(t, WatchEventMempool, events[0].Type)
+
+	source.txs[txId] = &TxStatus{Found: true, Confirmed: true, BlockHeight: 101, BlockHash: "a"}
+	source.tip = 101
+	assert.NoError(t, w.Poll())
+	events = recorder.take()
+	assert.Len(t, events, 1)
+	assert.Equal(t, WatchEventConfirmation, events[0].Type)
+	assert.Equal(t, int64(1), events[0].Confirmations)
+
+	// 重组后交易回到内存池, 确认数回退
+	source.txs[txId] = &TxStatus{Found: true}
+	source.tip = 101
+	assert.NoError(t, w.Poll())
+	events = recorder.take()
+	assert.Len(t, events, 1)
+	assert.Equal(t, WatchEventConfirmation, events[0].Type)
+	assert.Equal(t, int64(0), events[0].Confirmations)
+
+	// 被打包到其它区块
+	source.txs[txId] = &TxStatus{Found: true, Confirmed: true, BlockHeight: 102, BlockHash: "b"}
+	source.tip = 104
+	assert.NoError(t, w.Poll())
+	events = recorder.take()
+	assert.Len(t, events, 2)
+	assert.Equal(t, WatchEventConfirmation, events[0].Type)
+	assert.Equal(t, int64(3), events[0].Confirmations)
+	assert.Equal(t, WatchEventConfirmed, events[1].Type)
+	assert.Equal(t, "b", events[1].BlockHash)
+	assert.Equal(t, 0, w.Watching())
+}
+
+func TestWatcher_ReorgSameHeight(t *testing.T) {
+	source := newFakeWatchSource()
+	recorder := &watchRecorder{}
+	w := NewWatcher(source, 6, recorder.handle)
+	txId := testTxId("tx")
+	w.WatchTx(txId)
+
+	// 第一次查询到时已经打包, 不产生内存池事件
+	source.txs[txId] = &TxStatus{Found: true, Confirmed: true, BlockHeight: 99, BlockHash: "a"}
+	assert.NoError(t, w.Poll())
+	events := recorder.take()
+	assert.Len(t, events, 1)
+	assert.Equal(t, WatchEventConfirmation, events[0].Type)
+
+	source.txs[txId] = &TxStatus{Found: true, Confirmed: true, BlockHeight: 99, BlockHash: "b"}
+	assert.NoError(t, w.Poll())
+	events = recorder.take()
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(2), events[0].Confirmations)
+	assert.Equal(t, "b", events[0].BlockHash)
+}
+
+func TestWatcher_ReplacedAndDropped(t *testing.T) {
+	source := newFakeWatchSource()
+	recorder := &watchRecorder{}
+	w := NewWatcher(source, 1, recorder.handle)
+	w.SetDropAfter(2)
+
+	prevHash := chainhash.HashH([]byte("prev"))
+	outpoint := wire.OutPoint{Hash: prevHash, Index: 1}
+	replaced, dropped := testTxId("replaced"), testTxId("dropped")
+	source.txs[replaced] = &TxStatus{Found: true, Inputs: []wire.OutPoint{outpoint}}
+	source.txs[dropped] = &TxStatus{Found: true, Inputs: []wire.OutPoint{{Hash: prevHash, Index: 2}}}
+	w.WatchTx(replaced)
+	w.WatchTx(dropped)
+	assert.NoError(t, w.Poll())
+	assert.Len(t, recorder.take(), 2)
+
+	delete(source.txs, replaced)
+	delete(source.txs, dropped)
+	source.outspends[outpoint] = testTxId("bumped")
+	assert.NoError(t, w.Poll())
+	events := recorder.take()
+	assert.Len(t, events, 1)
+	assert.Equal(t, WatchEventReplaced, events[0].Type)
+	assert.Equal(t, replaced, events[0].TxId)
+	assert.Equal(t, testTxId("bumped"), events[0].ReplacedBy)
+
+	assert.NoError(t, w.Poll())
+	events = recorder.take()
+	assert.Len(t, events, 1)
+	assert.Equal(t, WatchEventDropped, events[0].Type)
+	assert.Equal(t, dropped, events[0].TxId)
+	assert.Equal(t, 0, w.Watching())
+}
+
+func TestWatcher_Address(t *testing.T) {
+	source := newFakeWatchSource()
+	recorder := &watchRecorder{}
+	w := NewWatcher(source, 1, recorder.handle)
+	address, _ := btcutil.DecodeAddress("tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", &chaincfg.TestNet3Params)
+	old, incoming := testTxId("old"), testTxId("incoming")
+	source.history[address.EncodeAddress()] = []string{old}
+	source.txs[old] = &TxStatus{Found: true, Confirmed: true, BlockHeight: 10}
+	w.WatchAddress(address)
+
+	// 已有的历史交易不产生事件
+	assert.NoError(t, w.Poll())
+	assert.Empty(t, recorder.take())
+
+	source.history[address.EncodeAddress()] = []string{incoming, old}
+	source.txs[incoming] = &TxStatus{Found: true}
+	assert.NoError(t, w.Poll())
+	events := recorder.take()
+	assert.Len(t, events, 1)
+	assert.Equal(t, WatchEventMempool, events[0].Type)
+	assert.Equal(t, incoming, events[0].TxId)
+	assert.Equal(t, address.EncodeAddress(), events[0].Address)
+}
+
+func TestWatcher_Error(t *testing.T) {
+	source := newFakeWatchSource()
+	w := NewWatcher(source, 1, nil)
+	w.WatchTx(testTxId("tx"))
+	source.err = errors.New("backend down")
+	assert.Error(t, w.Poll())
+	assert.Equal(t, 1, w.Watching())
+}
+
+// reentrantWatchSource 查询时调用监控器的方法, 验证查询期间不持有锁
+type reentrantWatchSource struct {
+	*fakeWatchSource
+	w *Watcher
+}
+
+func (r *reentrantWatchSource) GetTxStatus(txId string) (*TxStatus, error) {
+	r.w.WatchTx(testTxId("added"))
+	r.w.Unwatch(txId)
+	return r.fakeWatchSource.GetTxStatus(txId)
+}
+
+func TestWatcher_PollUnlocked(t *testing.T) {
+	source := &reentrantWatchSource{fakeWatchSource: newFakeWatchSource()}
+	recorder := &watchRecorder{}
+	w := NewWatcher(source, 1, recorder.handle)
+	source.w = w
+	txId := testTxId("tx")
+	source.txs[txId] = &TxStatus{Found: true}
+	w.WatchTx(txId)
+
+	done := make(chan error, 1)
+	go func() { done <- w.Poll() }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Poll holds the lock while querying")
+	}
+	// 查询期间取消跟踪的交易不产生事件
+	assert.Empty(t, recorder.take())
+	assert.Equal(t, 1, w.Watching())
+}
+
+type fakeBlockNotifier chan int64
+
+func (f fakeBlockNotifier) BlockNotifications() (<-chan int64, error) {
+	return f, nil
+}
+
+func TestWatcher_StartWithNotifier(t *testing.T) {
+	source := newFakeWatchSource()
+	confirmed := make(chan *WatchEvent, 1)
+	w := NewWatcher(source, 1, func(event *WatchEvent) {
+		if event.Type == WatchEventConfirmed {
+			confirmed <- event
+		}
+	})
+	txId := testTxId("tx")
+	source.txs[txId] = &TxStatus{Found: true}
+	w.WatchTx(txId)
+
+	blocks := make(fakeBlockNotifier, 1)
+	assert.NoError(t, w.Start(time.Hour, blocks))
+	defer w.Stop()
+
+	// Start 后立即查询一次, 之后只在新区块时查询
+	time.Sleep(50 * time.Millisecond)
+	w.pollMu.Lock()
+	source.txs[txId] = &TxStatus{Found: true, Confirmed: true, BlockHeight: 101, BlockHash: "a"}
+	source.tip = 101
+	w.pollMu.Unlock()
+	blocks <- 101
+
+	select {
+	case event := <-confirmed:
+		assert.Equal(t, txId, event.TxId)
+	case <-time.After(5 * time.Second):
+		t.Fatal("confirmed event not received")
+	}
+}
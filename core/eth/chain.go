@@ -5,11 +5,15 @@ import (
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
 	"math/big"
+	"sync"
 )
 
 type Chain struct {
 	client  *Client
 	chainId *big.Int
+
+	mu     sync.Mutex
+	nonces *NonceManager
 }
 
 func NewChain() *Chain {
@@ -49,6 +53,32 @@ func (c *Chain) CreateRemoteClientWithTimeout(rpcUrl string, timeout int64) (*Ch
 	return c, nil
 }
 
+// SetNonceManager 设置链上交易使用的 NonceManager, 需要持久化 nonce 时使用
+func (c *Chain) SetNonceManager(manager *NonceManager) *Chain {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nonces = manager
+	return c
+}
+
+// NonceManager 链上交易使用的 NonceManager, 未设置时创建只保存在内存中的 NonceManager
+func (c *Chain) NonceManager() (*NonceManager, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.nonces != nil {
+		return c.nonces, nil
+	}
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	c.nonces, err = NewNonceManager(client.RPCClient(), c.chainId, "")
+	if err != nil {
+		return nil, err
+	}
+	return c.nonces, nil
+}
+
 func (c *Chain) MainToken() base.Token {
 	return &Token{chain: c}
 }
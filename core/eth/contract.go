@@ -8,6 +8,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"golang.org/x/net/context"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
 	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
 	"math/big"
 	"strings"
@@ -89,9 +90,29 @@ type TransactBaseParam struct {
 	GasFeeCap *big.Int
 	GasTipCap *big.Int
 	BaseFee   *big.Int
+	Speed     FeeSpeed // 未指定燃料费用时按对应的档位计算, backend 需要实现 FeeSource
+}
+
+// SetFeeTier 使用 EstimateFees 返回的档位作为燃料费用
+func (_self *TransactBaseParam) SetFeeTier(tier FeeTier) {
+	_self.GasPrice, _self.GasTipCap, _self.GasFeeCap = tier.GasPrice, tier.GasTipCap, tier.GasFeeCap
 }
 
 func (_self *TransactBaseParam) EnsureGasPrice(backend bind.ContractBackend) error {
+	if _self.Speed != FeeDefault && _self.GasPrice == nil && _self.GasFeeCap == nil && _self.GasTipCap == nil {
+		source, ok := backend.(FeeSource)
+		if !ok {
+			return log.WithError(utils.ErrNotSupported, "backend does not support eth_feeHistory")
+		}
+		estimate, err := EstimateFees(context.Background(), source)
+		if err != nil {
+			return log.WithError(err, "EstimateFees failed")
+		}
+		_self.BaseFee = estimate.BaseFee
+		_self.SetFeeTier(estimate.Tier(_self.Speed))
+		return nil
+	}
+
 	head, err := backend.HeaderByNumber(context.Background(), nil)
 	if err != nil {
 		return log.WithError(err, "HeaderByNumber failed")
@@ -98,6 +98,7 @@ func (e *Erc1155Token) SafeTransferFrom(caller Account, from common.Address, to
 
 	client, err := e.chain.Client()
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 	//预估gas费
@@ -105,6 +106,7 @@ func (e *Erc1155Token) SafeTransferFrom(caller Account, from common.Address, to
 	//得到input
 	input, err := erc1155.abi.Pack("safeTransferFrom", from, to, tokenId, amount, data)
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 	//预估gas费
@@ -114,6 +116,7 @@ func (e *Erc1155Token) SafeTransferFrom(caller Account, from common.Address, to
 	}
 	transfer, err := erc1155.SafeTransferFrom(tx.ToTransactOpts(caller.privateKeyECDSA), from, to, tokenId, amount, data)
 	if err != nil {
+		tx.SendResult(err)
 		return nil, log.WithError(err)
 	}
 
@@ -130,12 +133,14 @@ func (e *Erc1155Token) SafeBatchTransferFrom(caller Account, from common.Address
 
 	client, err := e.chain.Client()
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 	erc1155 := NewErc1155Contract(e.contractAddress, client.RPCClient())
 	//得到input
 	input, err := erc1155.abi.Pack("safeBatchTransferFrom", from, to, tokenIds, amounts, data)
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 	//预估gas费
@@ -145,6 +150,7 @@ func (e *Erc1155Token) SafeBatchTransferFrom(caller Account, from common.Address
 	}
 	transfer, err := erc1155.SafeBatchTransferFrom(tx.ToTransactOpts(caller.privateKeyECDSA), from, to, tokenIds, amounts, data)
 	if err != nil {
+		tx.SendResult(err)
 		return nil, log.WithError(err)
 	}
 
@@ -162,12 +168,14 @@ func (e *Erc1155Token) SetApprovalForAll(caller Account, operator common.Address
 
 	client, err := e.chain.Client()
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 	erc1155 := NewErc1155Contract(e.contractAddress, client.RPCClient())
 	//得到input
 	input, err := erc1155.abi.Pack("setApprovalForAll", operator, approved)
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 	//预估gas费
@@ -177,6 +185,7 @@ func (e *Erc1155Token) SetApprovalForAll(caller Account, operator common.Address
 	}
 	all, err := erc1155.SetApprovalForAll(tx.ToTransactOpts(caller.privateKeyECDSA), operator, approved)
 	if err != nil {
+		tx.SendResult(err)
 		return nil, log.WithError(err)
 	}
 
@@ -100,7 +100,7 @@ func (e *Erc20Token) BalanceOfAddress(address string) (*base.Balance, error) {
 func (e *Erc20Token) EstimateGasLimit(from, to common.Address, amount *big.Int, method string) (uint64, error) {
 
 	tx := NewTransaction(from, to, nil, e.chain)
-	err := tx.BuildTransfer()
+	err := tx.ensureGasPrice()
 	if err != nil {
 		return 0, log.WithError(err)
 	}
@@ -153,11 +153,13 @@ func (e *Erc20Token) Transfer(from *Account, to common.Address, value *big.Int)
 	}
 	client, err := e.chain.Client()
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 	erc20 := NewErc20Contract(e.contractAddress, client.RPCClient())
 	transfer, err := erc20.Transfer(tx.ToTransactOpts(from.privateKeyECDSA), to, value)
 	if err != nil {
+		tx.SendResult(err)
 		return nil, log.WithError(err)
 	}
 
@@ -179,13 +181,20 @@ func (e *Erc20Token) Approve(from *Account, spender common.Address, tokens *big.
 
 	client, err := e.chain.Client()
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 	erc20 := NewErc20Contract(e.contractAddress, client.RPCClient())
 
 	tx.GasLimit, err = e.estimateGasLimit(from.Address(), spender, tx.GasPrice, tokens, "approve")
 	if err != nil {
+		tx.ReleaseNonce()
+		return nil, log.WithError(err)
+	}
+	approve, err := erc20.Approve(tx.ToTransactOpts(from.privateKeyECDSA), spender, tokens)
+	if err != nil {
+		tx.SendResult(err)
 		return nil, log.WithError(err)
 	}
-	return erc20.Approve(tx.ToTransactOpts(from.privateKeyECDSA), spender, tokens)
+	return approve, nil
 }
@@ -87,11 +87,13 @@ func (e *Erc721Token) Transfer(from Account, to common.Address, value *big.Int)
 
 	client, err := e.chain.Client()
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 	transfer, err := NewErc721Contract(e.contractAddress, client.RPCClient()).
 		TransferFrom(tx.ToTransactOpts(from.privateKeyECDSA), from.Address(), to, value)
 	if err != nil {
+		tx.SendResult(err)
 		return nil, log.WithError(err)
 	}
 	return transfer, nil
@@ -99,7 +101,7 @@ func (e *Erc721Token) Transfer(from Account, to common.Address, value *big.Int)
 
 func (e *Erc721Token) EstimateGasLimit(from, to common.Address, amount *big.Int, method string) (uint64, error) {
 	tx := NewTransaction(from, to, nil, e.chain)
-	err := tx.BuildTransfer()
+	err := tx.ensureGasPrice()
 	if err != nil {
 		return 0, log.WithError(err)
 	}
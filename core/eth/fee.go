@@ -0,0 +1,201 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// FeeSpeed 交易的紧急程度
+type FeeSpeed int
+
+const (
+	FeeDefault FeeSpeed = iota // 节点建议的小费, 最高费用为 2 倍基础费用 + 小费
+	FeeSlow
+	FeeNormal
+	FeeFast
+)
+
+const (
+	feeHistoryBlocks = 20 // 统计最近的区块数
+)
+
+// feeTierParams 各档位的参数, 等待区块数为经验值
+var feeTierParams = map[FeeSpeed]struct {
+	percentile float64 // 小费取区块内交易小费的百分位
+	baseFeeMul int64   // 最高费用中基础费用的倍数, 以 1/4 为单位
+	gasPrice   int64   // legacy 链上建议燃料价格的百分比
+	blocks     int64   // 预计等待的区块数
+}{
+	FeeSlow:   {percentile: 10, baseFeeMul: 5, gasPrice: 100, blocks: 10},
+	FeeNormal: {percentile: 50, baseFeeMul: 8, gasPrice: 110, blocks: 3},
+	FeeFast:   {percentile: 90, baseFeeMul: 8, gasPrice: 125, blocks: 1},
+}
+
+// FeeSource 查询燃料费用, ethclient.Client 实现了该接口
+type FeeSource interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+}
+
+// FeeTier 一个档位的燃料费用, legacy 链只有 GasPrice
+type FeeTier struct {
+	GasTipCap *big.Int      // 小费
+	GasFeeCap *big.Int      // 最高费用
+	GasPrice  *big.Int      // legacy 链的燃料价格
+	Wait      time.Duration // 预计等待时间
+}
+
+// FeeEstimate 慢、中、快三档燃料费用
+type FeeEstimate struct {
+	BaseFee *big.Int // 下一个区块的基础费用, legacy 链为 nil
+	Slow    FeeTier
+	Normal  FeeTier
+	Fast    FeeTier
+}
+
+// Legacy 链是否不支持 EIP-1559
+func (e *FeeEstimate) Legacy() bool {
+	return e.BaseFee == nil
+}
+
+// Tier 返回 speed 对应的档位, FeeDefault 返回 Normal
+func (e *FeeEstimate) Tier(speed FeeSpeed) FeeTier {
+	return *e.tier(speed)
+}
+
+func (e *FeeEstimate) tier(speed FeeSpeed) *FeeTier {
+	switch speed {
+	case FeeSlow:
+		return &e.Slow
+	case FeeFast:
+		return &e.Fast
+	}
+	return &e.Normal
+}
+
+// EstimateFees 由最近区块的 eth_feeHistory 计算各档位的燃料费用
+// 小费取各区块对应百分位的中位数, legacy 链按节点建议的燃料价格加价
+func EstimateFees(ctx context.Context, source FeeSource) (*FeeEstimate, error) {
+	head, err := source.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, log.WithError(err, "HeaderByNumber failed")
+	}
+	blockTime, err := averageBlockTime(ctx, source, head)
+	if err != nil {
+		return nil, err
+	}
+
+	speeds := []FeeSpeed{FeeSlow, FeeNormal, FeeFast}
+	estimate := &FeeEstimate{}
+	if head.BaseFee == nil {
+		gasPrice, err := source.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, log.WithError(err, "SuggestGasPrice failed")
+		}
+		for _, speed := range speeds {
+			params := feeTierParams[speed]
+			tier := estimate.tier(speed)
+			tier.GasPrice = new(big.Int).Div(new(big.Int).Mul(gasPrice, big.NewInt(params.gasPrice)), big.NewInt(100))
+			tier.Wait = blockTime * time.Duration(params.blocks)
+		}
+		return estimate, nil
+	}
+
+	percentiles := make([]float64, len(speeds))
+	for i, speed := range speeds {
+		percentiles[i] = feeTierParams[speed].percentile
+	}
+	history, err := source.FeeHistory(ctx, feeHistoryBlocks, head.Number, percentiles)
+	if err != nil {
+		return nil, log.WithError(err, "FeeHistory failed")
+	}
+	// 最后一个为下一个区块的基础费用
+	estimate.BaseFee = head.BaseFee
+	if len(history.BaseFee) > 0 {
+		estimate.BaseFee = history.BaseFee[len(history.BaseFee)-1]
+	}
+
+	tips, err := percentileTips(ctx, source, history, len(speeds))
+	if err != nil {
+		return nil, err
+	}
+	for i, speed := range speeds {
+		params := feeTierParams[speed]
+		tier := estimate.tier(speed)
+		tip := tips[i]
+		// 高档位的小费不低于低档位
+		if i > 0 && tip.Cmp(tips[i-1]) < 0 {
+			tip = tips[i-1]
+		}
+		tips[i] = tip
+		tier.GasTipCap = tip
+		tier.GasFeeCap = new(big.Int).Add(tip, new(big.Int).Div(new(big.Int).Mul(estimate.BaseFee, big.NewInt(params.baseFeeMul)), big.NewInt(4)))
+		tier.Wait = blockTime * time.Duration(params.blocks)
+	}
+	return estimate, nil
+}
+
+// percentileTips 每个百分位在非空区块中的中位数, 没有非空区块时使用节点建议的小费
+func percentileTips(ctx context.Context, source FeeSource, history *ethereum.FeeHistory, count int) ([]*big.Int, error) {
+	samples := make([][]*big.Int, count)
+	for i, rewards := range history.Reward {
+		if i < len(history.GasUsedRatio) && history.GasUsedRatio[i] == 0 {
+			continue
+		}
+		for j := 0; j < count && j < len(rewards); j++ {
+			samples[j] = append(samples[j], rewards[j])
+		}
+	}
+	tips := make([]*big.Int, count)
+	var suggested *big.Int
+	for i, sample := range samples {
+		if len(sample) == 0 {
+			if suggested == nil {
+				tip, err := source.SuggestGasTipCap(ctx)
+				if err != nil {
+					return nil, log.WithError(err, "SuggestGasTipCap failed")
+				}
+				suggested = tip
+			}
+			tips[i] = suggested
+			continue
+		}
+		sort.Slice(sample, func(a, b int) bool { return sample[a].Cmp(sample[b]) < 0 })
+		tips[i] = sample[len(sample)/2]
+	}
+	return tips, nil
+}
+
+// averageBlockTime 最近区块的平均出块时间
+func averageBlockTime(ctx context.Context, source FeeSource, head *types.Header) (time.Duration, error) {
+	blocks := int64(feeHistoryBlocks)
+	if head.Number.Int64() < blocks {
+		blocks = head.Number.Int64()
+	}
+	if blocks == 0 {
+		return 0, nil
+	}
+	oldest, err := source.HeaderByNumber(ctx, new(big.Int).Sub(head.Number, big.NewInt(blocks)))
+	if err != nil {
+		return 0, log.WithError(err, "HeaderByNumber failed")
+	}
+	return time.Duration(head.Time-oldest.Time) * time.Second / time.Duration(blocks), nil
+}
+
+// EstimateFees 当前链的慢、中、快三档燃料费用
+func (c *Chain) EstimateFees() (*FeeEstimate, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, log.WithError(err, "Client failed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout())
+	defer cancel()
+	return EstimateFees(ctx, client.RPCClient())
+}
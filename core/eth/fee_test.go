@@ -0,0 +1,98 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// fakeFeeSource 每 12 秒一个区块的 FeeSource
+type fakeFeeSource struct {
+	head    uint64
+	baseFee *big.Int
+	history *ethereum.FeeHistory
+}
+
+func (s *fakeFeeSource) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	n := s.head
+	if number != nil {
+		n = number.Uint64()
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(n), Time: 1700000000 + n*12, BaseFee: s.baseFee}, nil
+}
+
+func (s *fakeFeeSource) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	return s.history, nil
+}
+
+func (s *fakeFeeSource) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(5e9), nil
+}
+
+func (s *fakeFeeSource) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1e9), nil
+}
+
+func gwei(values ...int64) []*big.Int {
+	result := make([]*big.Int, len(values))
+	for i, value := range values {
+		result[i] = big.NewInt(value * 1e9)
+	}
+	return result
+}
+
+func TestEstimateFees(t *testing.T) {
+	source := &fakeFeeSource{
+		head:    1000,
+		baseFee: big.NewInt(30e9),
+		history: &ethereum.FeeHistory{
+			Reward: [][]*big.Int{
+				gwei(1, 2, 5),
+				gwei(0, 0, 0), // 空区块
+				gwei(2, 3, 4),
+				gwei(1, 1, 6),
+			},
+			BaseFee:      gwei(30, 28, 29, 30, 32),
+			GasUsedRatio: []float64{0.5, 0, 0.7, 0.6},
+		},
+	}
+	estimate, err := EstimateFees(context.Background(), source)
+	assert.NoError(t, err)
+	assert.False(t, estimate.Legacy())
+	assert.Equal(t, big.NewInt(32e9), estimate.BaseFee)
+
+	assert.Equal(t, big.NewInt(1e9), estimate.Slow.GasTipCap)
+	assert.Equal(t, big.NewInt(41e9), estimate.Slow.GasFeeCap)
+	assert.Equal(t, 120*time.Second, estimate.Slow.Wait)
+	assert.Equal(t, big.NewInt(2e9), estimate.Normal.GasTipCap)
+	assert.Equal(t, big.NewInt(66e9), estimate.Normal.GasFeeCap)
+	assert.Equal(t, big.NewInt(5e9), estimate.Fast.GasTipCap)
+	assert.Equal(t, 12*time.Second, estimate.Fast.Wait)
+	assert.Equal(t, estimate.Fast, estimate.Tier(FeeFast))
+	assert.Equal(t, estimate.Normal, estimate.Tier(FeeDefault))
+
+	// 全部为空区块时使用节点建议的小费
+	source.history.GasUsedRatio = []float64{0, 0, 0, 0}
+	estimate, err = EstimateFees(context.Background(), source)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1e9), estimate.Slow.GasTipCap)
+	assert.Equal(t, big.NewInt(1e9), estimate.Fast.GasTipCap)
+
+	// legacy 链只有燃料价格
+	source.baseFee = nil
+	estimate, err = EstimateFees(context.Background(), source)
+	assert.NoError(t, err)
+	assert.True(t, estimate.Legacy())
+	assert.Equal(t, big.NewInt(5e9), estimate.Slow.GasPrice)
+	assert.Equal(t, big.NewInt(5500000000), estimate.Normal.GasPrice)
+	assert.Equal(t, big.NewInt(6250000000), estimate.Fast.GasPrice)
+	assert.Nil(t, estimate.Fast.GasFeeCap)
+
+	tx := NewTransaction(from, to, big.NewInt(1), NewChain()).SetFeeTier(estimate.Fast)
+	assert.Equal(t, big.NewInt(6250000000), tx.GasPrice)
+	assert.Nil(t, tx.GasFeeCap)
+}
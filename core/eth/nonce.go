@@ -0,0 +1,268 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NonceSource 查询账户的 pending nonce, ethclient.Client 实现了该接口
+type NonceSource interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// nonceErrors 节点返回的与 nonce 相关的错误, 出现时需要与节点重新同步
+var nonceErrors = []string{
+	"nonce too low",
+	"nonce too high",
+	"replacement transaction underpriced",
+	"already known",
+}
+
+// IsNonceError 是否为 nonce 冲突或过期导致的发送失败
+func IsNonceError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, nonceErr := range nonceErrors {
+		if strings.Contains(msg, nonceErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// accountNonce 账户的本地 nonce 状态
+type accountNonce struct {
+	Next     uint64   `json:"next"`     // 下一个分配的 nonce
+	Released []uint64 `json:"released"` // 已分配但未发送的 nonce, 升序
+	synced   bool
+}
+
+// nonceFile 持久化文件的内容
+type nonceFile struct {
+	ChainId  string                           `json:"chainId"`
+	Accounts map[common.Address]*accountNonce `json:"accounts"`
+}
+
+// NonceManager 在本地按账户顺序分配 nonce, 同一账户并发发送时不会重复
+// 每个 NonceManager 对应一条链, 指定文件路径时每次变化后写入文件, 重启后继续使用
+type NonceManager struct {
+	source  NonceSource
+	chainId *big.Int
+	path    string
+
+	mu       sync.Mutex
+	accounts map[common.Address]*accountNonce
+}
+
+// NewNonceManager 创建 NonceManager, path 为空时只保存在内存中, 文件中的链与 chainId 不一致时返回错误
+func NewNonceManager(source NonceSource, chainId *big.Int, path string) (*NonceManager, error) {
+	m := &NonceManager{source: source, chainId: chainId, path: path, accounts: make(map[common.Address]*accountNonce)}
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, log.WithError(err, "ReadFile failed")
+	}
+	var file nonceFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, log.WithError(err, "Unmarshal failed")
+	}
+	if chainId != nil && file.ChainId != chainId.String() {
+		return nil, log.WithError(utils.ErrInvalidValue, "nonce file chainId "+file.ChainId)
+	}
+	if file.Accounts != nil {
+		m.accounts = file.Accounts
+	}
+	return m, nil
+}
+
+// Next 分配下一个 nonce, 优先复用已归还的 nonce, 账户第一次使用时与节点同步
+func (m *NonceManager) Next(ctx context.Context, account common.Address) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, err := m.state(ctx, account)
+	if err != nil {
+		return 0, err
+	}
+	var nonce uint64
+	if len(state.Released) > 0 {
+		nonce, state.Released = state.Released[0], state.Released[1:]
+	} else {
+		nonce = state.Next
+		state.Next++
+	}
+	return nonce, m.save()
+}
+
+// Release 归还未发送的 nonce, 下一次 Next 时重新分配
+func (m *NonceManager) Release(account common.Address, nonce uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.accounts[account]
+	if state == nil || nonce >= state.Next {
+		return nil
+	}
+	i := sort.Search(len(state.Released), func(i int) bool { return state.Released[i] >= nonce })
+	if i < len(state.Released) && state.Released[i] == nonce {
+		return nil
+	}
+	state.Released = append(state.Released, 0)
+	copy(state.Released[i+1:], state.Released[i:])
+	state.Released[i] = nonce
+	// 归还的是最后分配的 nonce 时回退
+	for n := len(state.Released); n > 0 && state.Released[n-1] == state.Next-1; n-- {
+		state.Released = state.Released[:n-1]
+		state.Next--
+	}
+	return m.save()
+}
+
+// Resync 与节点重新同步, 节点的 pending nonce 更大时跳过本地已过期的 nonce
+func (m *NonceManager) Resync(ctx context.Context, account common.Address) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending, err := m.pendingNonce(ctx, account)
+	if err != nil {
+		return err
+	}
+	m.merge(m.account(account), pending)
+	return m.save()
+}
+
+// Reset 丢弃本地状态, 使用节点的 pending nonce, 用于已分配的交易被节点丢弃后填补空缺
+func (m *NonceManager) Reset(ctx context.Context, account common.Address) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending, err := m.pendingNonce(ctx, account)
+	if err != nil {
+		return err
+	}
+	m.accounts[account] = &accountNonce{Next: pending, synced: true}
+	return m.save()
+}
+
+// Gaps 返回本地已分配但节点未收到的 nonce, 这些 nonce 之后的交易不会被打包
+// 应在没有正在发送的交易时调用, 否则正在发送的 nonce 也会被返回
+func (m *NonceManager) Gaps(ctx context.Context, account common.Address) ([]uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending, err := m.pendingNonce(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	state := m.accounts[account]
+	if state == nil || pending >= state.Next {
+		return nil, nil
+	}
+	gaps := []uint64{pending}
+	for _, nonce := range state.Released {
+		if nonce > pending {
+			gaps = append(gaps, nonce)
+		}
+	}
+	return gaps, nil
+}
+
+// HandleSendError 根据发送结果处理分配的 nonce, 返回是否与节点重新同步
+// nonce 过低或重复时重新同步; nonce 过高说明之前分配的 nonce 未被节点收到, 丢弃本地状态使用节点的 pending nonce
+// 节点明确拒绝的交易归还 nonce; 超时、连接断开等无法确定节点是否收到交易的错误保留 nonce, 之后由 Gaps 和 Resync 处理
+func (m *NonceManager) HandleSendError(ctx context.Context, account common.Address, nonce uint64, sendErr error) (bool, error) {
+	switch {
+	case sendErr == nil:
+		return false, nil
+	case strings.Contains(strings.ToLower(sendErr.Error()), "nonce too high"):
+		return true, m.Reset(ctx, account)
+	case IsNonceError(sendErr):
+		return true, m.Resync(ctx, account)
+	case IsSendRejected(sendErr):
+		return false, m.Release(account, nonce)
+	}
+	return false, nil
+}
+
+// IsSendRejected 节点是否明确拒绝了交易, 节点返回 JSON-RPC 错误时交易没有进入交易池
+func IsSendRejected(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr)
+}
+
+// state 账户的状态, 进程内第一次使用时与节点同步
+func (m *NonceManager) state(ctx context.Context, account common.Address) (*accountNonce, error) {
+	state := m.account(account)
+	if state.synced {
+		return state, nil
+	}
+	pending, err := m.pendingNonce(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	m.merge(state, pending)
+	state.synced = true
+	return state, nil
+}
+
+func (m *NonceManager) account(account common.Address) *accountNonce {
+	state := m.accounts[account]
+	if state == nil {
+		state = &accountNonce{}
+		m.accounts[account] = state
+	}
+	return state
+}
+
+// merge 节点的 pending nonce 之前的 nonce 都已使用
+func (m *NonceManager) merge(state *accountNonce, pending uint64) {
+	if pending > state.Next {
+		state.Next = pending
+	}
+	released := state.Released[:0]
+	for _, nonce := range state.Released {
+		if nonce >= pending {
+			released = append(released, nonce)
+		}
+	}
+	state.Released = released
+}
+
+func (m *NonceManager) pendingNonce(ctx context.Context, account common.Address) (uint64, error) {
+	pending, err := m.source.PendingNonceAt(ctx, account)
+	if err != nil {
+		return 0, log.WithError(err, "PendingNonceAt failed")
+	}
+	return pending, nil
+}
+
+// save 路径不为空时写入文件
+func (m *NonceManager) save() error {
+	if m.path == "" {
+		return nil
+	}
+	file := nonceFile{Accounts: m.accounts}
+	if m.chainId != nil {
+		file.ChainId = m.chainId.String()
+	}
+	data, err := json.MarshalIndent(file, "", " ")
+	if err != nil {
+		return log.WithError(err, "MarshalIndent failed")
+	}
+	if err = utils.WriteFileAtomic(m.path, data); err != nil {
+		return log.WithError(err, "WriteFileAtomic failed")
+	}
+	return nil
+}
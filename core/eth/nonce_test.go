@@ -0,0 +1,193 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// fakeNonceSource 固定返回 pending 的 NonceSource
+type fakeNonceSource struct {
+	mu      sync.Mutex
+	pending uint64
+	calls   int
+}
+
+func (s *fakeNonceSource) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	return s.pending, nil
+}
+
+func (s *fakeNonceSource) setPending(pending uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = pending
+}
+
+func TestNonceManager_Next(t *testing.T) {
+	ctx := context.Background()
+	source := &fakeNonceSource{pending: 5}
+	manager, err := NewNonceManager(source, big.NewInt(1), "")
+	assert.NoError(t, err)
+
+	// 并发分配不重复且连续
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := manager.Next(ctx, from)
+			assert.NoError(t, err)
+			mu.Lock()
+			seen[nonce] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, 20)
+	for nonce := uint64(5); nonce < 25; nonce++ {
+		assert.True(t, seen[nonce], nonce)
+	}
+	assert.Equal(t, 1, source.calls)
+
+	// 归还最后分配的 nonce 时回退, 中间的 nonce 优先复用
+	assert.NoError(t, manager.Release(from, 24))
+	assert.NoError(t, manager.Release(from, 10))
+	nonce, _ := manager.Next(ctx, from)
+	assert.Equal(t, uint64(10), nonce)
+	nonce, _ = manager.Next(ctx, from)
+	assert.Equal(t, uint64(24), nonce)
+
+	// 其它账户独立分配
+	nonce, _ = manager.Next(ctx, to)
+	assert.Equal(t, uint64(5), nonce)
+}
+
+// rpcError 节点返回的 JSON-RPC 错误
+type rpcError struct {
+	code int
+	msg  string
+}
+
+func (e rpcError) Error() string {
+	return e.msg
+}
+
+func (e rpcError) ErrorCode() int {
+	return e.code
+}
+
+func TestNonceManager_HandleSendError(t *testing.T) {
+	ctx := context.Background()
+	source := &fakeNonceSource{pending: 3}
+	manager, _ := NewNonceManager(source, big.NewInt(1), "")
+	first, _ := manager.Next(ctx, from)
+	second, _ := manager.Next(ctx, from)
+	assert.Equal(t, uint64(4), second)
+
+	// 节点明确拒绝时归还 nonce
+	resynced, err := manager.HandleSendError(ctx, from, second, rpcError{code: -32000, msg: "insufficient funds for gas * price + value"})
+	assert.NoError(t, err)
+	assert.False(t, resynced)
+	nonce, _ := manager.Next(ctx, from)
+	assert.Equal(t, second, nonce)
+
+	// 超时等无法确定节点是否收到交易的错误保留 nonce
+	resynced, err = manager.HandleSendError(ctx, from, nonce, context.DeadlineExceeded)
+	assert.NoError(t, err)
+	assert.False(t, resynced)
+	nonce, _ = manager.Next(ctx, from)
+	assert.Equal(t, uint64(5), nonce)
+
+	// 其它程序使用了该账户, 节点返回 nonce too low 后跳到节点的 pending nonce
+	source.setPending(9)
+	resynced, err = manager.HandleSendError(ctx, from, first, rpcError{code: -32000, msg: "nonce too low: next nonce 9, tx nonce 3"})
+	assert.NoError(t, err)
+	assert.True(t, resynced)
+	nonce, _ = manager.Next(ctx, from)
+	assert.Equal(t, uint64(9), nonce)
+
+	// 之前的 nonce 未被节点收到, nonce too high 后从节点的 pending nonce 重新开始
+	_, _ = manager.Next(ctx, from)
+	resynced, err = manager.HandleSendError(ctx, from, 10, rpcError{code: -32000, msg: "nonce too high"})
+	assert.NoError(t, err)
+	assert.True(t, resynced)
+	nonce, _ = manager.Next(ctx, from)
+	assert.Equal(t, uint64(9), nonce)
+
+	resynced, err = manager.HandleSendError(ctx, from, nonce, nil)
+	assert.NoError(t, err)
+	assert.False(t, resynced)
+}
+
+func TestNonceManager_Gaps(t *testing.T) {
+	ctx := context.Background()
+	source := &fakeNonceSource{pending: 0}
+	manager, _ := NewNonceManager(source, big.NewInt(1), "")
+	for i := 0; i < 4; i++ {
+		_, _ = manager.Next(ctx, from)
+	}
+	// 0 被节点丢弃, 2 未发送
+	assert.NoError(t, manager.Release(from, 2))
+	gaps, err := manager.Gaps(ctx, from)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0, 2}, gaps)
+
+	source.setPending(4)
+	gaps, err = manager.Gaps(ctx, from)
+	assert.NoError(t, err)
+	assert.Empty(t, gaps)
+
+	// Reset 后从节点的 pending nonce 开始
+	source.setPending(1)
+	assert.NoError(t, manager.Reset(ctx, from))
+	nonce, _ := manager.Next(ctx, from)
+	assert.Equal(t, uint64(1), nonce)
+}
+
+func TestNonceManager_Persist(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nonces", "1.json")
+	source := &fakeNonceSource{pending: 7}
+	manager, err := NewNonceManager(source, big.NewInt(1), path)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, _ = manager.Next(ctx, from)
+	}
+	assert.NoError(t, manager.Release(from, 8))
+
+	// 重启后节点还未收到交易, 继续使用本地状态
+	manager, err = NewNonceManager(source, big.NewInt(1), path)
+	assert.NoError(t, err)
+	nonce, _ := manager.Next(ctx, from)
+	assert.Equal(t, uint64(8), nonce)
+	nonce, _ = manager.Next(ctx, from)
+	assert.Equal(t, uint64(10), nonce)
+
+	_, err = NewNonceManager(source, big.NewInt(5), path)
+	assert.Equal(t, utils.ErrInvalidValue.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestIsSendRejected(t *testing.T) {
+	assert.True(t, IsSendRejected(rpcError{code: -32000, msg: "intrinsic gas too low"}))
+	assert.False(t, IsSendRejected(errors.New("connection reset by peer")))
+	assert.False(t, IsSendRejected(context.DeadlineExceeded))
+}
+
+func TestIsNonceError(t *testing.T) {
+	assert.True(t, IsNonceError(errors.New("nonce too low")))
+	assert.True(t, IsNonceError(errors.New("Replacement transaction underpriced")))
+	assert.True(t, IsNonceError(errors.New("already known")))
+	assert.False(t, IsNonceError(errors.New("insufficient funds")))
+	assert.False(t, IsNonceError(nil))
+}
@@ -0,0 +1,134 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"math/big"
+)
+
+// ReplacementPriceBump 节点接受替换交易要求的最低涨幅百分比, geth 默认 10
+var ReplacementPriceBump int64 = 10
+
+// ReplaceSource 查询和发送交易, ethclient.Client 实现了该接口
+type ReplaceSource interface {
+	FeeSource
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// SpeedUpTransaction 使用相同的 nonce 和更高的燃料费用重新发送 pending 交易
+// 燃料费用至少提高 ReplacementPriceBump, speed 不为 FeeDefault 时不低于对应档位的当前费用
+func (c *Chain) SpeedUpTransaction(from *Account, hash string, speed FeeSpeed) (*types.Transaction, error) {
+	return c.replaceTransaction(from, hash, false, speed)
+}
+
+// CancelTransaction 使用相同的 nonce 向自己发送 0 金额交易, 使 pending 交易失效
+func (c *Chain) CancelTransaction(from *Account, hash string, speed FeeSpeed) (*types.Transaction, error) {
+	return c.replaceTransaction(from, hash, true, speed)
+}
+
+func (c *Chain) replaceTransaction(from *Account, hash string, cancel bool, speed FeeSpeed) (*types.Transaction, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, log.WithError(err, "Client failed")
+	}
+	ctx, cancelCtx := context.WithTimeout(context.Background(), client.Timeout())
+	defer cancelCtx()
+	return replaceTransaction(ctx, client.RPCClient(), c, from, common.HexToHash(hash), cancel, speed)
+}
+
+func replaceTransaction(ctx context.Context, source ReplaceSource, chain *Chain, from *Account, hash common.Hash, cancel bool, speed FeeSpeed) (*types.Transaction, error) {
+	original, isPending, err := source.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, log.WithError(utils.ErrTransactionNotPending, hash.Hex())
+	}
+	if err != nil {
+		return nil, log.WithError(err, "TransactionByHash failed")
+	}
+	if !isPending {
+		return nil, log.WithError(utils.ErrTransactionNotPending, hash.Hex())
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(original.ChainId()), original)
+	if err != nil {
+		return nil, log.WithError(err, "Sender failed")
+	}
+	if sender != from.Address() {
+		return nil, log.WithError(utils.ErrInvalidValue, "transaction is not sent by "+from.Address().Hex())
+	}
+
+	var tx *Transaction
+	switch {
+	case cancel:
+		tx = NewTransaction(from.Address(), from.Address(), big.NewInt(0), chain)
+		tx.GasLimit = uint64(DefaultEthGasList)
+	case original.To() == nil:
+		return nil, log.WithError(utils.ErrNotSupported, "contract creation can only be cancelled")
+	default:
+		tx = NewTransaction(from.Address(), *original.To(), original.Value(), chain)
+		tx.Data = original.Data()
+		tx.GasLimit = original.Gas()
+	}
+	tx.Nonce = new(big.Int).SetUint64(original.Nonce())
+
+	var estimate *FeeEstimate
+	if speed != FeeDefault {
+		estimate, err = EstimateFees(ctx, source)
+		if err != nil {
+			return nil, log.WithError(err, "EstimateFees failed")
+		}
+	}
+	setReplacementFees(tx, original, estimate, speed)
+
+	signTx, err := tx.SignTx(from.privateKeyECDSA)
+	if err != nil {
+		return nil, log.WithError(err)
+	}
+	if err = source.SendTransaction(ctx, signTx); err != nil {
+		return nil, log.WithError(err, "SendTransaction failed")
+	}
+	return signTx, nil
+}
+
+// setReplacementFees 按原交易的类型设置燃料费用, 每项费用取提高后的原费用和当前档位中的较大值
+// estimate 为 nil 时只提高原费用
+func setReplacementFees(tx *Transaction, original *types.Transaction, estimate *FeeEstimate, speed FeeSpeed) {
+	var tier FeeTier
+	if estimate != nil {
+		tier = estimate.Tier(speed)
+	}
+	if original.Type() != types.DynamicFeeTxType {
+		gasPrice := bumpFee(original.GasPrice())
+		current := tier.GasPrice
+		// EIP-1559 链上的 legacy 交易, 燃料价格为基础费用 + 小费
+		if current == nil && tier.GasTipCap != nil {
+			current = new(big.Int).Add(estimate.BaseFee, tier.GasTipCap)
+		}
+		tx.GasPrice = maxFee(gasPrice, current)
+		return
+	}
+	tip := maxFee(bumpFee(original.GasTipCap()), tier.GasTipCap, tier.GasPrice)
+	feeCap := maxFee(bumpFee(original.GasFeeCap()), tier.GasFeeCap, tier.GasPrice)
+	tx.GasTipCap = tip
+	tx.GasFeeCap = maxFee(feeCap, tip)
+}
+
+// bumpFee 提高 ReplacementPriceBump, 向上取整
+func bumpFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+ReplacementPriceBump))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+func maxFee(fee *big.Int, others ...*big.Int) *big.Int {
+	for _, other := range others {
+		if other != nil && other.Cmp(fee) > 0 {
+			fee = other
+		}
+	}
+	return fee
+}
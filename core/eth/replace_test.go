@@ -0,0 +1,114 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"math/big"
+	"testing"
+)
+
+// fakeReplaceSource 记录发送的交易
+type fakeReplaceSource struct {
+	fakeFeeSource
+	txs     map[common.Hash]*types.Transaction
+	pending map[common.Hash]bool
+	sent    []*types.Transaction
+}
+
+func (s *fakeReplaceSource) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	tx, ok := s.txs[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	return tx, s.pending[hash], nil
+}
+
+func (s *fakeReplaceSource) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	s.sent = append(s.sent, tx)
+	return nil
+}
+
+func (s *fakeReplaceSource) add(tx *types.Transaction, pending bool) common.Hash {
+	s.txs[tx.Hash()] = tx
+	s.pending[tx.Hash()] = pending
+	return tx.Hash()
+}
+
+func TestReplaceTransaction(t *testing.T) {
+	ctx := context.Background()
+	chain := &Chain{chainId: big.NewInt(1)}
+	account := &Account{privateKeyECDSA: pri}
+	source := &fakeReplaceSource{
+		fakeFeeSource: fakeFeeSource{
+			head:    1000,
+			baseFee: big.NewInt(30e9),
+			history: &ethereum.FeeHistory{
+				Reward:       [][]*big.Int{gwei(1, 2, 3)},
+				BaseFee:      gwei(30, 30),
+				GasUsedRatio: []float64{0.5},
+			},
+		},
+		txs:     map[common.Hash]*types.Transaction{},
+		pending: map[common.Hash]bool{},
+	}
+
+	original := NewTransaction(account.Address(), to, big.NewInt(1e15), chain)
+	original.Nonce, original.GasLimit, original.Data = big.NewInt(7), 60000, []byte{0xa9, 0x05}
+	original.GasTipCap, original.GasFeeCap = big.NewInt(1e9), big.NewInt(100e9)
+	signed, err := original.SignTx(pri)
+	assert.NoError(t, err)
+	hash := source.add(signed, true)
+
+	// 只提高原费用
+	speedUp, err := replaceTransaction(ctx, source, chain, account, hash, false, FeeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), speedUp.Nonce())
+	assert.Equal(t, to, *speedUp.To())
+	assert.Equal(t, signed.Value(), speedUp.Value())
+	assert.Equal(t, signed.Data(), speedUp.Data())
+	assert.Equal(t, uint64(60000), speedUp.Gas())
+	assert.Equal(t, big.NewInt(1.1e9), speedUp.GasTipCap())
+	assert.Equal(t, big.NewInt(110e9), speedUp.GasFeeCap())
+
+	// 当前快速档位的小费更高
+	speedUp, err = replaceTransaction(ctx, source, chain, account, hash, false, FeeFast)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(3e9), speedUp.GasTipCap())
+	assert.Equal(t, big.NewInt(110e9), speedUp.GasFeeCap())
+
+	cancel, err := replaceTransaction(ctx, source, chain, account, hash, true, FeeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), cancel.Nonce())
+	assert.Equal(t, account.Address(), *cancel.To())
+	assert.Equal(t, int64(0), cancel.Value().Int64())
+	assert.Empty(t, cancel.Data())
+	assert.Equal(t, uint64(DefaultEthGasList), cancel.Gas())
+	sender, _ := types.Sender(types.LatestSignerForChainID(chain.ChainId()), cancel)
+	assert.Equal(t, account.Address(), sender)
+	assert.Len(t, source.sent, 3)
+
+	// legacy 交易
+	legacy := NewTransaction(account.Address(), to, big.NewInt(1), chain)
+	legacy.Nonce, legacy.GasLimit, legacy.GasPrice = big.NewInt(8), 21000, big.NewInt(20e9)
+	signed, _ = legacy.SignTx(pri)
+	legacyHash := source.add(signed, true)
+	speedUp, err = replaceTransaction(ctx, source, chain, account, legacyHash, false, FeeNormal)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(types.LegacyTxType), speedUp.Type())
+	assert.Equal(t, big.NewInt(32e9), speedUp.GasPrice())
+
+	// 已打包、不存在或不是本账户发送的交易
+	source.pending[hash] = false
+	_, err = replaceTransaction(ctx, source, chain, account, hash, true, FeeDefault)
+	assert.Equal(t, utils.ErrTransactionNotPending.ErrCode, err.(*utils.Error).ErrCode)
+	_, err = replaceTransaction(ctx, source, chain, account, common.Hash{1}, true, FeeDefault)
+	assert.Equal(t, utils.ErrTransactionNotPending.ErrCode, err.(*utils.Error).ErrCode)
+	other, _ := NewAccountWithPrivateKey("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	_, err = replaceTransaction(ctx, source, chain, other, legacyHash, true, FeeDefault)
+	assert.Equal(t, utils.ErrInvalidValue.ErrCode, err.(*utils.Error).ErrCode)
+	assert.Len(t, source.sent, 4)
+}
@@ -75,7 +75,7 @@ func (t *Token) BalanceOfAddress(address string) (*base.Balance, error) {
 
 func (t *Token) EstimateGasLimit(from, to common.Address, amount *big.Int) (uint64, error) {
 	tx := NewTransaction(from, to, amount, t.chain)
-	err := tx.BuildTransfer()
+	err := tx.ensureGasPrice()
 	if err != nil {
 		return 0, log.WithError(err)
 	}
@@ -120,12 +120,14 @@ func (t *Token) Transfer(from Account, to common.Address, value *big.Int) (*type
 	// 签名
 	signTx, err := tx.SignTx(from.privateKeyECDSA)
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 
 	// 执行交易
 	client, err := t.chain.Client()
 	if err != nil {
+		tx.ReleaseNonce()
 		return nil, log.WithError(err)
 	}
 
@@ -134,6 +136,7 @@ func (t *Token) Transfer(from Account, to common.Address, value *big.Int) (*type
 
 	err = client.RPCClient().SendTransaction(ctx, signTx)
 	if err != nil {
+		tx.SendResult(err)
 		return nil, log.WithError(err)
 	}
 
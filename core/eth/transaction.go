@@ -28,8 +28,10 @@ type Transaction struct {
 	Nonce     *big.Int // 交易的nonce
 	GasLimit  uint64   // 燃料限制
 
-	chain *Chain
-	ctx   context.Context
+	chain        *Chain
+	ctx          context.Context
+	speed        FeeSpeed
+	nonceManaged bool // Nonce 由 NonceManager 分配
 }
 
 //func (tx *Transaction) EstimateGasLimit(from, to common.Address, amount *big.Int, input []byte) (uint64, error) {
@@ -70,6 +72,18 @@ func NewTransaction(from common.Address, to common.Address, value *big.Int, chai
 	}
 }
 
+// SetFeeTier 使用 EstimateFees 返回的档位作为燃料费用
+func (tx *Transaction) SetFeeTier(tier FeeTier) *Transaction {
+	tx.GasPrice, tx.GasTipCap, tx.GasFeeCap = tier.GasPrice, tier.GasTipCap, tier.GasFeeCap
+	return tx
+}
+
+// SetFeeSpeed 构建交易时按 speed 对应的档位计算燃料费用, 已指定燃料费用时不生效
+func (tx *Transaction) SetFeeSpeed(speed FeeSpeed) *Transaction {
+	tx.speed = speed
+	return tx
+}
+
 // 确保燃料价格的方法
 func (tx *Transaction) ensureGasPrice() error {
 	client, err := tx.chain.Client()
@@ -77,6 +91,16 @@ func (tx *Transaction) ensureGasPrice() error {
 		return log.WithError(err)
 	}
 
+	if tx.speed != FeeDefault && tx.GasPrice == nil && tx.GasFeeCap == nil && tx.GasTipCap == nil {
+		estimate, err := EstimateFees(tx.ctx, client.RPCClient())
+		if err != nil {
+			return log.WithError(err)
+		}
+		tx.BaseFee = estimate.BaseFee
+		tx.SetFeeTier(estimate.Tier(tx.speed))
+		return nil
+	}
+
 	// Get the latest block header
 	head, err := client.RPCClient().HeaderByNumber(tx.ctx, nil)
 	if err != nil {
@@ -86,11 +110,12 @@ func (tx *Transaction) ensureGasPrice() error {
 
 	// If the base fee is nil, set the gas price if it is also nil
 	if head.BaseFee == nil {
-		tx.GasPrice, err = client.RPCClient().SuggestGasPrice(tx.ctx)
-		if err != nil {
-			return log.WithError(err)
+		if tx.GasPrice == nil {
+			tx.GasPrice, err = client.RPCClient().SuggestGasPrice(tx.ctx)
+			if err != nil {
+				return log.WithError(err)
+			}
 		}
-
 	} else {
 		// If the gas tip cap is nil, set it from the backend
 		// eip1159 gas price
@@ -117,17 +142,21 @@ func (tx *Transaction) ensureGasPrice() error {
 	return nil
 }
 
-// getNonce 获取给定地址的nonce。
+// getNonce 从链的 NonceManager 分配给定地址的nonce
 func (tx *Transaction) getNonce(address common.Address) (*big.Int, error) {
 	client, err := tx.chain.Client()
 	if err != nil {
 		return nil, log.WithError(err)
 	}
+	manager, err := tx.chain.NonceManager()
+	if err != nil {
+		return nil, log.WithError(err)
+	}
 
 	ctx, cancel := context.WithTimeout(tx.ctx, client.Timeout())
 	defer cancel()
 
-	nonce, err := client.RPCClient().PendingNonceAt(ctx, address)
+	nonce, err := manager.Next(ctx, address)
 	if err != nil {
 		return nil, log.WithError(err)
 	}
@@ -136,24 +165,54 @@ func (tx *Transaction) getNonce(address common.Address) (*big.Int, error) {
 
 }
 
-// BuildTransfer 构建转账交易
+// BuildTransfer 构建转账交易, 未指定 Nonce 时由 NonceManager 分配, 发送后需要调用 SendResult, 未发送时调用 ReleaseNonce
 func (tx *Transaction) BuildTransfer() error {
-	// 1. 获取nonce
+	// 1. 获取燃料价格
+	err := tx.ensureGasPrice()
+	if err != nil {
+		return log.WithError(err)
+	}
+	// 2. 获取nonce
 	if tx.Nonce == nil {
 		nonce, err := tx.getNonce(tx.From)
 		if err != nil {
 			return log.WithError(err)
 		}
 		tx.Nonce = nonce
+		tx.nonceManaged = true
 	}
-	// 2. 获取燃料价格
-	err := tx.ensureGasPrice()
+
+	return nil
+}
+
+// SendResult 报告发送结果, 由 NonceManager.HandleSendError 处理 BuildTransfer 分配的 nonce
+func (tx *Transaction) SendResult(sendErr error) {
+	if sendErr == nil || !tx.nonceManaged {
+		return
+	}
+	manager, err := tx.chain.NonceManager()
 	if err != nil {
-		return log.WithError(err)
+		return
+	}
+	if _, err = manager.HandleSendError(tx.ctx, tx.From, tx.Nonce.Uint64(), sendErr); err != nil {
+		log.WithError(err, "HandleSendError failed")
 	}
+}
 
-	return nil
+// ReleaseNonce 发送前失败时归还 BuildTransfer 分配的 nonce, 如签名、打包或获取客户端失败
+func (tx *Transaction) ReleaseNonce() {
+	if !tx.nonceManaged {
+		return
+	}
+	manager, err := tx.chain.NonceManager()
+	if err != nil {
+		return
+	}
+	if err = manager.Release(tx.From, tx.Nonce.Uint64()); err != nil {
+		log.WithError(err, "Release failed")
+	}
 }
+
 func (tx *Transaction) ToTransactOpts(privateKeyCDSA *ecdsa.PrivateKey) *bind.TransactOpts {
 	return &bind.TransactOpts{
 		From:      tx.From,
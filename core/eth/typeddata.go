@@ -0,0 +1,111 @@
+package eth
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/core/base"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+)
+
+// TypedData EIP-712 结构化数据, JSON 格式与 eth_signTypedData_v4 的参数相同
+type TypedData = apitypes.TypedData
+
+// ParseTypedData 解析 eth_signTypedData_v4 的 JSON 参数
+func ParseTypedData(data []byte) (*TypedData, error) {
+	var typedData TypedData
+	if err := json.Unmarshal(data, &typedData); err != nil {
+		return nil, log.WithError(err, "Unmarshal failed")
+	}
+	return &typedData, nil
+}
+
+// DomainSeparator EIP712Domain 的哈希
+func DomainSeparator(typedData *TypedData) ([]byte, error) {
+	separator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, log.WithError(err, "HashStruct failed")
+	}
+	return separator, nil
+}
+
+// HashTypedData 待签名的摘要 keccak256("\x19\x01" || domainSeparator || hashStruct(message))
+func HashTypedData(typedData *TypedData) ([]byte, error) {
+	hash, _, err := apitypes.TypedDataAndHash(*typedData)
+	if err != nil {
+		return nil, log.WithError(err, "TypedDataAndHash failed")
+	}
+	return hash, nil
+}
+
+// SignTypedData 使用 signer 签名结构化数据, 返回与 eth_signTypedData_v4 相同的签名, V 为 27 或 28
+func SignTypedData(signer base.Signer, typedData *TypedData) (string, error) {
+	hash, err := HashTypedData(typedData)
+	if err != nil {
+		return "", err
+	}
+	signature, err := signer.SignHash(hash)
+	if err != nil {
+		return "", log.WithError(err, "SignHash failed")
+	}
+	if len(signature) != crypto.SignatureLength {
+		return "", log.WithError(utils.ErrInvalidValue, "invalid signature length")
+	}
+	signature = append([]byte(nil), signature...)
+	if signature[crypto.RecoveryIDOffset] < 27 {
+		signature[crypto.RecoveryIDOffset] += 27
+	}
+	return hexutil.Encode(signature), nil
+}
+
+// RecoverTypedData 从签名恢复签名地址, V 可以是 0、1、27 或 28
+func RecoverTypedData(typedData *TypedData, signatureHex string) (common.Address, error) {
+	signature, err := hexutil.Decode(signatureHex)
+	if err != nil {
+		return common.Address{}, log.WithError(err, "hexutil.Decode failed")
+	}
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, log.WithError(utils.ErrInvalidValue, "invalid signature length")
+	}
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	if signature[crypto.RecoveryIDOffset] > 1 {
+		return common.Address{}, log.WithError(utils.ErrInvalidValue, "invalid signature recovery id")
+	}
+	hash, err := HashTypedData(typedData)
+	if err != nil {
+		return common.Address{}, err
+	}
+	publicKey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return common.Address{}, log.WithError(err, "crypto.SigToPub failed")
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// VerifyTypedData 签名是否由 address 签名
+func VerifyTypedData(address common.Address, typedData *TypedData, signatureHex string) (bool, error) {
+	signer, err := RecoverTypedData(typedData, signatureHex)
+	if err != nil {
+		return false, err
+	}
+	return signer == address, nil
+}
+
+// SignHash 对 32 字节摘要签名, 实现 base.Signer
+func (a *Account) SignHash(hash []byte) ([]byte, error) {
+	signature, err := crypto.Sign(hash, a.privateKeyECDSA)
+	if err != nil {
+		return nil, log.WithError(err, "crypto.Sign failed")
+	}
+	return signature, nil
+}
+
+// SignTypedData 签名 eth_signTypedData_v4 结构化数据
+func (a *Account) SignTypedData(typedData *TypedData) (string, error) {
+	return SignTypedData(a, typedData)
+}
@@ -0,0 +1,130 @@
+package eth
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+// mailTypedData EIP-712 规范中的示例
+const mailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+// groupMailTypedData 包含结构体数组和基本类型数组
+const groupMailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallets", "type": "address[]"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person[]"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": "0x1",
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallets": ["0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826", "0xDeaDbeefdEAdbeefdEadbEEFdeadbeEFdEaDbeeF"]},
+		"to": [{"name": "Bob", "wallets": ["0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"]}],
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestSignTypedData(t *testing.T) {
+	typedData, err := ParseTypedData([]byte(mailTypedData))
+	assert.NoError(t, err)
+	separator, err := DomainSeparator(typedData)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f", hexutil.Encode(separator))
+	hash, err := HashTypedData(typedData)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2", hexutil.Encode(hash))
+
+	// 规范中的私钥 keccak256("cow")
+	account, _ := NewAccountWithPrivateKey(hexutil.Encode(crypto.Keccak256([]byte("cow")))[2:])
+	assert.Equal(t, common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"), account.Address())
+	signature, err := account.SignTypedData(typedData)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d"+
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562"+"1c", signature)
+
+	ok, err := VerifyTypedData(account.Address(), typedData, signature)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	typedData.Message["contents"] = "Hello, Alice!"
+	ok, err = VerifyTypedData(account.Address(), typedData, signature)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = RecoverTypedData(typedData, "0x1234")
+	assert.Equal(t, utils.ErrInvalidValue.ErrCode, err.(*utils.Error).ErrCode)
+}
+
+func TestSignTypedData_Arrays(t *testing.T) {
+	typedData, err := ParseTypedData([]byte(groupMailTypedData))
+	assert.NoError(t, err)
+	account, _ := NewAccountWithPrivateKey(hexutil.Encode(crypto.Keccak256([]byte("cow")))[2:])
+
+	// 任意 base.Signer 都可以签名
+	signature, err := SignTypedData(account, typedData)
+	assert.NoError(t, err)
+	signer, err := RecoverTypedData(typedData, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, account.Address(), signer)
+
+	// 修改数组中的元素后签名失效
+	typedData.Message["to"].([]interface{})[0].(map[string]interface{})["name"] = "Alice"
+	signer, err = RecoverTypedData(typedData, signature)
+	assert.NoError(t, err)
+	assert.NotEqual(t, account.Address(), signer)
+
+	// 消息与类型定义不符
+	typedData.Message["to"] = "Bob"
+	_, err = HashTypedData(typedData)
+	assert.Error(t, err)
+}
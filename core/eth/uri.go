@@ -0,0 +1,203 @@
+package eth
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"math/big"
+	neturl "net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EIP681Scheme EIP-681 付款链接的协议头
+	EIP681Scheme = "ethereum"
+
+	eip681Transfer = "transfer"
+)
+
+// PaymentURI EIP-681 付款请求, 支持原生币和 ERC20 transfer
+// 原生币: ethereum:<to>[@chainId]?value=1e18
+// 代币:   ethereum:<token>[@chainId]/transfer?address=<to>&uint256=1e6
+type PaymentURI struct {
+	To       common.Address  // 收款地址
+	Token    *common.Address // ERC20 合约地址, 原生币为 nil
+	ChainId  *big.Int        // 未指定时为 nil
+	Value    *big.Int        // 最小单位, 未指定金额时为 nil
+	GasLimit uint64
+	GasPrice *big.Int
+}
+
+// ParsePaymentURI 解析 EIP-681 链接, 不支持 ENS 名称和 transfer 以外的合约调用
+func ParsePaymentURI(uri string) (*PaymentURI, error) {
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(uri), ":")
+	if !ok || !strings.EqualFold(scheme, EIP681Scheme) {
+		return nil, log.WithError(utils.ErrInvalidURL, "not an ethereum uri")
+	}
+	rest = strings.TrimPrefix(rest, "pay-")
+	path, query, _ := strings.Cut(rest, "?")
+	path, function, _ := strings.Cut(path, "/")
+	target, chainId, hasChainId := strings.Cut(path, "@")
+
+	targetAddress, err := parseURIAddress(target)
+	if err != nil {
+		return nil, err
+	}
+	values, err := neturl.ParseQuery(query)
+	if err != nil {
+		return nil, log.WithError(utils.ErrInvalidURL, err.Error())
+	}
+
+	p := &PaymentURI{To: targetAddress}
+	if hasChainId {
+		id, ok := new(big.Int).SetString(chainId, 10)
+		if !ok || id.Sign() <= 0 {
+			return nil, log.WithError(utils.ErrInvalidURL, "invalid chain id "+chainId)
+		}
+		p.ChainId = id
+	}
+
+	amountKey := "value"
+	switch function {
+	case "":
+	case eip681Transfer:
+		p.Token = &targetAddress
+		if p.To, err = parseURIAddress(values.Get("address")); err != nil {
+			return nil, err
+		}
+		amountKey = "uint256"
+	default:
+		return nil, log.WithError(utils.ErrInvalidURL, "unsupported function "+function)
+	}
+	if amount := values.Get(amountKey); amount != "" {
+		if p.Value, err = parseURINumber(amount); err != nil {
+			return nil, log.WithError(utils.AmountError, "invalid amount "+amount)
+		}
+	}
+	if gasLimit := values.Get("gasLimit"); gasLimit != "" {
+		limit, err := parseURINumber(gasLimit)
+		if err != nil || !limit.IsUint64() {
+			return nil, log.WithError(utils.ErrInvalidURL, "invalid gasLimit "+gasLimit)
+		}
+		p.GasLimit = limit.Uint64()
+	}
+	if gasPrice := values.Get("gasPrice"); gasPrice != "" {
+		if p.GasPrice, err = parseURINumber(gasPrice); err != nil {
+			return nil, log.WithError(utils.ErrInvalidURL, "invalid gasPrice "+gasPrice)
+		}
+	}
+	return p, nil
+}
+
+// parseURIAddress 大小写混合的地址按 EIP-55 校验
+func parseURIAddress(address string) (common.Address, error) {
+	if !strings.HasPrefix(address, "0x") || !common.IsHexAddress(address) {
+		return common.Address{}, log.WithError(utils.ErrInvalidAddress, "invalid address "+address)
+	}
+	digits := address[2:]
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) {
+		mixed, err := common.NewMixedcaseAddressFromString(address)
+		if err != nil || !mixed.ValidChecksum() {
+			return common.Address{}, log.WithError(utils.ErrAddressChecksum, "invalid address "+address)
+		}
+	}
+	return common.HexToAddress(address), nil
+}
+
+// parseURINumber 解析 EIP-681 数值, 支持科学计数法, 结果必须为非负整数, 如 2.014e18
+func parseURINumber(number string) (*big.Int, error) {
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(number), "e")
+	exp := 0
+	if hasExponent {
+		var err error
+		if exp, err = strconv.Atoi(exponent); err != nil || exp < 0 || exp > 77 {
+			return nil, utils.ErrInvalidValue
+		}
+	}
+	if exp == 0 {
+		value, ok := new(big.Int).SetString(mantissa, 10)
+		if !ok || value.Sign() < 0 {
+			return nil, utils.ErrInvalidValue
+		}
+		return value, nil
+	}
+	amount, err := utils.ParseAmount(mantissa, int16(exp))
+	if err != nil {
+		return nil, err
+	}
+	return amount.BigInt(), nil
+}
+
+// Amount 按精度转换金额, 原生币精度为 18, 代币精度需查询合约
+func (p *PaymentURI) Amount(decimal int16) *utils.OptAmount {
+	if p.Value == nil {
+		return nil
+	}
+	return utils.NewOptAmount(p.Value.String(), decimal)
+}
+
+// String 生成 EIP-681 链接
+func (p *PaymentURI) String() string {
+	var params []string
+	target := p.To
+	if p.Token != nil {
+		target = *p.Token
+		params = append(params, "address="+p.To.Hex())
+	}
+	uri := EIP681Scheme + ":" + target.Hex()
+	if p.ChainId != nil {
+		uri += "@" + p.ChainId.String()
+	}
+	if p.Token != nil {
+		uri += "/" + eip681Transfer
+	}
+	if p.Value != nil {
+		if p.Token != nil {
+			params = append(params, "uint256="+p.Value.String())
+		} else {
+			params = append(params, "value="+p.Value.String())
+		}
+	}
+	if p.GasLimit > 0 {
+		params = append(params, "gasLimit="+strconv.FormatUint(p.GasLimit, 10))
+	}
+	if p.GasPrice != nil {
+		params = append(params, "gasPrice="+p.GasPrice.String())
+	}
+	if len(params) > 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
+
+// Transaction 生成转账交易, 代币转账时交易的 To 为合约地址, Data 为 transfer 调用
+func (p *PaymentURI) Transaction(from common.Address, chain *Chain) (*Transaction, error) {
+	if p.Value == nil {
+		return nil, log.WithError(utils.AmountError, "amount not specified")
+	}
+	if p.ChainId != nil && chain.ChainId() != nil && p.ChainId.Cmp(chain.ChainId()) != 0 {
+		return nil, log.WithError(utils.ErrAddressNetworkMismatch, "chain id mismatch")
+	}
+	if p.Token == nil {
+		tx := NewTransaction(from, p.To, p.Value, chain)
+		tx.GasLimit = p.GasLimit
+		tx.GasPrice = p.GasPrice
+		return tx, nil
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(ERC20InterfaceABI))
+	if err != nil {
+		return nil, log.WithError(err, "abi.JSON failed")
+	}
+	data, err := parsed.Pack(eip681Transfer, p.To, p.Value)
+	if err != nil {
+		return nil, log.WithError(err, "Pack failed")
+	}
+	tx := NewTransaction(from, *p.Token, big.NewInt(0), chain)
+	tx.Data = data
+	tx.GasLimit = p.GasLimit
+	tx.GasPrice = p.GasPrice
+	return tx, nil
+}
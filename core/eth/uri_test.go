@@ -0,0 +1,90 @@
+package eth
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"math/big"
+	"testing"
+)
+
+func TestParsePaymentURI(t *testing.T) {
+	to := common.HexToAddress("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	p, err := ParsePaymentURI("ethereum:0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359?value=2.014e18")
+	if assert.NoError(t, err) {
+		assert.Equal(t, to, p.To)
+		assert.Nil(t, p.Token)
+		assert.Nil(t, p.ChainId)
+		assert.Equal(t, "2014000000000000000", p.Value.String())
+		assert.Equal(t, "2.014", p.Amount(18).AmountString())
+	}
+
+	token := common.HexToAddress("0x89205a3a3b2a69de6dbf7f01ed13b2108b2c43e7")
+	p, err = ParsePaymentURI("ethereum:0x89205a3a3b2a69de6dbf7f01ed13b2108b2c43e7@1/transfer?address=0x8e23ee67d1332ad560396262c48ffbb01f93d052&uint256=1&gasLimit=60000")
+	if assert.NoError(t, err) {
+		assert.Equal(t, common.HexToAddress("0x8e23ee67d1332ad560396262c48ffbb01f93d052"), p.To)
+		assert.Equal(t, &token, p.Token)
+		assert.Equal(t, big.NewInt(1), p.ChainId)
+		assert.Equal(t, big.NewInt(1), p.Value)
+		assert.Equal(t, uint64(60000), p.GasLimit)
+	}
+
+	p, err = ParsePaymentURI("ethereum:pay-0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359@11155111")
+	if assert.NoError(t, err) {
+		assert.Equal(t, big.NewInt(11155111), p.ChainId)
+		assert.Nil(t, p.Value)
+	}
+}
+
+func TestParsePaymentURI_Invalid(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected *utils.Error
+	}{
+		{"bitcoin:0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", utils.ErrInvalidURL},
+		{"ethereum:vitalik.eth?value=1", utils.ErrInvalidAddress},
+		{"ethereum:0xfb6916095ca1df60bb79Ce92ce3ea74c37c5d35A", utils.ErrAddressChecksum},
+		{"ethereum:0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359@0", utils.ErrInvalidURL},
+		{"ethereum:0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359?value=1.5", utils.AmountError},
+		{"ethereum:0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359?value=-1", utils.AmountError},
+		{"ethereum:0x89205a3a3b2a69de6dbf7f01ed13b2108b2c43e7/approve?address=0x8e23ee67d1332ad560396262c48ffbb01f93d052", utils.ErrInvalidURL},
+		{"ethereum:0x89205a3a3b2a69de6dbf7f01ed13b2108b2c43e7/transfer?uint256=1", utils.ErrInvalidAddress},
+	}
+	for _, tt := range tests {
+		_, err := ParsePaymentURI(tt.uri)
+		if assert.Error(t, err, tt.uri) {
+			assert.Equal(t, tt.expected.ErrCode, err.(*utils.Error).ErrCode, tt.uri)
+		}
+	}
+}
+
+func TestPaymentURI_Transaction(t *testing.T) {
+	from := common.HexToAddress("0xEa2a9Ce354F787791597dF0686Ee2EB9716AE293")
+	to := common.HexToAddress("0x8e23ee67d1332ad560396262c48ffbb01f93d052")
+	token := common.HexToAddress("0x89205a3a3b2a69de6dbf7f01ed13b2108b2c43e7")
+	chain := &Chain{chainId: big.NewInt(1)}
+
+	native := &PaymentURI{To: to, ChainId: big.NewInt(1), Value: big.NewInt(1000)}
+	assert.Equal(t, "ethereum:"+to.Hex()+"@1?value=1000", native.String())
+	tx, err := native.Transaction(from, chain)
+	if assert.NoError(t, err) {
+		assert.Equal(t, to, tx.To)
+		assert.Equal(t, big.NewInt(1000), tx.Value)
+		assert.Empty(t, tx.Data)
+	}
+
+	transfer := &PaymentURI{To: to, Token: &token, Value: big.NewInt(1000000)}
+	parsed, err := ParsePaymentURI(transfer.String())
+	if assert.NoError(t, err) {
+		assert.Equal(t, transfer, parsed)
+	}
+	tx, err = transfer.Transaction(from, chain)
+	if assert.NoError(t, err) {
+		assert.Equal(t, token, tx.To)
+		assert.Equal(t, int64(0), tx.Value.Int64())
+		assert.Equal(t, "a9059cbb0000000000000000000000008e23ee67d1332ad560396262c48ffbb01f93d05200000000000000000000000000000000000000000000000000000000000f4240", common.Bytes2Hex(tx.Data))
+	}
+
+	_, err = (&PaymentURI{To: to, ChainId: big.NewInt(5), Value: big.NewInt(1)}).Transaction(from, chain)
+	assert.Equal(t, utils.ErrAddressNetworkMismatch.ErrCode, err.(*utils.Error).ErrCode)
+}
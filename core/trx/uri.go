@@ -0,0 +1,106 @@
+package trx
+
+import (
+	"github.com/fbsobreira/gotron-sdk/pkg/address"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"net/url"
+	"strings"
+)
+
+const (
+	// TronScheme Tron 付款链接的协议头
+	TronScheme = "tron"
+
+	maxTokenDecimal = 18
+)
+
+// tokenParamKeys 各钱包表示 TRC20 合约的参数名
+var tokenParamKeys = []string{"token", "contract", "contractAddress"}
+
+// PaymentURI Tron 付款请求, 如 tron:T...?amount=1.5&token=<TRC20合约>
+type PaymentURI struct {
+	Address string // 收款地址, base58 格式
+	Token   string // TRC20 合约地址, 原生 TRX 为空
+	Amount  string // 十进制金额, 未指定时为空
+}
+
+// ParsePaymentURI 解析 Tron 链接, 地址支持 base58 和 41 开头的 hex 格式
+func ParsePaymentURI(uri string) (*PaymentURI, error) {
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(uri), ":")
+	if !ok || !strings.EqualFold(scheme, TronScheme) {
+		return nil, log.WithError(utils.ErrInvalidURL, "not a tron uri")
+	}
+	rest = strings.TrimPrefix(rest, "//")
+	addr, query, _ := strings.Cut(rest, "?")
+
+	p := &PaymentURI{}
+	var err error
+	if p.Address, err = parseURIAddress(addr); err != nil {
+		return nil, err
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, log.WithError(utils.ErrInvalidURL, err.Error())
+	}
+	for _, key := range tokenParamKeys {
+		if token := values.Get(key); token != "" {
+			if p.Token, err = parseURIAddress(token); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if amount := values.Get("amount"); amount != "" {
+		// 代币精度未知, 按最大精度校验格式
+		if _, err = utils.ParseAmount(amount, maxTokenDecimal); err != nil {
+			return nil, log.WithError(utils.AmountError, "invalid amount "+amount)
+		}
+		p.Amount = amount
+	}
+	return p, nil
+}
+
+func parseURIAddress(s string) (string, error) {
+	digits := strings.TrimPrefix(s, "0x")
+	if len(digits) == 2*address.AddressLength {
+		addr := address.HexToAddress(digits)
+		if len(addr) != address.AddressLength || addr[0] != address.TronBytePrefix {
+			return "", log.WithError(utils.ErrInvalidAddress, "invalid address "+s)
+		}
+		return addr.String(), nil
+	}
+	addr, err := address.Base58ToAddress(s)
+	if err != nil {
+		return "", log.WithError(utils.ErrInvalidAddress, err.Error())
+	}
+	return addr.String(), nil
+}
+
+// Value 按精度转换金额, TRX 精度为 6, 代币精度需查询合约; 未指定金额时返回 nil
+func (p *PaymentURI) Value(decimal int16) (*utils.OptAmount, error) {
+	if p.Amount == "" {
+		return nil, nil
+	}
+	amount, err := utils.ParseAmount(p.Amount, decimal)
+	if err != nil {
+		return nil, log.WithError(utils.AmountError, "invalid amount "+p.Amount)
+	}
+	return amount, nil
+}
+
+// String 生成 Tron 链接
+func (p *PaymentURI) String() string {
+	var params []string
+	if p.Amount != "" {
+		params = append(params, "amount="+p.Amount)
+	}
+	if p.Token != "" {
+		params = append(params, "token="+p.Token)
+	}
+	uri := TronScheme + ":" + p.Address
+	if len(params) > 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
@@ -0,0 +1,56 @@
+package trx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"testing"
+)
+
+func TestParsePaymentURI(t *testing.T) {
+	const usdt = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	p, err := ParsePaymentURI("tron:" + addr + "?amount=1.5")
+	if assert.NoError(t, err) {
+		assert.Equal(t, addr, p.Address)
+		assert.Empty(t, p.Token)
+		value, err := p.Value(6)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1500000), value.BigInt().Int64())
+		assert.Equal(t, "tron:"+addr+"?amount=1.5", p.String())
+	}
+
+	p, err = ParsePaymentURI("tron://41a614f803b6fd780986a42c78ec9c7f77e6ded13c?contractAddress=" + usdt + "&amount=10")
+	if assert.NoError(t, err) {
+		assert.Equal(t, usdt, p.Address)
+		assert.Equal(t, usdt, p.Token)
+		value, err := p.Value(6)
+		assert.NoError(t, err)
+		assert.Equal(t, "10000000", value.BigInt().String())
+		assert.Equal(t, "tron:"+usdt+"?amount=10&token="+usdt, p.String())
+	}
+
+	p, err = ParsePaymentURI("tron:" + addr)
+	if assert.NoError(t, err) {
+		value, err := p.Value(6)
+		assert.NoError(t, err)
+		assert.Nil(t, value)
+	}
+}
+
+func TestParsePaymentURI_Invalid(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected *utils.Error
+	}{
+		{"ethereum:" + addr, utils.ErrInvalidURL},
+		{"tron:TU7YLwySaoPJCDuhF2tZcsxJVDX8umJuXf", utils.ErrInvalidAddress},
+		{"tron:0x8e23ee67d1332ad560396262c48ffbb01f93d052", utils.ErrInvalidAddress},
+		{"tron:" + addr + "?token=0x8e23ee67d1332ad560396262c48ffbb01f93d052", utils.ErrInvalidAddress},
+		{"tron:" + addr + "?amount=1,5", utils.AmountError},
+	}
+	for _, tt := range tests {
+		_, err := ParsePaymentURI(tt.uri)
+		if assert.Error(t, err, tt.uri) {
+			assert.Equal(t, tt.expected.ErrCode, err.(*utils.Error).ErrCode, tt.uri)
+		}
+	}
+}
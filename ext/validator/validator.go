@@ -0,0 +1,240 @@
+package validator
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/common"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils/log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// AddressType 地址类型
+type AddressType string
+
+const (
+	AddressP2PKH  AddressType = "P2PKH"
+	AddressP2SH   AddressType = "P2SH"
+	AddressP2WPKH AddressType = "P2WPKH"
+	AddressP2WSH  AddressType = "P2WSH"
+	AddressP2TR   AddressType = "P2TR"
+
+	// AddressEVM 未查询链上代码, 无法区分 EOA 和合约
+	AddressEVM      AddressType = "EVM"
+	AddressEOA      AddressType = "EOA"
+	AddressContract AddressType = "CONTRACT"
+
+	AddressTronBase58 AddressType = "BASE58"
+	AddressTronHex    AddressType = "HEX"
+)
+
+const (
+	tronAddressPrefix = 0x41
+	defaultTimeout    = 10 * time.Second
+)
+
+// btcNetworks 用于识别地址所属的网络
+var btcNetworks = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet3Params,
+	&chaincfg.RegressionNetParams,
+	&chaincfg.SimNetParams,
+	&chaincfg.SigNetParams,
+}
+
+// AddressInfo 地址校验结果
+type AddressInfo struct {
+	Address  string      `json:"address"` // 规范化后的地址
+	Type     AddressType `json:"type"`
+	CoinType uint32      `json:"coinType"`
+	ChainId  int         `json:"chainId"`
+}
+
+// CodeReader 查询合约代码, ethclient.Client 实现了该接口
+type CodeReader interface {
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+}
+
+// Validator 按币种和网络校验转账目标地址
+type Validator struct {
+	codeReader CodeReader
+	timeout    time.Duration
+}
+
+func NewValidator() *Validator {
+	return &Validator{timeout: defaultTimeout}
+}
+
+// SetCodeReader 设置后 EVM 地址会区分 EOA 和合约
+func (v *Validator) SetCodeReader(reader CodeReader) *Validator {
+	v.codeReader = reader
+	return v
+}
+
+func (v *Validator) SetTimeout(timeout time.Duration) *Validator {
+	v.timeout = timeout
+	return v
+}
+
+// ValidateAddress 不查询链上状态的地址校验
+func ValidateAddress(coinType uint32, chainId int, address string) (*AddressInfo, error) {
+	return NewValidator().Validate(coinType, chainId, address)
+}
+
+// Validate 校验地址并返回规范化地址和类型, 代币按所属主链校验
+// BTC 的 chainId 为 utils.BtcChainXxx, EVM 和 TRX 的地址与网络无关
+func (v *Validator) Validate(coinType uint32, chainId int, address string) (*AddressInfo, error) {
+	if mainCoin, ok := utils.CoinTypes[coinType]; ok {
+		coinType = mainCoin
+	}
+	address = strings.TrimSpace(address)
+
+	var info *AddressInfo
+	var err error
+	switch coinType {
+	case utils.BTC:
+		info, err = validateBtc(chainId, address)
+	case utils.ETH:
+		info, err = v.validateEvm(address)
+	case utils.TRX:
+		info, err = validateTrx(address)
+	default:
+		return nil, log.WithError(utils.ErrInvalidValue, "coin type not supported")
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.CoinType = coinType
+	info.ChainId = chainId
+	return info, nil
+}
+
+func validateBtc(chainId int, address string) (*AddressInfo, error) {
+	params, err := utils.GetBtcChainParams(chainId)
+	if err != nil {
+		return nil, log.WithError(utils.ErrInvalidValue, err.Error())
+	}
+	// bech32 地址允许全大写(如二维码), 统一转为小写
+	if lower := strings.ToLower(address); address == strings.ToUpper(address) && isBech32(lower) {
+		address = lower
+	}
+
+	addr, err := decodeBtcAddress(address, params)
+	if err != nil {
+		var bech32Checksum bech32.ErrInvalidChecksum
+		if errors.Is(err, btcutil.ErrChecksumMismatch) || errors.As(err, &bech32Checksum) {
+			return nil, log.WithError(utils.ErrAddressChecksum, err.Error())
+		}
+		for _, other := range btcNetworks {
+			if other.Net == params.Net {
+				continue
+			}
+			if _, e := decodeBtcAddress(address, other); e == nil {
+				return nil, log.WithError(utils.ErrAddressNetworkMismatch, "address is for "+other.Name)
+			}
+		}
+		return nil, log.WithError(utils.ErrInvalidAddress, err.Error())
+	}
+
+	info := &AddressInfo{Address: addr.EncodeAddress()}
+	switch addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		info.Type = AddressP2PKH
+	case *btcutil.AddressScriptHash:
+		info.Type = AddressP2SH
+	case *btcutil.AddressWitnessPubKeyHash:
+		info.Type = AddressP2WPKH
+	case *btcutil.AddressWitnessScriptHash:
+		info.Type = AddressP2WSH
+	case *btcutil.AddressTaproot:
+		info.Type = AddressP2TR
+	default:
+		return nil, log.WithError(utils.ErrAddressTypeNotSupported, "validateBtc failed")
+	}
+	return info, nil
+}
+
+func isBech32(address string) bool {
+	i := strings.LastIndexByte(address, '1')
+	return i > 1 && chaincfg.IsBech32SegwitPrefix(address[:i+1])
+}
+
+// decodeBtcAddress 与 btcutil.DecodeAddress 相同, 但不接受公钥并校验网络
+func decodeBtcAddress(address string, params *chaincfg.Params) (btcutil.Address, error) {
+	addr, err := btcutil.DecodeAddress(address, params)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := addr.(*btcutil.AddressPubKey); ok {
+		return nil, errors.New("public key is not an address")
+	}
+	if !addr.IsForNet(params) {
+		return nil, errors.New("address is not for " + params.Name)
+	}
+	return addr, nil
+}
+
+func (v *Validator) validateEvm(address string) (*AddressInfo, error) {
+	if !strings.HasPrefix(address, "0x") || !common.IsHexAddress(address) {
+		return nil, log.WithError(utils.ErrInvalidAddress, "not a hex address")
+	}
+	// 大小写混合时按 EIP-55 校验, 全小写或全大写不含校验信息
+	digits := address[2:]
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) {
+		mixed, err := common.NewMixedcaseAddressFromString(address)
+		if err != nil || !mixed.ValidChecksum() {
+			return nil, log.WithError(utils.ErrAddressChecksum, "EIP-55 checksum mismatch")
+		}
+	}
+	addr := common.HexToAddress(address)
+	if addr == (common.Address{}) {
+		return nil, log.WithError(utils.ErrInvalidAddress, "zero address")
+	}
+
+	info := &AddressInfo{Address: addr.Hex(), Type: AddressEVM}
+	if v.codeReader == nil {
+		return info, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+	code, err := v.codeReader.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return nil, log.WithError(err, "CodeAt failed")
+	}
+	info.Type = AddressEOA
+	if len(code) > 0 {
+		info.Type = AddressContract
+	}
+	return info, nil
+}
+
+// validateTrx 支持 base58 地址和 41 开头的 hex 地址, 规范化为 base58
+func validateTrx(address string) (*AddressInfo, error) {
+	digits := strings.TrimPrefix(address, "0x")
+	if len(digits) == 2*(common.AddressLength+1) {
+		data, err := hex.DecodeString(digits)
+		if err != nil || data[0] != tronAddressPrefix {
+			return nil, log.WithError(utils.ErrInvalidAddress, "not a tron hex address")
+		}
+		return &AddressInfo{Address: base58.CheckEncode(data[1:], tronAddressPrefix), Type: AddressTronHex}, nil
+	}
+
+	payload, version, err := base58.CheckDecode(address)
+	if err != nil {
+		if errors.Is(err, base58.ErrChecksum) {
+			return nil, log.WithError(utils.ErrAddressChecksum, err.Error())
+		}
+		return nil, log.WithError(utils.ErrInvalidAddress, err.Error())
+	}
+	if version != tronAddressPrefix || len(payload) != common.AddressLength {
+		return nil, log.WithError(utils.ErrInvalidAddress, "not a tron address")
+	}
+	return &AddressInfo{Address: address, Type: AddressTronBase58}, nil
+}
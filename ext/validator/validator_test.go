@@ -0,0 +1,148 @@
+package validator
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/config"
+	"hypier.fun/hdwallet/hdwallet-go-sdk/utils"
+	"math/big"
+	"testing"
+)
+
+func init() {
+	config.InitConfig(&config.BaseConfig{
+		BaseDir:    "..",
+		LogSwitch:  "CONSOLE_FILE",
+		Platform:   "ALL",
+		DeviceType: "UNKNOWN",
+	})
+}
+
+type fakeCodeReader map[common.Address][]byte
+
+func (r fakeCodeReader) CodeAt(_ context.Context, account common.Address, _ *big.Int) ([]byte, error) {
+	return r[account], nil
+}
+
+func assertErrCode(t *testing.T, err error, expected *utils.Error) {
+	t.Helper()
+	if assert.Error(t, err) {
+		assert.Equal(t, expected.ErrCode, err.(*utils.Error).ErrCode)
+	}
+}
+
+func TestValidateAddress_Btc(t *testing.T) {
+	tests := []struct {
+		chainId  int
+		address  string
+		expected string
+		addrType AddressType
+	}{
+		{utils.BtcChainMainNet, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", AddressP2PKH},
+		{utils.BtcChainMainNet, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", AddressP2SH},
+		{utils.BtcChainMainNet, "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", AddressP2WPKH},
+		{utils.BtcChainMainNet, "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3", "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3", AddressP2WSH},
+		{utils.BtcChainMainNet, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", AddressP2TR},
+		{utils.BtcChainTestNet3, " tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732 ", "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", AddressP2WPKH},
+		{utils.BtcChainTestNet3, "2MuKWyXzED48Rag2WrrLC97BgtCuteUzLDS", "2MuKWyXzED48Rag2WrrLC97BgtCuteUzLDS", AddressP2SH},
+		{utils.BtcChainTestNet3, "n43tW32TTVfapiTEstqmhAAoasEcRdAJEm", "n43tW32TTVfapiTEstqmhAAoasEcRdAJEm", AddressP2PKH},
+	}
+	for _, tt := range tests {
+		info, err := ValidateAddress(utils.BTC, tt.chainId, tt.address)
+		if assert.NoError(t, err, tt.address) {
+			assert.Equal(t, tt.expected, info.Address)
+			assert.Equal(t, tt.addrType, info.Type)
+			assert.Equal(t, utils.BTC, info.CoinType)
+		}
+	}
+
+	// 代币按主链校验
+	info, err := ValidateAddress(utils.USDT, utils.BtcChainMainNet, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, utils.BTC, info.CoinType)
+	}
+}
+
+func TestValidateAddress_BtcInvalid(t *testing.T) {
+	tests := []struct {
+		chainId  int
+		address  string
+		expected *utils.Error
+	}{
+		{utils.BtcChainMainNet, "tb1q7uk8a46p5e424l0mdh7whldn0mzlvl56c45732", utils.ErrAddressNetworkMismatch},
+		{utils.BtcChainMainNet, "n43tW32TTVfapiTEstqmhAAoasEcRdAJEm", utils.ErrAddressNetworkMismatch},
+		{utils.BtcChainTestNet3, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", utils.ErrAddressNetworkMismatch},
+		{utils.BtcChainMainNet, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3", utils.ErrAddressChecksum},
+		{utils.BtcChainMainNet, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", utils.ErrAddressChecksum},
+		{utils.BtcChainMainNet, "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", utils.ErrInvalidAddress},
+		{utils.BtcChainMainNet, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", utils.ErrInvalidAddress},
+		{0, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", utils.ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		_, err := ValidateAddress(utils.BTC, tt.chainId, tt.address)
+		assertErrCode(t, err, tt.expected)
+	}
+}
+
+func TestValidateAddress_Evm(t *testing.T) {
+	const checksummed = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	for _, address := range []string{checksummed, "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED"} {
+		info, err := ValidateAddress(utils.ETH, 1, address)
+		if assert.NoError(t, err, address) {
+			assert.Equal(t, checksummed, info.Address)
+			assert.Equal(t, AddressEVM, info.Type)
+		}
+	}
+
+	_, err := ValidateAddress(utils.ETH, 1, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD")
+	assertErrCode(t, err, utils.ErrAddressChecksum)
+	_, err = ValidateAddress(utils.USDC, 1, "5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	assertErrCode(t, err, utils.ErrInvalidAddress)
+	_, err = ValidateAddress(utils.ETH, 1, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA")
+	assertErrCode(t, err, utils.ErrInvalidAddress)
+	_, err = ValidateAddress(utils.ETH, 1, "0x0000000000000000000000000000000000000000")
+	assertErrCode(t, err, utils.ErrInvalidAddress)
+}
+
+func TestValidator_EvmCode(t *testing.T) {
+	contract := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	v := NewValidator().SetCodeReader(fakeCodeReader{contract: {0x60, 0x80}})
+
+	info, err := v.Validate(utils.ETH, 1, contract.Hex())
+	if assert.NoError(t, err) {
+		assert.Equal(t, AddressContract, info.Type)
+	}
+	info, err = v.Validate(utils.ETH, 1, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	if assert.NoError(t, err) {
+		assert.Equal(t, AddressEOA, info.Type)
+	}
+}
+
+func TestValidateAddress_Trx(t *testing.T) {
+	const base58Address = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	tests := []struct {
+		address  string
+		addrType AddressType
+	}{
+		{base58Address, AddressTronBase58},
+		{"41a614f803b6fd780986a42c78ec9c7f77e6ded13c", AddressTronHex},
+		{"0x41A614F803B6FD780986A42C78EC9C7F77E6DED13C", AddressTronHex},
+	}
+	for _, tt := range tests {
+		info, err := ValidateAddress(utils.TRX, 0, tt.address)
+		if assert.NoError(t, err, tt.address) {
+			assert.Equal(t, base58Address, info.Address)
+			assert.Equal(t, tt.addrType, info.Type)
+		}
+	}
+
+	_, err := ValidateAddress(utils.TRX, 0, "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6u")
+	assertErrCode(t, err, utils.ErrAddressChecksum)
+	_, err = ValidateAddress(utils.TRX, 0, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2")
+	assertErrCode(t, err, utils.ErrInvalidAddress)
+	_, err = ValidateAddress(utils.TRX, 0, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	assertErrCode(t, err, utils.ErrInvalidAddress)
+	_, err = ValidateAddress(utils.TRX, 0, "42a614f803b6fd780986a42c78ec9c7f77e6ded13c")
+	assertErrCode(t, err, utils.ErrInvalidAddress)
+}
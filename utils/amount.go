@@ -3,9 +3,13 @@ package utils
 import (
 	"github.com/shopspring/decimal"
 	"math/big"
+	"regexp"
 	"strings"
 )
 
+// amountPattern 十进制非负金额, 不支持科学计数法
+var amountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]*)?$`)
+
 type OptAmount struct {
 	value   string
 	decimal int16
@@ -63,7 +67,7 @@ func NewOptAmount(value string, decimal int16) *OptAmount {
 
 // ParseAmount 将string转换为OptAmount
 func ParseAmount(value string, decimal int16) (*OptAmount, error) {
-	if decimal <= 0 {
+	if decimal <= 0 || !amountPattern.MatchString(value) {
 		return nil, ErrInvalidValue
 	}
 
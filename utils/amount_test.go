@@ -192,6 +192,33 @@ func TestParseAmount(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "TestParseAmount",
+			args: args{
+				amount:  ".5",
+				decimal: 8,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "TestParseAmount",
+			args: args{
+				amount:  "-1",
+				decimal: 8,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "TestParseAmount",
+			args: args{
+				amount:  "1e8",
+				decimal: 8,
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"sync"
+	"time"
+)
+
+// BTC 系的其它链, chainId 使用网络的 magic, 与 BtcChainMainNet 等保持一致
+const (
+	LtcChainMainNet  = 0xdbb6c0fb
+	LtcChainTestNet4 = 0xf1c8d2fd
+	DogeChainMainNet = 0xc0c0c0c0
+	DogeChainTestNet = 0xdcb7c1fc
+	BchChainMainNet  = 0xe8f3e1e3
+	BchChainTestNet3 = 0xf4f3e5f4
+)
+
+// LtcMainNetParams Litecoin 主网, 不支持 MWEB(扩展块)
+var LtcMainNetParams = newBtcFamilyParams(chaincfg.MainNetParams, func(p *chaincfg.Params) {
+	p.Name = "ltc-mainnet"
+	p.Net = LtcChainMainNet
+	p.DefaultPort = "9333"
+	p.GenesisHash = mustHash("12a765e31ffd4059bada1e25190f6e98c99d9714d334efa41a195a7e7e04bfe2")
+	p.Bech32HRPSegwit = "ltc"
+	p.PubKeyHashAddrID = 0x30
+	p.ScriptHashAddrID = 0x32
+	p.PrivateKeyID = 0xb0
+	p.HDPrivateKeyID = [4]byte{0x01, 0x9d, 0x9c, 0xfe} // Ltpv
+	p.HDPublicKeyID = [4]byte{0x01, 0x9d, 0xa4, 0x62}  // Ltub
+	p.HDCoinType = 2
+})
+
+// LtcTestNet4Params Litecoin 测试网
+var LtcTestNet4Params = newBtcFamilyParams(chaincfg.TestNet3Params, func(p *chaincfg.Params) {
+	p.Name = "ltc-testnet4"
+	p.Net = LtcChainTestNet4
+	p.DefaultPort = "19335"
+	p.GenesisHash = mustHash("4966625a4b2851d9fdee139e56211a0d88575f59ed816ff5e6a63deb4e3e29a0")
+	p.Bech32HRPSegwit = "tltc"
+	p.PubKeyHashAddrID = 0x6f
+	p.ScriptHashAddrID = 0x3a
+	p.PrivateKeyID = 0xef
+})
+
+// DogeMainNetParams Dogecoin 主网, 没有隔离见证
+var DogeMainNetParams = newBtcFamilyParams(chaincfg.MainNetParams, func(p *chaincfg.Params) {
+	p.Name = "doge-mainnet"
+	p.Net = DogeChainMainNet
+	p.DefaultPort = "22556"
+	p.GenesisHash = mustHash("1a91e3dace36e2be3bf030a65679fe821aa1d6ef92e7c9902eb318182c355691")
+	p.CoinbaseMaturity = 240
+	p.Bech32HRPSegwit = ""
+	p.PubKeyHashAddrID = 0x1e
+	p.ScriptHashAddrID = 0x16
+	p.PrivateKeyID = 0x9e
+	p.HDPrivateKeyID = [4]byte{0x02, 0xfa, 0xc3, 0x98} // dgpv
+	p.HDPublicKeyID = [4]byte{0x02, 0xfa, 0xca, 0xfd}  // dgub
+	p.HDCoinType = 3
+})
+
+// DogeTestNetParams Dogecoin 测试网
+var DogeTestNetParams = newBtcFamilyParams(chaincfg.TestNet3Params, func(p *chaincfg.Params) {
+	p.Name = "doge-testnet"
+	p.Net = DogeChainTestNet
+	p.DefaultPort = "44556"
+	p.GenesisHash = mustHash("bb0a78264637406b6360aad926284d544d7049f45189db5664f3c4d07350559e")
+	p.CoinbaseMaturity = 240
+	p.Bech32HRPSegwit = ""
+	p.PubKeyHashAddrID = 0x71
+	p.ScriptHashAddrID = 0xc4
+	p.PrivateKeyID = 0xf1
+})
+
+// BchMainNetParams Bitcoin Cash 主网, 地址使用 CashAddr 编码, 签名使用 SIGHASH_FORKID
+var BchMainNetParams = newBtcFamilyParams(chaincfg.MainNetParams, func(p *chaincfg.Params) {
+	p.Name = "bch-mainnet"
+	p.Net = BchChainMainNet
+	p.Bech32HRPSegwit = ""
+	p.HDCoinType = 145
+})
+
+// BchTestNet3Params Bitcoin Cash 测试网
+var BchTestNet3Params = newBtcFamilyParams(chaincfg.TestNet3Params, func(p *chaincfg.Params) {
+	p.Name = "bch-testnet3"
+	p.Net = BchChainTestNet3
+	p.Bech32HRPSegwit = ""
+})
+
+// testNet4GenesisBlock 比特币 testnet4 的创世区块
+var testNet4GenesisBlock = func() wire.MsgBlock {
+	message := []byte("03/May/2024 000000000000000000001ebd58c244970b3aa9d783bb001011fbe8ea8e98e00e")
+	sigScript := append([]byte{0x04, 0xff, 0xff, 0x00, 0x1d, 0x01, 0x04, 0x4c, byte(len(message))}, message...)
+	pkScript := append(append([]byte{0x21}, make([]byte, 33)...), 0xac)
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), sigScript, nil))
+	coinbase.AddTxOut(wire.NewTxOut(50*1e8, pkScript))
+	return wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    1,
+			MerkleRoot: coinbase.TxHash(),
+			Timestamp:  time.Unix(1714777860, 0),
+			Bits:       0x1d00ffff,
+			Nonce:      393743547,
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}
+}()
+
+// TestNet4Params 比特币 testnet4(BIP94), 地址编码与 testnet3 相同
+var TestNet4Params = newBtcFamilyParams(chaincfg.TestNet3Params, func(p *chaincfg.Params) {
+	p.Name = "testnet4"
+	p.Net = BtcChainTestNet4
+	p.DefaultPort = "48333"
+	p.DNSSeeds = []chaincfg.DNSSeed{
+		{Host: "seed.testnet4.bitcoin.sprovoost.nl", HasFiltering: true},
+		{Host: "seed.testnet4.wiz.biz", HasFiltering: true},
+	}
+	p.GenesisBlock = &testNet4GenesisBlock
+	p.GenesisHash = mustHash("00000000da84f2bafbbc53dee25a72ae507ff4914b867c565be350b0da8bf043")
+	p.BIP0034Height = 1
+	p.BIP0065Height = 1
+	p.BIP0066Height = 1
+})
+
+// EnforceBIP94 是否启用 BIP94 的难度调整和时间扭曲规则(testnet4)
+func EnforceBIP94(params *chaincfg.Params) bool {
+	return params.Net == BtcChainTestNet4
+}
+
+var (
+	customSignetsMu sync.RWMutex
+	customSignets   = make(map[wire.BitcoinNet]*chaincfg.Params)
+)
+
+// CustomSignetParams 使用自定义 challenge 的 signet, 返回参数的 Net 可以作为 chainId 使用
+// 名称为 signet-<Net 的十六进制>, 相同的 challenge 返回同一个参数
+func CustomSignetParams(challenge []byte) (*chaincfg.Params, error) {
+	if len(challenge) == 0 {
+		return nil, ErrInvalidValue
+	}
+	if bytes.Equal(challenge, chaincfg.DefaultSignetChallenge) {
+		return &chaincfg.SigNetParams, nil
+	}
+	params := chaincfg.CustomSignetParams(challenge, nil)
+	customSignetsMu.Lock()
+	defer customSignetsMu.Unlock()
+	if existing, ok := customSignets[params.Net]; ok {
+		return existing, nil
+	}
+	params.Name = fmt.Sprintf("signet-%08x", uint32(params.Net))
+	if err := chaincfg.Register(&params); err != nil {
+		return nil, err
+	}
+	customSignets[params.Net] = &params
+	return &params, nil
+}
+
+// btcFamilyCoinTypes 链参数对应的币种
+var btcFamilyCoinTypes = map[wire.BitcoinNet]uint32{
+	LtcChainMainNet:  LTC,
+	LtcChainTestNet4: LTC,
+	DogeChainMainNet: DOGE,
+	DogeChainTestNet: DOGE,
+	BchChainMainNet:  BCH,
+	BchChainTestNet3: BCH,
+}
+
+var btcFamilyParams = []*chaincfg.Params{
+	&LtcMainNetParams, &LtcTestNet4Params,
+	&DogeMainNetParams, &DogeTestNetParams,
+	&BchMainNetParams, &BchTestNet3Params,
+	&chaincfg.SigNetParams, &TestNet4Params,
+}
+
+func init() {
+	// 注册后 btcutil 才能识别 ltc1 等 bech32 前缀, btcd 没有注册 signet
+	for _, params := range btcFamilyParams {
+		if err := chaincfg.Register(params); err != nil {
+			panic("failed to register network " + params.Name + ": " + err.Error())
+		}
+	}
+}
+
+func newBtcFamilyParams(base chaincfg.Params, apply func(p *chaincfg.Params)) chaincfg.Params {
+	base.DNSSeeds = nil
+	base.Checkpoints = nil
+	base.Deployments = [chaincfg.DefinedDeployments]chaincfg.ConsensusDeployment{}
+	base.GenesisBlock = nil
+	apply(&base)
+	return base
+}
+
+func mustHash(s string) *chainhash.Hash {
+	hash, err := chainhash.NewHashFromStr(s)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+// BtcCoinType 链参数对应的币种, 比特币各网络返回 BTC
+func BtcCoinType(params *chaincfg.Params) uint32 {
+	if params == nil {
+		return BTC
+	}
+	if coinType, ok := btcFamilyCoinTypes[params.Net]; ok {
+		return coinType
+	}
+	return BTC
+}
+
+// BtcChainName 链参数的名称, 可以用 GetBtcChainParam 还原
+func BtcChainName(params *chaincfg.Params) string {
+	switch params.Net {
+	case wire.MainNet, wire.TestNet3, wire.TestNet, wire.SimNet:
+		return params.Net.String()
+	}
+	return params.Name
+}
+
+// IsForkIdChain 是否使用 SIGHASH_FORKID 签名(Bitcoin Cash)
+func IsForkIdChain(params *chaincfg.Params) bool {
+	return BtcCoinType(params) == BCH
+}
+
+// getBtcFamilyParams 在 BTC 系其它链、signet、testnet4 和已创建的自定义 signet 中查找
+func getBtcFamilyParams(match func(p *chaincfg.Params) bool) *chaincfg.Params {
+	for _, params := range btcFamilyParams {
+		if match(params) {
+			return params
+		}
+	}
+	customSignetsMu.RLock()
+	defer customSignetsMu.RUnlock()
+	for _, params := range customSignets {
+		if match(params) {
+			return params
+		}
+	}
+	return nil
+}
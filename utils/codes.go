@@ -61,6 +61,9 @@ const (
 	BtcChainTestNet3 = int(wire.TestNet3)
 	BtcChainRegtest  = int(wire.TestNet)
 	BtcChainSimNet   = int(wire.SimNet)
+	// BtcChainSigNet 默认 challenge 的 signet, 自定义 challenge 的 signet 使用 CustomSignetParams 返回参数的 Net
+	BtcChainSigNet   = 0x40cf030a
+	BtcChainTestNet4 = 0x283f161c
 )
 
 var CoinTypes = map[uint32]uint32{
@@ -84,6 +87,9 @@ func GetBtcChainParams(chainId int) (*chaincfg.Params, error) {
 	case BtcChainSimNet:
 		return &chaincfg.SimNetParams, nil
 	default:
+		if params := getBtcFamilyParams(func(p *chaincfg.Params) bool { return int(p.Net) == chainId }); params != nil {
+			return params, nil
+		}
 		return nil, fmt.Errorf("unknown btc chainId: %d", chainId)
 	}
 }
@@ -98,6 +104,9 @@ func GetBtcChainParam(name string) (*chaincfg.Params, error) {
 	case wire.SimNet.String():
 		return &chaincfg.SimNetParams, nil
 	default:
+		if params := getBtcFamilyParams(func(p *chaincfg.Params) bool { return p.Name == name }); params != nil {
+			return params, nil
+		}
 		return nil, fmt.Errorf("unknown btc name: %s", name)
 	}
 }
@@ -112,6 +121,9 @@ func GetBtcChainId(name string) (int, error) {
 	case wire.SimNet.String():
 		return BtcChainSimNet, nil
 	default:
+		if params := getBtcFamilyParams(func(p *chaincfg.Params) bool { return p.Name == name }); params != nil {
+			return int(params.Net), nil
+		}
 		return -1, fmt.Errorf("unknown btc name: %s", name)
 	}
 }
@@ -31,6 +31,43 @@ var (
 	ToError               = NewError(111, "to error")
 	AmountError           = NewError(112, "amount error")
 	PasswordError         = NewError(113, "password error")
+
+	// ErrInvalidSignature 无效的签名
+	ErrInvalidSignature = NewError(114, "invalid signature")
+	// ErrAddressTypeNotSupported 不支持的地址类型
+	ErrAddressTypeNotSupported = NewError(115, "address type not supported")
+	// ErrInvalidAddress 地址格式错误
+	ErrInvalidAddress = NewError(116, "invalid address")
+	// ErrAddressNetworkMismatch 地址属于其他网络, 如在主网使用测试网地址
+	ErrAddressNetworkMismatch = NewError(117, "address network mismatch")
+	// ErrAddressChecksum 地址校验和错误
+	ErrAddressChecksum = NewError(118, "address checksum mismatch")
+	// ErrNotSupported 当前实现不支持该功能
+	ErrNotSupported = NewError(119, "not supported")
+	// ErrDustOutput 输出金额低于粉尘阈值
+	ErrDustOutput = NewError(120, "dust output")
+	// ErrFeeTooLow 手续费低于最低转发费率
+	ErrFeeTooLow = NewError(121, "fee below min relay fee")
+	// ErrAbsurdFee 手续费率过高
+	ErrAbsurdFee = NewError(122, "absurdly high fee")
+	// ErrNonStandard 交易不符合节点的标准规则
+	ErrNonStandard = NewError(123, "non-standard transaction")
+	// ErrDuplicateInput 重复花费同一个输出
+	ErrDuplicateInput = NewError(124, "duplicate input")
+	// ErrImmatureCoinbase 花费未成熟的 coinbase 输出
+	ErrImmatureCoinbase = NewError(125, "immature coinbase spend")
+	// ErrInvalidProof 默克尔证明与区块头不符
+	ErrInvalidProof = NewError(126, "invalid merkle proof")
+	// ErrInvalidHeader 区块头工作量证明或链连续性校验失败
+	ErrInvalidHeader = NewError(127, "invalid block header")
+	// ErrUnknownBlock 区块头不在本地的区块头链中
+	ErrUnknownBlock = NewError(128, "unknown block")
+	// ErrKeyNotFound 没有输入地址对应的私钥
+	ErrKeyNotFound = NewError(129, "private key not found")
+	// ErrCoinFrozen 花费已冻结的 UTXO
+	ErrCoinFrozen = NewError(130, "coin is frozen")
+	// ErrTransactionNotPending 要替换的交易已打包或不存在
+	ErrTransactionNotPending = NewError(131, "transaction is not pending")
 )
 
 type Error struct {
@@ -63,6 +100,14 @@ func NewSysError(err error) *Error {
 	}
 }
 
+// WithMessage 返回附带详细信息的同类错误
+func (e *Error) WithMessage(msg string) *Error {
+	return &Error{
+		ErrCode: e.ErrCode,
+		ErrMsg:  fmt.Errorf("%s: %s", e.ErrMsg.Error(), msg),
+	}
+}
+
 func (e *Error) ErrorCode() int {
 	return e.ErrCode
 }